@@ -0,0 +1,76 @@
+package arbitrum
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+const (
+	minTrieFlushInterval = 10 * time.Second
+	maxTrieFlushInterval = 24 * time.Hour
+)
+
+var errTrieFlushPathScheme = errors.New("trie flush interval is undefined for path-based scheme")
+
+// ArbAdminAPI offers administrative RPC methods for tuning the node at runtime
+type ArbAdminAPI struct {
+	blockchain            *core.BlockChain
+	maxRecreateStateDepth int64
+}
+
+// NewArbAdminAPI creates a new admin API instance.
+func NewArbAdminAPI(blockchain *core.BlockChain, maxRecreateStateDepth int64) *ArbAdminAPI {
+	return &ArbAdminAPI{blockchain, maxRecreateStateDepth}
+}
+
+// TrieFlushStatus is the result of admin_getTrieFlushStatus.
+type TrieFlushStatus struct {
+	FlushInterval       string         `json:"flushInterval"`
+	AccumulatedProcTime string         `json:"accumulatedProcTime"`
+	LastFlushBlock      hexutil.Uint64 `json:"lastFlushBlock"`
+}
+
+// GetTrieFlushStatus returns the trie flush interval along with the block
+// processing time accumulated towards it and the last block flushed by it.
+func (api *ArbAdminAPI) GetTrieFlushStatus() (*TrieFlushStatus, error) {
+	if api.blockchain.TrieDB().Scheme() == rawdb.PathScheme {
+		return nil, errTrieFlushPathScheme
+	}
+	status := api.blockchain.TrieFlushStatus()
+	return &TrieFlushStatus{
+		FlushInterval:       status.FlushInterval.String(),
+		AccumulatedProcTime: status.AccumulatedProcTime.String(),
+		LastFlushBlock:      hexutil.Uint64(status.LastFlushBlock),
+	}, nil
+}
+
+// SetTrieFlushInterval configures how often in-memory tries are persisted to
+// disk, in terms of block processing time. The new interval applies right away.
+func (api *ArbAdminAPI) SetTrieFlushInterval(interval string) error {
+	if api.blockchain.TrieDB().Scheme() == rawdb.PathScheme {
+		return errTrieFlushPathScheme
+	}
+	t, err := time.ParseDuration(interval)
+	if err != nil {
+		return err
+	}
+	if t < minTrieFlushInterval || t > maxTrieFlushInterval {
+		return fmt.Errorf("trie flush interval %v out of bounds [%v, %v]", t, minTrieFlushInterval, maxTrieFlushInterval)
+	}
+	api.blockchain.SetTrieFlushInterval(t)
+	return nil
+}
+
+// RepairReceiptRange re-executes the given range of finalized blocks and
+// overwrites their stored receipts, restoring the Arbitrum specific fields
+// missing from receipts synced from older nodes. Blocks in the ancient store are
+// supported, unless their receipts were pruned. Missing state is recreated up to
+// the configured MaxRecreateStateDepth.
+func (api *ArbAdminAPI) RepairReceiptRange(first, last hexutil.Uint64) error {
+	return api.blockchain.RepairReceiptRange(uint64(first), uint64(last), api.maxRecreateStateDepth)
+}
@@ -0,0 +1,123 @@
+package arbitrum
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestAdminTrieFlushInterval(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		genesis = &core.Genesis{
+			Config:  params.TestChainConfig,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		_, blocks, _ = core.GenerateChainWithGenesis(genesis, engine, 64, nil)
+	)
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(rawdb.HashScheme), nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("admin", NewArbAdminAPI(chain, InfiniteMaxRecreateStateDepth)); err != nil {
+		t.Fatalf("failed to register api: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	// Import blocks while querying and changing the flush interval
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, block := range blocks {
+			if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+				t.Errorf("failed to insert block %d: %v", block.NumberU64(), err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 32; i++ {
+		var status TrieFlushStatus
+		if err := client.Call(&status, "admin_getTrieFlushStatus"); err != nil {
+			t.Fatalf("failed to get flush status: %v", err)
+		}
+		if err := client.Call(nil, "admin_setTrieFlushInterval", "30s"); err != nil {
+			t.Fatalf("failed to set flush interval: %v", err)
+		}
+	}
+	wg.Wait()
+
+	if err := client.Call(nil, "admin_setTrieFlushInterval", "90m"); err != nil {
+		t.Fatalf("failed to set flush interval: %v", err)
+	}
+	if have := chain.GetTrieFlushInterval(); have != 90*time.Minute {
+		t.Fatalf("flush interval mismatch: have %v, want %v", have, 90*time.Minute)
+	}
+	var status TrieFlushStatus
+	if err := client.Call(&status, "admin_getTrieFlushStatus"); err != nil {
+		t.Fatalf("failed to get flush status: %v", err)
+	}
+	if status.FlushInterval != "1h30m0s" {
+		t.Fatalf("reported flush interval mismatch: have %v, want %v", status.FlushInterval, "1h30m0s")
+	}
+	for _, interval := range []string{"1s", "25h", "-1m", "soon"} {
+		if err := client.Call(nil, "admin_setTrieFlushInterval", interval); err == nil {
+			t.Errorf("expected error for interval %q", interval)
+		}
+	}
+	if have := chain.GetTrieFlushInterval(); have != 90*time.Minute {
+		t.Fatalf("flush interval changed by invalid calls: have %v", have)
+	}
+}
+
+func TestAdminRepairReceiptRange(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		genesis = &core.Genesis{
+			Config:  params.TestChainConfig,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		_, blocks, _ = core.GenerateChainWithGenesis(genesis, engine, 8, nil)
+	)
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), nil, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("admin", NewArbAdminAPI(chain, InfiniteMaxRecreateStateDepth)); err != nil {
+		t.Fatalf("failed to register api: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	if err := client.Call(nil, "admin_repairReceiptRange", "0x1", "0x4"); err == nil {
+		t.Fatal("repaired receipts without finalized block")
+	}
+	chain.SetFinalized(blocks[3].Header())
+	if err := client.Call(nil, "admin_repairReceiptRange", "0x1", "0x5"); err == nil {
+		t.Fatal("repaired receipts above finalized block")
+	}
+	if err := client.Call(nil, "admin_repairReceiptRange", "0x1", "0x4"); err != nil {
+		t.Fatalf("failed to repair receipts: %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -51,18 +52,137 @@ type APIBackend struct {
 	sync           SyncProgressBackend
 }
 
-type timeoutFallbackClient struct {
-	impl    types.FallbackClient
+// FallbackClientConfig configures how requests are forwarded to the fallback client.
+type FallbackClientConfig struct {
+	// Timeout is applied to every forwarded request (0 = no timeout).
+	Timeout time.Duration
+	// MethodTimeouts overrides Timeout for methods starting with the given
+	// prefix, the longest matching prefix wins (0 = no timeout).
+	MethodTimeouts map[string]time.Duration
+	// Retries is the number of times a failed idempotent read request is retried.
+	Retries int
+	// RetryBackoff is the delay before the first retry, doubled for every
+	// subsequent one.
+	RetryBackoff time.Duration
+}
+
+// ParseFallbackMethodTimeouts parses a list of "method-prefix=duration" entries.
+func ParseFallbackMethodTimeouts(entries []string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration, len(entries))
+	for _, entry := range entries {
+		prefix, value, found := strings.Cut(entry, "=")
+		if !found || prefix == "" {
+			return nil, fmt.Errorf("invalid fallback method timeout %q, expected method-prefix=duration", entry)
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fallback method timeout %q: %w", entry, err)
+		}
+		timeouts[prefix] = timeout
+	}
+	return timeouts, nil
+}
+
+type methodTimeout struct {
+	prefix  string
 	timeout time.Duration
 }
 
-func (c *timeoutFallbackClient) CallContext(ctxIn context.Context, result interface{}, method string, args ...interface{}) error {
-	ctx, cancel := context.WithTimeout(ctxIn, c.timeout)
+type timeoutFallbackClient struct {
+	impl           types.FallbackClient
+	timeout        time.Duration
+	methodTimeouts []methodTimeout // sorted by descending prefix length
+	retries        int
+	retryBackoff   time.Duration
+}
+
+func newTimeoutFallbackClient(impl types.FallbackClient, config *FallbackClientConfig) *timeoutFallbackClient {
+	client := &timeoutFallbackClient{
+		impl:         impl,
+		timeout:      config.Timeout,
+		retries:      config.Retries,
+		retryBackoff: config.RetryBackoff,
+	}
+	for prefix, timeout := range config.MethodTimeouts {
+		client.methodTimeouts = append(client.methodTimeouts, methodTimeout{prefix, timeout})
+	}
+	sort.Slice(client.methodTimeouts, func(i, j int) bool {
+		return len(client.methodTimeouts[i].prefix) > len(client.methodTimeouts[j].prefix)
+	})
+	return client
+}
+
+// timeoutFor returns the timeout to use for the given method.
+func (c *timeoutFallbackClient) timeoutFor(method string) time.Duration {
+	for _, override := range c.methodTimeouts {
+		if strings.HasPrefix(method, override.prefix) {
+			return override.timeout
+		}
+	}
+	return c.timeout
+}
+
+// nonIdempotentPrefixes lists the eth_ and net_ methods which change the state
+// of the remote node, and hence must never be retried.
+var nonIdempotentPrefixes = []string{
+	"eth_send", "eth_sign", "eth_submit", "eth_new", "eth_uninstall", "eth_subscribe", "eth_unsubscribe",
+}
+
+// isRetryableFallbackMethod returns whether the method is an idempotent read
+// which can be safely retried.
+func isRetryableFallbackMethod(method string) bool {
+	if !strings.HasPrefix(method, "eth_") && !strings.HasPrefix(method, "net_") {
+		return false
+	}
+	for _, prefix := range nonIdempotentPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *timeoutFallbackClient) call(ctxIn context.Context, timeout time.Duration, result interface{}, method string, args ...interface{}) error {
+	if timeout == 0 {
+		return c.impl.CallContext(ctxIn, result, method, args...)
+	}
+	ctx, cancel := context.WithTimeout(ctxIn, timeout)
 	defer cancel()
 	return c.impl.CallContext(ctx, result, method, args...)
 }
 
+func (c *timeoutFallbackClient) CallContext(ctxIn context.Context, result interface{}, method string, args ...interface{}) error {
+	timeout := c.timeoutFor(method)
+	err := c.call(ctxIn, timeout, result, method, args...)
+	if err == nil || c.retries == 0 || !isRetryableFallbackMethod(method) {
+		return err
+	}
+	backoff := c.retryBackoff
+	for i := 0; i < c.retries; i++ {
+		// Errors returned by the fallback node itself are final, only retry
+		// transport failures and timeouts.
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) || ctxIn.Err() != nil {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctxIn.Done():
+			return err
+		}
+		backoff *= 2
+		if err = c.call(ctxIn, timeout, result, method, args...); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
 func CreateFallbackClient(fallbackClientUrl string, fallbackClientTimeout time.Duration) (types.FallbackClient, error) {
+	return CreateFallbackClientWithConfig(fallbackClientUrl, &FallbackClientConfig{Timeout: fallbackClientTimeout})
+}
+
+func CreateFallbackClientWithConfig(fallbackClientUrl string, config *FallbackClientConfig) (types.FallbackClient, error) {
 	if fallbackClientUrl == "" {
 		return nil, nil
 	}
@@ -83,11 +203,8 @@ func CreateFallbackClient(fallbackClientUrl string, fallbackClientTimeout time.D
 	if err != nil {
 		return nil, fmt.Errorf("failed creating fallback connection: %w", err)
 	}
-	if fallbackClientTimeout != 0 {
-		fallbackClient = &timeoutFallbackClient{
-			impl:    fallbackClient,
-			timeout: fallbackClientTimeout,
-		}
+	if config.Timeout != 0 || len(config.MethodTimeouts) > 0 || config.Retries > 0 {
+		fallbackClient = newTimeoutFallbackClient(fallbackClient, config)
 	}
 	return fallbackClient, nil
 }
@@ -98,11 +215,14 @@ type SyncProgressBackend interface {
 	FinalizedBlockNumber(ctx context.Context) (uint64, error)
 }
 
-func createRegisterAPIBackend(backend *Backend, filterConfig filters.Config, fallbackClientUrl string, fallbackClientTimeout time.Duration) (*filters.FilterSystem, error) {
-	fallbackClient, err := CreateFallbackClient(fallbackClientUrl, fallbackClientTimeout)
+func createRegisterAPIBackend(backend *Backend, filterConfig filters.Config, fallbackClientUrl string, fallbackClientConfig *FallbackClientConfig) (*filters.FilterSystem, error) {
+	fallbackClient, err := CreateFallbackClientWithConfig(fallbackClientUrl, fallbackClientConfig)
 	if err != nil {
 		return nil, err
 	}
+	if fallbackClient != nil {
+		fallbackClient = newSanitizingFallbackClient(fallbackClient, backend.config.RPCGasCap, backend.config.RPCEVMTimeout)
+	}
 	// discard stylus-tag on any call made from api database
 	dbForAPICalls := backend.chainDb
 	wasmStore, tag := backend.chainDb.WasmDataBase()
@@ -158,6 +278,41 @@ func (a *APIBackend) GetAPIs(filterSystem *filters.FilterSystem) []rpc.API {
 		Public:    true,
 	})
 
+	apis = append(apis, rpc.API{
+		Namespace: "arb",
+		Service:   NewArbHeadsAPI(a),
+	})
+
+	apis = append(apis, rpc.API{
+		Namespace: "arb",
+		Service:   NewArbStorageGrowthAPI(a),
+	})
+
+	apis = append(apis, rpc.API{
+		Namespace: "arb",
+		Service:   NewArbMultiGasAPI(a),
+	})
+
+	apis = append(apis, rpc.API{
+		Namespace: "arb",
+		Service:   NewArbStateAvailabilityAPI(a),
+	})
+
+	apis = append(apis, rpc.API{
+		Namespace: "admin",
+		Service:   NewArbAdminAPI(a.BlockChain(), a.b.config.MaxRecreateStateDepth),
+	})
+
+	apis = append(apis, rpc.API{
+		Namespace: "admin",
+		Service:   NewArbSendTxAdminAPI(a.b),
+	})
+
+	apis = append(apis, rpc.API{
+		Namespace: "debug",
+		Service:   NewArbDebugAPI(a.BlockChain()),
+	})
+
 	apis = append(apis, tracers.APIs(a)...)
 
 	return apis
@@ -185,14 +340,30 @@ func (a *APIBackend) SyncProgressMap() map[string]interface{} {
 		res["error"] = "sync object not set in apibackend"
 		return res
 	}
-	return a.sync.SyncProgressMap()
+	progress := a.sync.SyncProgressMap()
+	// An empty map means synced, only report imports while syncing
+	if len(progress) > 0 {
+		progress["blockProcessing"] = a.BlockProcessing()
+	}
+	return progress
+}
+
+// BlockProcessing reports whether the blockchain is importing blocks or
+// receipts.
+func (a *APIBackend) BlockProcessing() bool {
+	return a.BlockChain().BlockProcessing()
 }
 
 func (a *APIBackend) SyncProgress() ethereum.SyncProgress {
 	progress := a.SyncProgressMap()
 
 	if len(progress) == 0 {
-		return ethereum.SyncProgress{}
+		var prog ethereum.SyncProgress
+		if txProg, err := a.BlockChain().TxIndexProgress(); err == nil {
+			prog.TxIndexFinishedBlocks = txProg.Indexed
+			prog.TxIndexRemainingBlocks = txProg.Remaining
+		}
+		return prog
 	}
 	return ethereum.SyncProgress{
 		CurrentBlock: 0,
@@ -292,12 +463,7 @@ func (a *APIBackend) FeeHistory(
 			currentTimestampGasUsed = 0
 		}
 
-		receipts := a.BlockChain().GetReceiptsByHash(header.Hash())
-		for _, receipt := range receipts {
-			if receipt.GasUsed > receipt.GasUsedForL1 {
-				currentTimestampGasUsed += receipt.GasUsed - receipt.GasUsedForL1
-			}
-		}
+		currentTimestampGasUsed += a.BlockChain().L2GasUsed(header.Hash(), block)
 
 		prevTimestamp = header.Time
 
@@ -371,19 +537,45 @@ func (a *APIBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types
 	return a.BlockChain().GetHeaderByHash(hash), nil
 }
 
+// unknownBlockError is returned when a block tag can't be resolved by the node,
+// letting clients fall back to the latest block.
+type unknownBlockError struct{ tag string }
+
+var (
+	ErrSafeBlockNotAvailable      error = &unknownBlockError{"safe"}
+	ErrFinalizedBlockNotAvailable error = &unknownBlockError{"finalized"}
+)
+
+func (e *unknownBlockError) Error() string  { return e.tag + " block not available" }
+func (e *unknownBlockError) ErrorCode() int { return -39001 } // Unknown block
+
+// errBlockNotYetAvailable is returned for block numbers above the current head.
+// It reads as the usual "header not found" to RPC clients, but lets callers tell
+// a block which wasn't produced yet apart from a missing one.
+var errBlockNotYetAvailable = errors.New("header not found")
+
+// checkBlockProduced returns errBlockNotYetAvailable if the block number is
+// above the current head.
+func (a *APIBackend) checkBlockProduced(number uint64) error {
+	if number > a.BlockChain().CurrentBlock().Number.Uint64() {
+		return errBlockNotYetAvailable
+	}
+	return nil
+}
+
 func (a *APIBackend) blockNumberToUint(ctx context.Context, number rpc.BlockNumber) (uint64, error) {
 	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
 		return a.BlockChain().CurrentBlock().Number.Uint64(), nil
 	}
 	if number == rpc.SafeBlockNumber {
 		if a.sync == nil {
-			return 0, errors.New("block number not supported: object not set")
+			return 0, ErrSafeBlockNotAvailable
 		}
 		return a.sync.SafeBlockNumber(ctx)
 	}
 	if number == rpc.FinalizedBlockNumber {
 		if a.sync == nil {
-			return 0, errors.New("block number not supported: object not set")
+			return 0, ErrFinalizedBlockNotAvailable
 		}
 		return a.sync.FinalizedBlockNumber(ctx)
 	}
@@ -401,6 +593,9 @@ func (a *APIBackend) headerByNumberImpl(ctx context.Context, number rpc.BlockNum
 	if err != nil {
 		return nil, err
 	}
+	if err := a.checkBlockProduced(numUint); err != nil {
+		return nil, err
+	}
 	return a.BlockChain().GetHeaderByNumber(numUint), nil
 }
 
@@ -441,6 +636,9 @@ func (a *APIBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber)
 	if err != nil {
 		return nil, err
 	}
+	if err := a.checkBlockProduced(numUint); err != nil {
+		return nil, err
+	}
 	return a.BlockChain().GetBlockByNumber(numUint), nil
 }
 
@@ -461,6 +659,13 @@ func (a *APIBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.
 }
 
 func StateAndHeaderFromHeader(ctx context.Context, chainDb ethdb.Database, bc *core.BlockChain, maxRecreateStateDepth int64, header *types.Header, err error) (*state.StateDB, *types.Header, error) {
+	return stateAndHeaderFromHeader(ctx, chainDb, bc, maxRecreateStateDepth, nil, header, err)
+}
+
+// stateAndHeaderFromHeader is StateAndHeaderFromHeader keeping some of the
+// intermediate states of the recreations in the given recreated states, if not
+// nil, and starting recreations from them.
+func stateAndHeaderFromHeader(ctx context.Context, chainDb ethdb.Database, bc *core.BlockChain, maxRecreateStateDepth int64, recreated *recreatedStates, header *types.Header, err error) (*state.StateDB, *types.Header, error) {
 	if err != nil {
 		return nil, header, err
 	}
@@ -502,8 +707,31 @@ func StateAndHeaderFromHeader(ctx context.Context, chainDb ethdb.Database, bc *c
 	// note: triedb cleans cache is disabled in trie.HashDefaults
 	// note: only states committed to diskdb can be found as we're creating new triedb
 	// note: snapshots are not used here
-	ephemeral := state.NewDatabaseWithConfig(chainDb, triedb.HashDefaults)
-	lastState, lastHeader, lastStateRelease, err := FindLastAvailableState(ctx, bc, stateFor(ephemeral, nil), header, nil, maxRecreateStateDepth)
+	// note: the recreated states share a database holding the ones kept
+	var (
+		ephemeral   state.Database
+		recreateFor StateForHeaderFunction
+	)
+	if recreated != nil {
+		ephemeral = recreated.db
+		// A kept state found as the base is pinned for the whole recreation
+		recreateFor = func(header *types.Header) (*state.StateDB, StateReleaseFunc, error) {
+			unpin, pinned := recreated.pin(header.Hash())
+			statedb, release, err := stateFor(ephemeral, nil)(header)
+			if !pinned {
+				return statedb, release, err
+			}
+			if err != nil {
+				unpin()
+				return nil, nil, err
+			}
+			return statedb, func() { release(); unpin() }, nil
+		}
+	} else {
+		ephemeral = state.NewDatabaseWithConfig(chainDb, triedb.HashDefaults)
+		recreateFor = stateFor(ephemeral, nil)
+	}
+	lastState, lastHeader, lastStateRelease, err := FindLastAvailableState(ctx, bc, recreateFor, header, nil, maxRecreateStateDepth)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -528,10 +756,42 @@ func StateAndHeaderFromHeader(ctx context.Context, chainDb ethdb.Database, bc *c
 	reexec := uint64(0)
 	checkLive := false
 	preferDisk := false // preferDisk is ignored in this case
-	statedb, release, err := eth.NewArbEthereum(bc, chainDb).StateAtBlock(ctx, targetBlock, reexec, lastState, lastBlock, checkLive, preferDisk)
+	arbEth := eth.NewArbEthereum(bc, chainDb)
+	if recreated != nil {
+		// Recreate up to each state to keep first, referencing it before
+		// dropping the reference of the recreation. If it can't be kept, the
+		// reference is held until the next state built on it has its own.
+		pendingRelease := func() {}
+		defer func() { pendingRelease() }()
+		for number := lastBlock.NumberU64() + 1; number < targetBlock.NumberU64(); number++ {
+			if !recreated.shouldKeep(number) {
+				continue
+			}
+			block := bc.GetBlockByNumber(number)
+			if block == nil {
+				return nil, nil, fmt.Errorf("block %d not found while recreating", number)
+			}
+			statedb, release, err := arbEth.StateAtBlock(ctx, block, reexec, lastState, lastBlock, checkLive, preferDisk)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to recreate state: %w", err)
+			}
+			pendingRelease()
+			if recreated.keep(block.Header()) {
+				release()
+				pendingRelease = func() {}
+			} else {
+				pendingRelease = release
+			}
+			lastState, lastBlock = statedb, block
+		}
+	}
+	statedb, release, err := arbEth.StateAtBlock(ctx, targetBlock, reexec, lastState, lastBlock, checkLive, preferDisk)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to recreate state: %w", err)
 	}
+	if recreated != nil && recreated.shouldKeep(targetBlock.NumberU64()) {
+		recreated.keep(targetBlock.Header())
+	}
 	// we are setting finalizer instead of returning a StateReleaseFunc to avoid changing ethapi.Backend interface to minimize diff to upstream
 	recreatedStatesReferencedCounter.Inc(1)
 	statedb.SetArbFinalizer(func(*state.ArbitrumExtraData) {
@@ -543,7 +803,7 @@ func StateAndHeaderFromHeader(ctx context.Context, chainDb ethdb.Database, bc *c
 
 func (a *APIBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
 	header, err := a.HeaderByNumber(ctx, number)
-	return StateAndHeaderFromHeader(ctx, a.ChainDb(), a.b.arb.BlockChain(), a.b.config.MaxRecreateStateDepth, header, err)
+	return stateAndHeaderFromHeader(ctx, a.ChainDb(), a.b.arb.BlockChain(), a.b.config.MaxRecreateStateDepth, a.b.recreatedStates, header, err)
 }
 
 func (a *APIBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
@@ -554,7 +814,7 @@ func (a *APIBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOr
 	if ishash && header != nil && header.Number.Cmp(bc.CurrentBlock().Number) > 0 && bc.GetCanonicalHash(header.Number.Uint64()) != hash {
 		return nil, nil, errors.New("requested block ahead of current block and the hash is not currently canonical")
 	}
-	return StateAndHeaderFromHeader(ctx, a.ChainDb(), a.b.arb.BlockChain(), a.b.config.MaxRecreateStateDepth, header, err)
+	return stateAndHeaderFromHeader(ctx, a.ChainDb(), a.b.arb.BlockChain(), a.b.config.MaxRecreateStateDepth, a.b.recreatedStates, header, err)
 }
 
 func (a *APIBackend) StateAtBlock(ctx context.Context, block *types.Block, reexec uint64, base *state.StateDB, checkLive bool, preferDisk bool) (statedb *state.StateDB, release tracers.StateReleaseFunc, err error) {
@@ -574,7 +834,13 @@ func (a *APIBackend) StateAtTransaction(ctx context.Context, block *types.Block,
 }
 
 func (a *APIBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
-	return a.BlockChain().GetReceiptsByHash(hash), nil
+	receipts := a.BlockChain().GetReceiptsByHash(hash)
+	if receipts == nil {
+		if number := rawdb.ReadHeaderNumber(a.ChainDb(), hash); number != nil {
+			return nil, a.BlockChain().CheckReceiptsPruned(*number)
+		}
+	}
+	return receipts, nil
 }
 
 func (a *APIBackend) GetTd(ctx context.Context, hash common.Hash) *big.Int {
@@ -610,6 +876,12 @@ func (a *APIBackend) SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) even
 	return a.BlockChain().SubscribeChainSideEvent(ch)
 }
 
+// SubscribeChainInsertEvent subscribes to the outcome of every block written
+// to the chain, telling canonical writes, reorgs and side blocks apart.
+func (a *APIBackend) SubscribeChainInsertEvent(ch chan<- core.ChainInsertEvent) event.Subscription {
+	return a.BlockChain().SubscribeChainInsertEvent(ch)
+}
+
 // Transaction pool API
 func (a *APIBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
 	return a.b.EnqueueL2Message(ctx, signedTx, nil)
@@ -625,13 +897,21 @@ func (a *APIBackend) GetTransaction(ctx context.Context, txHash common.Hash) (bo
 }
 
 func (a *APIBackend) GetPoolTransactions() (types.Transactions, error) {
-	// Arbitrum doesn't have a pool
-	return types.Transactions{}, nil
+	// Arbitrum doesn't have a pool, report the txs enqueued to the sequencer
+	stateDB, err := a.BlockChain().State()
+	if err != nil {
+		return nil, err
+	}
+	return a.b.pendingTxs.list(stateDB.GetNonce), nil
 }
 
 func (a *APIBackend) GetPoolTransaction(txHash common.Hash) *types.Transaction {
-	// Arbitrum doesn't have a pool
-	return nil
+	// Arbitrum doesn't have a pool, report the txs enqueued to the sequencer
+	stateDB, err := a.BlockChain().State()
+	if err != nil {
+		return nil
+	}
+	return a.b.pendingTxs.get(txHash, stateDB.GetNonce)
 }
 
 func (a *APIBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
@@ -639,7 +919,7 @@ func (a *APIBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uin
 	if err != nil {
 		return 0, err
 	}
-	return stateDB.GetNonce(addr), nil
+	return a.b.pendingNonces.get(addr, stateDB.GetNonce(addr)), nil
 }
 
 func (a *APIBackend) Stats() (pending int, queued int) {
@@ -665,7 +945,11 @@ func (a *APIBackend) BloomStatus() (uint64, uint64) {
 }
 
 func (a *APIBackend) GetLogs(ctx context.Context, hash common.Hash, number uint64) ([][]*types.Log, error) {
-	return rawdb.ReadLogs(a.ChainDb(), hash, number), nil
+	logs := rawdb.ReadLogs(a.ChainDb(), hash, number)
+	if logs == nil {
+		return nil, a.BlockChain().CheckReceiptsPruned(number)
+	}
+	return logs, nil
 }
 
 func (a *APIBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
@@ -687,6 +971,10 @@ func (a *APIBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent)
 	return a.BlockChain().SubscribeRemovedLogsEvent(ch)
 }
 
+func (a *APIBackend) SubscribeFinalizedHeaderEvent(ch chan<- core.FinalizedHeaderEvent) event.Subscription {
+	return a.BlockChain().SubscribeFinalizedHeaderEvent(ch)
+}
+
 func (a *APIBackend) ChainConfig() *params.ChainConfig {
 	return a.BlockChain().Config()
 }
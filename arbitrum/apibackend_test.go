@@ -0,0 +1,324 @@
+package arbitrum
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+var errFallbackTransport = errors.New("connection reset")
+
+type fallbackRPCError struct{}
+
+func (fallbackRPCError) Error() string  { return "execution reverted" }
+func (fallbackRPCError) ErrorCode() int { return 3 }
+
+// sleepyFallbackClient is a fake fallback client which takes a fixed time to
+// answer and fails a configurable number of initial calls.
+type sleepyFallbackClient struct {
+	delay    time.Duration
+	failures int
+	failWith error
+	calls    map[string]int
+}
+
+func (c *sleepyFallbackClient) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	if c.calls == nil {
+		c.calls = make(map[string]int)
+	}
+	c.calls[method]++
+	select {
+	case <-time.After(c.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if c.failures > 0 {
+		c.failures--
+		return c.failWith
+	}
+	return nil
+}
+
+func TestFallbackClientMethodTimeouts(t *testing.T) {
+	impl := &sleepyFallbackClient{delay: 100 * time.Millisecond}
+	client := newTimeoutFallbackClient(impl, &FallbackClientConfig{
+		Timeout: 20 * time.Millisecond,
+		MethodTimeouts: map[string]time.Duration{
+			"debug_":                 5 * time.Second,
+			"debug_traceTransaction": 0, // no timeout at all
+			"eth_":                   10 * time.Millisecond,
+		},
+	})
+	tests := []struct {
+		method  string
+		timeout time.Duration
+		fails   bool
+	}{
+		{"debug_traceBlockByHash", 5 * time.Second, false},
+		{"debug_traceTransaction", 0, false},
+		{"eth_chainId", 10 * time.Millisecond, true},
+		{"net_version", 20 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		if have := client.timeoutFor(tt.method); have != tt.timeout {
+			t.Errorf("%s: timeout mismatch: have %v, want %v", tt.method, have, tt.timeout)
+		}
+		err := client.CallContext(context.Background(), nil, tt.method)
+		if tt.fails && !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: expected deadline error, have %v", tt.method, err)
+		}
+		if !tt.fails && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.method, err)
+		}
+	}
+}
+
+func TestFallbackClientRetries(t *testing.T) {
+	config := &FallbackClientConfig{Retries: 3, RetryBackoff: time.Millisecond}
+
+	// Idempotent reads are retried until they succeed
+	impl := &sleepyFallbackClient{failures: 2, failWith: errFallbackTransport}
+	if err := newTimeoutFallbackClient(impl, config).CallContext(context.Background(), nil, "eth_getBalance"); err != nil {
+		t.Fatalf("expected retried call to succeed, have %v", err)
+	}
+	if have := impl.calls["eth_getBalance"]; have != 3 {
+		t.Fatalf("call count mismatch: have %d, want %d", have, 3)
+	}
+	// Retries are bounded
+	impl = &sleepyFallbackClient{failures: 10, failWith: errFallbackTransport}
+	if err := newTimeoutFallbackClient(impl, config).CallContext(context.Background(), nil, "net_version"); !errors.Is(err, errFallbackTransport) {
+		t.Fatalf("expected transport error, have %v", err)
+	}
+	if have := impl.calls["net_version"]; have != 4 {
+		t.Fatalf("call count mismatch: have %d, want %d", have, 4)
+	}
+	// Errors returned by the fallback node are final
+	impl = &sleepyFallbackClient{failures: 1, failWith: fallbackRPCError{}}
+	if err := newTimeoutFallbackClient(impl, config).CallContext(context.Background(), nil, "eth_call"); !errors.Is(err, fallbackRPCError{}) {
+		t.Fatalf("expected rpc error, have %v", err)
+	}
+	if have := impl.calls["eth_call"]; have != 1 {
+		t.Fatalf("call count mismatch: have %d, want %d", have, 1)
+	}
+	// Non-idempotent methods are never retried
+	for _, method := range []string{"eth_sendRawTransaction", "eth_sendRawTransactionConditional", "debug_traceTransaction"} {
+		impl = &sleepyFallbackClient{failures: 1, failWith: errFallbackTransport}
+		if err := newTimeoutFallbackClient(impl, config).CallContext(context.Background(), nil, method); !errors.Is(err, errFallbackTransport) {
+			t.Fatalf("%s: expected transport error, have %v", method, err)
+		}
+		if have := impl.calls[method]; have != 1 {
+			t.Fatalf("%s: call count mismatch: have %d, want %d", method, have, 1)
+		}
+	}
+}
+
+func TestParseFallbackMethodTimeouts(t *testing.T) {
+	timeouts, err := ParseFallbackMethodTimeouts([]string{"debug_=10m", "eth_chainId=50ms"})
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if timeouts["debug_"] != 10*time.Minute || timeouts["eth_chainId"] != 50*time.Millisecond {
+		t.Fatalf("unexpected timeouts: %v", timeouts)
+	}
+	for _, invalid := range []string{"debug_", "=1s", "eth_=forever"} {
+		if _, err := ParseFallbackMethodTimeouts([]string{invalid}); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestUnknownBlockTagErrors(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		genesis = &core.Genesis{
+			Config:  params.TestChainConfig,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+	)
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), nil, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	call := func(sync SyncProgressBackend, tag string) error {
+		backend := &APIBackend{
+			b:    &Backend{arb: &stubArbInterface{chain: chain}},
+			sync: sync,
+		}
+		server := rpc.NewServer()
+		defer server.Stop()
+		if err := server.RegisterName("eth", ethapi.NewBlockChainAPI(backend)); err != nil {
+			t.Fatalf("failed to register api: %v", err)
+		}
+		client := rpc.DialInProc(server)
+		defer client.Close()
+		var block map[string]interface{}
+		return client.Call(&block, "eth_getBlockByNumber", tag, false)
+	}
+	for _, tag := range []string{"safe", "finalized"} {
+		err := call(nil, tag)
+		var rpcErr rpc.Error
+		if !errors.As(err, &rpcErr) {
+			t.Fatalf("%s without sync backend: expected rpc error, got %v", tag, err)
+		}
+		if rpcErr.ErrorCode() != -39001 {
+			t.Errorf("%s without sync backend: error code mismatch: have %d, want %d", tag, rpcErr.ErrorCode(), -39001)
+		}
+		if err := call(&stubSyncBackend{}, tag); err != nil {
+			t.Errorf("%s with sync backend: unexpected error: %v", tag, err)
+		}
+	}
+}
+
+func TestBlockNotYetAvailable(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		config  = *params.TestChainConfig
+		genesis = &core.Genesis{
+			Config:  &config,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+	)
+	config.ArbitrumChainParams.EnableArbOS = true
+	_, blocks, _ := core.GenerateChainWithGenesis(genesis, engine, 3, nil)
+
+	db := rawdb.NewMemoryDatabase()
+	genesis.MustCommit(db, triedb.NewDatabase(db, triedb.HashDefaults))
+	chain, err := core.NewBlockChain(db, nil, &config, nil, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	arbConfig := DefaultConfig
+	backend := &APIBackend{
+		b: &Backend{arb: &stubArbInterface{chain: chain}, config: &arbConfig, chainDb: db},
+	}
+	ctx := context.Background()
+
+	// Blocks up to the head are served as usual
+	if block, err := backend.BlockByNumber(ctx, 3); err != nil || block == nil {
+		t.Fatalf("failed to get head block: %v", err)
+	}
+	if header, err := backend.HeaderByNumber(ctx, 3); err != nil || header == nil {
+		t.Fatalf("failed to get head header: %v", err)
+	}
+	if statedb, _, err := backend.StateAndHeaderByNumber(ctx, 3); err != nil || statedb == nil {
+		t.Fatalf("failed to get head state: %v", err)
+	}
+	// Blocks above the head are reported as not yet available
+	if _, err := backend.BlockByNumber(ctx, 4); !errors.Is(err, errBlockNotYetAvailable) {
+		t.Errorf("block above head: have %v, want %v", err, errBlockNotYetAvailable)
+	}
+	if _, err := backend.HeaderByNumber(ctx, 4); !errors.Is(err, errBlockNotYetAvailable) {
+		t.Errorf("header above head: have %v, want %v", err, errBlockNotYetAvailable)
+	}
+	if _, _, err := backend.StateAndHeaderByNumberOrHash(ctx, rpc.BlockNumberOrHashWithNumber(4)); !errors.Is(err, errBlockNotYetAvailable) {
+		t.Errorf("state above head: have %v, want %v", err, errBlockNotYetAvailable)
+	}
+	// And reach RPC clients as the usual header not found error
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", ethapi.NewBlockChainAPI(backend)); err != nil {
+		t.Fatalf("failed to register api: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+	var block map[string]interface{}
+	if err := client.Call(&block, "eth_getBlockByNumber", "0x4", false); err == nil || err.Error() != "header not found" {
+		t.Errorf("rpc error mismatch: have %v, want header not found", err)
+	}
+}
+
+// BenchmarkFeeHistory measures serving a full fee history window, with the L2
+// gas used of the blocks stored and derived from their receipts.
+func BenchmarkFeeHistory(b *testing.B) {
+	var (
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		engine  = ethash.NewFaker()
+		config  = *params.TestChainConfig
+		genesis = &core.Genesis{
+			Config:  &config,
+			Alloc:   types.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		signer      = types.LatestSigner(genesis.Config)
+		cacheConfig = *core.DefaultCacheConfigWithScheme(rawdb.HashScheme)
+		arbConfig   = DefaultConfig
+	)
+	config.ArbitrumChainParams.EnableArbOS = true
+	cacheConfig.TrieDirtyDisabled = true // archive, so the head state survives reopening
+	_, blocks, _ := core.GenerateChainWithGenesis(genesis, engine, int(arbConfig.FeeHistoryMaxBlockCount), func(i int, b *core.BlockGen) {
+		for j := 0; j < i%4; j++ {
+			tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+				Nonce:    b.TxNonce(address),
+				To:       &common.Address{0x01},
+				Value:    big.NewInt(1),
+				Gas:      params.TxGas,
+				GasPrice: b.BaseFee(),
+			})
+			b.AddTx(tx)
+		}
+	})
+	db := rawdb.NewMemoryDatabase()
+	genesis.MustCommit(db, triedb.NewDatabase(db, triedb.HashDefaults))
+	chain, err := core.NewBlockChain(db, &cacheConfig, &config, nil, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		b.Fatalf("failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		b.Fatalf("failed to insert chain: %v", err)
+	}
+	chain.Stop()
+
+	speedLimit := core.GetArbOSSpeedLimitPerSecond
+	core.GetArbOSSpeedLimitPerSecond = func(*state.StateDB) (uint64, error) { return 7_000_000, nil }
+	defer func() { core.GetArbOSSpeedLimitPerSecond = speedLimit }()
+
+	run := func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			// Open the chain anew so that nothing is served from its caches
+			b.StopTimer()
+			chain, err := core.NewBlockChain(db, &cacheConfig, &config, nil, nil, engine, vm.Config{}, nil, nil)
+			if err != nil {
+				b.Fatalf("failed to create chain: %v", err)
+			}
+			backend := &APIBackend{
+				b:             &Backend{arb: &stubArbInterface{chain: chain}, config: &arbConfig, chainDb: db},
+				dbForAPICalls: db,
+			}
+			b.StartTimer()
+
+			if _, _, _, _, _, _, err := backend.FeeHistory(context.Background(), arbConfig.FeeHistoryMaxBlockCount, rpc.LatestBlockNumber, nil); err != nil {
+				b.Fatalf("failed to get fee history: %v", err)
+			}
+			b.StopTimer()
+			chain.Stop()
+			b.StartTimer()
+		}
+	}
+	b.Run("stored", run)
+	for _, block := range blocks {
+		rawdb.DeleteL2GasUsed(db, block.Hash(), block.NumberU64())
+	}
+	b.Run("receipts", run)
+}
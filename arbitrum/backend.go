@@ -5,6 +5,8 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/arbitrum_types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
@@ -36,6 +38,14 @@ type Backend struct {
 	chanClose    chan struct{} //close coroutine
 	chanNewBlock chan struct{} //create new L2 block unless empty
 
+	pendingNonces *pendingNonces // nonces of enqueued but not yet included txs
+	pendingTxs    *pendingTxs    // enqueued but not yet included txs
+	sendTxLimiter *sendTxLimiter // bounds the txs being enqueued at once
+
+	recreatedStates *recreatedStates // states kept from recreations, nil if not enabled
+
+	multiGasPricer MultiGasPricer // reads the per-dimension pricing of ArbOS, set by the node
+
 	filterSystem *filters.FilterSystem
 }
 
@@ -54,6 +64,14 @@ func NewBackend(stack *node.Node, config *Config, chainDb ethdb.Database, publis
 		chanTxs:      make(chan *types.Transaction, 100),
 		chanClose:    make(chan struct{}),
 		chanNewBlock: make(chan struct{}, 1),
+
+		pendingNonces: newPendingNonces(mclock.System{}, pendingNonceTimeout, pendingNonceLimit),
+		pendingTxs:    newPendingTxs(mclock.System{}, pendingTxTimeout, pendingTxLimit),
+		sendTxLimiter: newSendTxLimiter(config.SendTxMaxInFlight, config.SendTxMaxInFlightPerSender, config.SendTxRetryAfter),
+	}
+
+	if config.MaxRecreateStatePersistInterval > 0 && config.MaxRecreateStatePersistCount > 0 {
+		backend.recreatedStates = newRecreatedStates(chainDb, config.MaxRecreateStatePersistInterval, config.MaxRecreateStatePersistCount)
 	}
 
 	if len(config.AllowMethod) > 0 {
@@ -65,7 +83,11 @@ func NewBackend(stack *node.Node, config *Config, chainDb ethdb.Database, publis
 	}
 
 	backend.bloomIndexer.Start(backend.arb.BlockChain())
-	filterSystem, err := createRegisterAPIBackend(backend, filterConfig, config.ClassicRedirect, config.ClassicRedirectTimeout)
+	fallbackClientConfig, err := config.FallbackClientConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	filterSystem, err := createRegisterAPIBackend(backend, filterConfig, config.ClassicRedirect, fallbackClientConfig)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -88,7 +110,30 @@ func (b *Backend) ResetWithGenesisBlock(gb *types.Block) {
 }
 
 func (b *Backend) EnqueueL2Message(ctx context.Context, tx *types.Transaction, options *arbitrum_types.ConditionalOptions) error {
-	return b.arb.PublishTransaction(ctx, tx, options)
+	sender, senderErr := types.Sender(types.LatestSigner(b.arb.BlockChain().Config()), tx)
+	if b.sendTxLimiter != nil {
+		// Don't lump transactions with unrecoverable senders together under
+		// the zero address; they only count towards the overall limit.
+		var limited *common.Address
+		if senderErr == nil {
+			limited = &sender
+		}
+		release, err := b.sendTxLimiter.acquire(limited)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	if err := b.arb.PublishTransaction(ctx, tx, options); err != nil {
+		return err
+	}
+	if senderErr == nil {
+		b.pendingNonces.add(sender, tx.Nonce())
+		// Make the transaction visible as pending before announcing it
+		b.pendingTxs.add(tx, sender)
+		b.txFeed.Send(core.NewTxsEvent{Txs: []*types.Transaction{tx}})
+	}
+	return nil
 }
 
 func (b *Backend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
@@ -100,6 +145,7 @@ func (b *Backend) Start() error {
 	b.startBloomHandlers(b.config.BloomBitsBlocks)
 	b.shutdownTracker.MarkStartup()
 	b.shutdownTracker.Start()
+	go b.prunePendingNonces()
 
 	return nil
 }
@@ -108,6 +154,9 @@ func (b *Backend) Stop() error {
 	b.scope.Close()
 	b.bloomIndexer.Close()
 	b.shutdownTracker.Stop()
+	if b.recreatedStates != nil {
+		b.recreatedStates.close()
+	}
 	b.chainDb.Close()
 	close(b.chanClose)
 	return nil
@@ -34,11 +34,39 @@ type Config struct {
 
 	ArbDebug ArbDebugConfig `koanf:"arbdebug"`
 
-	ClassicRedirect        string        `koanf:"classic-redirect"`
-	ClassicRedirectTimeout time.Duration `koanf:"classic-redirect-timeout"`
-	MaxRecreateStateDepth  int64         `koanf:"max-recreate-state-depth"`
+	ClassicRedirect              string        `koanf:"classic-redirect"`
+	ClassicRedirectTimeout       time.Duration `koanf:"classic-redirect-timeout"`
+	ClassicRedirectMethodTimeout []string      `koanf:"classic-redirect-method-timeout"`
+	ClassicRedirectRetries       int           `koanf:"classic-redirect-retries"`
+	ClassicRedirectRetryBackoff  time.Duration `koanf:"classic-redirect-retry-backoff"`
+	MaxRecreateStateDepth        int64         `koanf:"max-recreate-state-depth"`
+
+	// Intermediate states kept in memory when recreating states, so that
+	// nearby queries can start from them (0 = don't keep)
+	MaxRecreateStatePersistInterval uint64 `koanf:"max-recreate-state-persist-interval"`
+	MaxRecreateStatePersistCount    int    `koanf:"max-recreate-state-persist-count"`
 
 	AllowMethod []string `koanf:"allow-method"`
+
+	// Bounds on the transactions being enqueued to the sequencer at once, beyond
+	// which they are rejected as backlogged (0 = no limit)
+	SendTxMaxInFlight          int           `koanf:"send-tx-max-in-flight"`
+	SendTxMaxInFlightPerSender int           `koanf:"send-tx-max-in-flight-per-sender"`
+	SendTxRetryAfter           time.Duration `koanf:"send-tx-retry-after"`
+}
+
+// FallbackClientConfig returns the configuration of the classic redirect client.
+func (c *Config) FallbackClientConfig() (*FallbackClientConfig, error) {
+	methodTimeouts, err := ParseFallbackMethodTimeouts(c.ClassicRedirectMethodTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &FallbackClientConfig{
+		Timeout:        c.ClassicRedirectTimeout,
+		MethodTimeouts: methodTimeouts,
+		Retries:        c.ClassicRedirectRetries,
+		RetryBackoff:   c.ClassicRedirectRetryBackoff,
+	}, nil
 }
 
 type ArbDebugConfig struct {
@@ -56,10 +84,18 @@ func ConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Uint64(prefix+".feehistory-max-block-count", DefaultConfig.FeeHistoryMaxBlockCount, "max number of blocks a fee history request may cover")
 	f.String(prefix+".classic-redirect", DefaultConfig.ClassicRedirect, "url to redirect classic requests, use \"error:[CODE:]MESSAGE\" to return specified error instead of redirecting")
 	f.Duration(prefix+".classic-redirect-timeout", DefaultConfig.ClassicRedirectTimeout, "timeout for forwarded classic requests, where 0 = no timeout")
+	f.StringSlice(prefix+".classic-redirect-method-timeout", DefaultConfig.ClassicRedirectMethodTimeout, "per-method timeout overrides for forwarded classic requests, as method-prefix=duration (e.g. debug_=10m), where 0 = no timeout")
+	f.Int(prefix+".classic-redirect-retries", DefaultConfig.ClassicRedirectRetries, "number of retries for failed idempotent eth_/net_ classic requests")
+	f.Duration(prefix+".classic-redirect-retry-backoff", DefaultConfig.ClassicRedirectRetryBackoff, "delay before the first retry of a classic request, doubled for each subsequent retry")
 	f.Int(prefix+".filter-log-cache-size", DefaultConfig.FilterLogCacheSize, "log filter system maximum number of cached blocks")
 	f.Duration(prefix+".filter-timeout", DefaultConfig.FilterTimeout, "log filter system maximum time filters stay active")
 	f.Int64(prefix+".max-recreate-state-depth", DefaultConfig.MaxRecreateStateDepth, "maximum depth for recreating state, measured in l2 gas (0=don't recreate state, -1=infinite, -2=use default value for archive or non-archive node (whichever is configured))")
+	f.Uint64(prefix+".max-recreate-state-persist-interval", DefaultConfig.MaxRecreateStatePersistInterval, "interval in blocks at which intermediate states built while recreating state are kept in memory, so that nearby queries can start from them (0 = don't keep)")
+	f.Int(prefix+".max-recreate-state-persist-count", DefaultConfig.MaxRecreateStatePersistCount, "maximum number of intermediate recreated states kept in memory, evicting the least recently used (bounds the count, not the memory used by the states)")
 	f.StringSlice(prefix+".allow-method", DefaultConfig.AllowMethod, "list of whitelisted rpc methods")
+	f.Int(prefix+".send-tx-max-in-flight", DefaultConfig.SendTxMaxInFlight, "maximum number of transactions being enqueued to the sequencer at once (0 = no limit)")
+	f.Int(prefix+".send-tx-max-in-flight-per-sender", DefaultConfig.SendTxMaxInFlightPerSender, "maximum number of transactions from a single sender being enqueued to the sequencer at once (0 = no limit)")
+	f.Duration(prefix+".send-tx-retry-after", DefaultConfig.SendTxRetryAfter, "retry hint returned with transactions rejected because the sequencer is backlogged")
 	arbDebug := DefaultConfig.ArbDebug
 	f.Uint64(prefix+".arbdebug.block-range-bound", arbDebug.BlockRangeBound, "bounds the number of blocks arbdebug calls may return")
 	f.Uint64(prefix+".arbdebug.timeout-queue-bound", arbDebug.TimeoutQueueBound, "bounds the length of timeout queues arbdebug calls may return")
@@ -89,4 +125,7 @@ var DefaultConfig = Config{
 		BlockRangeBound:   256,
 		TimeoutQueueBound: 512,
 	},
+	ClassicRedirectRetryBackoff:  100 * time.Millisecond,
+	SendTxRetryAfter:             time.Second,
+	MaxRecreateStatePersistCount: 16,
 }
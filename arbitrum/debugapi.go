@@ -0,0 +1,60 @@
+package arbitrum
+
+import (
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// ArbDebugAPI offers debugging RPC methods specific to Arbitrum nodes.
+type ArbDebugAPI struct {
+	blockchain *core.BlockChain
+}
+
+// NewArbDebugAPI creates a new debug API instance.
+func NewArbDebugAPI(blockchain *core.BlockChain) *ArbDebugAPI {
+	return &ArbDebugAPI{blockchain}
+}
+
+// TrieDatabaseStats is the result of debug_trieDatabaseStats. The sizes are in
+// bytes, the limits are those the node is configured with so that the usage
+// can be related to them.
+type TrieDatabaseStats struct {
+	Scheme        string         `json:"scheme"`
+	Dirties       hexutil.Uint64 `json:"dirties"`
+	Diffs         hexutil.Uint64 `json:"diffs"`
+	Cleans        hexutil.Uint64 `json:"cleans"`
+	Preimages     hexutil.Uint64 `json:"preimages"`
+	TrieGCEntries hexutil.Uint64 `json:"trieGCEntries"`
+	HistoryItems  hexutil.Uint64 `json:"historyItems"`
+	HistorySize   hexutil.Uint64 `json:"historySize"`
+
+	CleanLimit    hexutil.Uint64 `json:"cleanLimit"`
+	DirtyLimit    hexutil.Uint64 `json:"dirtyLimit"`
+	TriesInMemory hexutil.Uint64 `json:"triesInMemory"`
+	StateHistory  hexutil.Uint64 `json:"stateHistory"`
+}
+
+// TrieDatabaseStats returns the memory held by the trie database: the dirty
+// nodes or node buffer, the diff layers, the clean cache and the preimages,
+// along with the tries awaiting garbage collection for the hash scheme and the
+// state histories for the path scheme.
+func (api *ArbDebugAPI) TrieDatabaseStats() (*TrieDatabaseStats, error) {
+	stats, err := api.blockchain.TrieDatabaseStats()
+	if err != nil {
+		return nil, err
+	}
+	return &TrieDatabaseStats{
+		Scheme:        stats.Scheme,
+		Dirties:       hexutil.Uint64(stats.Dirties),
+		Diffs:         hexutil.Uint64(stats.Diffs),
+		Cleans:        hexutil.Uint64(stats.Cleans),
+		Preimages:     hexutil.Uint64(stats.Preimages),
+		TrieGCEntries: hexutil.Uint64(stats.TrieGCEntries),
+		HistoryItems:  hexutil.Uint64(stats.HistoryItems),
+		HistorySize:   hexutil.Uint64(stats.HistorySize),
+		CleanLimit:    hexutil.Uint64(stats.CleanLimit),
+		DirtyLimit:    hexutil.Uint64(stats.DirtyLimit),
+		TriesInMemory: hexutil.Uint64(stats.TriesInMemory),
+		StateHistory:  hexutil.Uint64(stats.StateHistory),
+	}, nil
+}
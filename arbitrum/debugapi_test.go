@@ -0,0 +1,105 @@
+package arbitrum
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestDebugTrieDatabaseStats(t *testing.T) {
+	testDebugTrieDatabaseStats(t, rawdb.HashScheme)
+	testDebugTrieDatabaseStats(t, rawdb.PathScheme)
+}
+
+func testDebugTrieDatabaseStats(t *testing.T, scheme string) {
+	var (
+		engine  = ethash.NewFaker()
+		genesis = &core.Genesis{
+			Config:  params.TestChainConfig,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		cacheConfig = core.DefaultCacheConfigWithScheme(scheme)
+		// Enough blocks for the path scheme to flush some diff layers
+		_, blocks, _ = core.GenerateChainWithGenesis(genesis, engine, 160, nil)
+	)
+	// The path scheme only keeps state histories with an ancient store
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("%s: failed to create database: %v", scheme, err)
+	}
+	defer db.Close()
+	chain, err := core.NewBlockChain(db, cacheConfig, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("%s: failed to create chain: %v", scheme, err)
+	}
+	defer chain.Stop()
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("debug", NewArbDebugAPI(chain)); err != nil {
+		t.Fatalf("%s: failed to register api: %v", scheme, err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	// Import blocks while querying the stats
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, block := range blocks {
+			if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+				t.Errorf("%s: failed to insert block %d: %v", scheme, block.NumberU64(), err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 32; i++ {
+		var stats TrieDatabaseStats
+		if err := client.Call(&stats, "debug_trieDatabaseStats"); err != nil {
+			t.Fatalf("%s: failed to get trie database stats: %v", scheme, err)
+		}
+	}
+	wg.Wait()
+
+	var stats TrieDatabaseStats
+	if err := client.Call(&stats, "debug_trieDatabaseStats"); err != nil {
+		t.Fatalf("%s: failed to get trie database stats: %v", scheme, err)
+	}
+	if stats.Scheme != scheme {
+		t.Errorf("scheme mismatch: have %s, want %s", stats.Scheme, scheme)
+	}
+	if uint64(stats.CleanLimit) != uint64(cacheConfig.TrieCleanLimit)*1024*1024 || uint64(stats.DirtyLimit) != uint64(cacheConfig.TrieDirtyLimit)*1024*1024 {
+		t.Errorf("%s: limits mismatch: have %d/%d", scheme, stats.CleanLimit, stats.DirtyLimit)
+	}
+	if uint64(stats.TriesInMemory) != cacheConfig.TriesInMemory {
+		t.Errorf("%s: tries in memory mismatch: have %d, want %d", scheme, stats.TriesInMemory, cacheConfig.TriesInMemory)
+	}
+	switch scheme {
+	case rawdb.HashScheme:
+		if stats.Dirties == 0 || stats.TrieGCEntries == 0 {
+			t.Errorf("hash scheme: missing dirty nodes: %+v", stats)
+		}
+		if stats.Diffs != 0 || stats.HistoryItems != 0 {
+			t.Errorf("hash scheme: unexpected path scheme stats: %+v", stats)
+		}
+	case rawdb.PathScheme:
+		if stats.Diffs == 0 || stats.Dirties == 0 {
+			t.Errorf("path scheme: missing diff layers or node buffer: %+v", stats)
+		}
+		if stats.HistoryItems == 0 || stats.HistorySize == 0 {
+			t.Errorf("path scheme: missing state histories: %+v", stats)
+		}
+		if stats.TrieGCEntries != 0 {
+			t.Errorf("path scheme: unexpected hash scheme stats: %+v", stats)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package arbitrum
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+)
+
+// sanitizingFallbackClient applies our own RPC limits to the execution requests
+// forwarded to the fallback client, so they can't be bypassed by targeting
+// pre-Nitro blocks.
+type sanitizingFallbackClient struct {
+	impl    types.FallbackClient
+	gasCap  uint64        // RPCGasCap, 0 = no cap
+	timeout time.Duration // RPCEVMTimeout, 0 = no timeout
+}
+
+func newSanitizingFallbackClient(impl types.FallbackClient, gasCap uint64, timeout time.Duration) *sanitizingFallbackClient {
+	return &sanitizingFallbackClient{
+		impl:    impl,
+		gasCap:  gasCap,
+		timeout: timeout,
+	}
+}
+
+// isExecutionMethod returns whether the method executes the transaction passed
+// as its first argument.
+func isExecutionMethod(method string) bool {
+	switch method {
+	case "eth_call", "eth_estimateGas", "eth_createAccessList":
+		return true
+	}
+	return false
+}
+
+// sanitizeArgs returns a copy of the transaction arguments with the gas clamped
+// to the gas cap and the fields unknown to pre-Nitro nodes removed.
+func (c *sanitizingFallbackClient) sanitizeArgs(args ethapi.TransactionArgs) ethapi.TransactionArgs {
+	if c.gasCap != 0 && (args.Gas == nil || uint64(*args.Gas) > c.gasCap) {
+		gas := hexutil.Uint64(c.gasCap)
+		args.Gas = &gas
+	}
+	args.SkipL1Charging = nil
+	args.BlobFeeCap = nil
+	args.BlobHashes = nil
+	args.Blobs = nil
+	args.Commitments = nil
+	args.Proofs = nil
+	return args
+}
+
+func (c *sanitizingFallbackClient) CallContext(ctxIn context.Context, result interface{}, method string, args ...interface{}) error {
+	if !isExecutionMethod(method) || len(args) == 0 {
+		return c.impl.CallContext(ctxIn, result, method, args...)
+	}
+	// Don't bother the fallback node with requests we gave up on already
+	if err := ctxIn.Err(); err != nil {
+		return err
+	}
+	sanitized := make([]interface{}, len(args))
+	copy(sanitized, args)
+	switch txArgs := args[0].(type) {
+	case ethapi.TransactionArgs:
+		sanitized[0] = c.sanitizeArgs(txArgs)
+	case *ethapi.TransactionArgs:
+		if txArgs != nil {
+			clean := c.sanitizeArgs(*txArgs)
+			sanitized[0] = &clean
+		}
+	}
+	// The eth_ methods have no timeout parameter, the remaining deadline is
+	// carried to the fallback node by the request context, capped by our own
+	// execution timeout.
+	ctx := ctxIn
+	if c.timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctxIn, c.timeout)
+		defer cancel()
+	}
+	return c.impl.CallContext(ctx, result, method, sanitized...)
+}
@@ -0,0 +1,135 @@
+package arbitrum
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// recordingFallbackClient is a fake fallback client remembering the last
+// request it was asked to forward.
+type recordingFallbackClient struct {
+	method   string
+	args     []interface{}
+	deadline time.Time
+	calls    int
+}
+
+func (c *recordingFallbackClient) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	c.method = method
+	c.args = args
+	c.deadline, _ = ctx.Deadline()
+	c.calls++
+	return nil
+}
+
+func TestSanitizingFallbackClientGasCap(t *testing.T) {
+	var (
+		impl     = new(recordingFallbackClient)
+		client   = newSanitizingFallbackClient(impl, 50_000_000, 0)
+		to       = common.HexToAddress("0x01")
+		skip     = true
+		blockNum = rpc.BlockNumberOrHashWithNumber(1)
+	)
+	u64 := func(n uint64) *hexutil.Uint64 { return (*hexutil.Uint64)(&n) }
+
+	tests := []struct {
+		gas  *hexutil.Uint64
+		want uint64
+	}{
+		{nil, 50_000_000},
+		{u64(21000), 21000},
+		{u64(50_000_000), 50_000_000},
+		{u64(1 << 40), 50_000_000},
+	}
+	for i, tt := range tests {
+		args := ethapi.TransactionArgs{
+			To:             &to,
+			Gas:            tt.gas,
+			SkipL1Charging: &skip,
+			BlobFeeCap:     (*hexutil.Big)(common.Big1),
+			BlobHashes:     []common.Hash{{0x01}},
+		}
+		if err := client.CallContext(context.Background(), nil, "eth_call", args, &blockNum, nil); err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if len(impl.args) != 3 {
+			t.Fatalf("test %d: forwarded argument count mismatch: have %d, want 3", i, len(impl.args))
+		}
+		forwarded, ok := impl.args[0].(ethapi.TransactionArgs)
+		if !ok {
+			t.Fatalf("test %d: unexpected forwarded args type %T", i, impl.args[0])
+		}
+		if forwarded.Gas == nil || uint64(*forwarded.Gas) != tt.want {
+			t.Errorf("test %d: forwarded gas mismatch: have %v, want %d", i, forwarded.Gas, tt.want)
+		}
+		if forwarded.SkipL1Charging != nil || forwarded.BlobFeeCap != nil || forwarded.BlobHashes != nil {
+			t.Errorf("test %d: unsupported fields forwarded: %+v", i, forwarded)
+		}
+		if *forwarded.To != to || impl.args[1] != &blockNum {
+			t.Errorf("test %d: request mangled: %+v", i, impl.args)
+		}
+		// The caller's arguments must be left alone
+		if args.Gas != tt.gas || args.SkipL1Charging == nil {
+			t.Errorf("test %d: caller arguments modified", i)
+		}
+	}
+	// Pointer arguments are sanitized as well
+	args := &ethapi.TransactionArgs{Gas: u64(1 << 40)}
+	if err := client.CallContext(context.Background(), nil, "eth_estimateGas", args); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if forwarded := impl.args[0].(*ethapi.TransactionArgs); uint64(*forwarded.Gas) != 50_000_000 || uint64(*args.Gas) != 1<<40 {
+		t.Errorf("pointer args not sanitized: forwarded %v, original %v", *forwarded.Gas, *args.Gas)
+	}
+	// Other methods are forwarded verbatim
+	if err := client.CallContext(context.Background(), nil, "eth_getBalance", to, &blockNum); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if impl.method != "eth_getBalance" || impl.args[0] != to {
+		t.Errorf("non-execution request mangled: %s %v", impl.method, impl.args)
+	}
+}
+
+func TestSanitizingFallbackClientDeadline(t *testing.T) {
+	impl := new(recordingFallbackClient)
+
+	// Without our own timeout, the caller's deadline is passed on as is
+	client := newSanitizingFallbackClient(impl, 0, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	callerDeadline, _ := ctx.Deadline()
+	if err := client.CallContext(ctx, nil, "eth_call", ethapi.TransactionArgs{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !impl.deadline.Equal(callerDeadline) {
+		t.Errorf("deadline mismatch: have %v, want %v", impl.deadline, callerDeadline)
+	}
+	if impl.args[0].(ethapi.TransactionArgs).Gas != nil {
+		t.Errorf("gas set without a gas cap")
+	}
+	// The execution timeout shortens a later deadline
+	client = newSanitizingFallbackClient(impl, 0, time.Second)
+	if err := client.CallContext(ctx, nil, "eth_call", ethapi.TransactionArgs{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if impl.deadline.IsZero() || !impl.deadline.Before(callerDeadline) || time.Until(impl.deadline) > time.Second {
+		t.Errorf("execution timeout not applied: deadline %v", impl.deadline)
+	}
+	// Expired requests are not forwarded at all
+	calls := impl.calls
+	expired, cancelExpired := context.WithCancel(context.Background())
+	cancelExpired()
+	if err := client.CallContext(expired, nil, "eth_call", ethapi.TransactionArgs{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation error, have %v", err)
+	}
+	if impl.calls != calls {
+		t.Errorf("expired request forwarded")
+	}
+}
@@ -0,0 +1,149 @@
+package arbitrum
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// blockMetadataRetryDelay is how long to wait before asking again for the
+// metadata of a new head that wasn't available yet.
+const blockMetadataRetryDelay = 200 * time.Millisecond
+
+// BlockMetadataBackend is optionally implemented by the SyncProgressBackend to
+// serve the Arbitrum block metadata recorded for a block.
+type BlockMetadataBackend interface {
+	BlockMetadataByNumber(ctx context.Context, blockNum uint64) (hexutil.Bytes, error)
+}
+
+// ArbHeadsAPI offers Arbitrum specific chain head subscriptions
+type ArbHeadsAPI struct {
+	b          *APIBackend
+	retryDelay time.Duration
+}
+
+// NewArbHeadsAPI creates a new heads API instance.
+func NewArbHeadsAPI(b *APIBackend) *ArbHeadsAPI {
+	return &ArbHeadsAPI{b: b, retryDelay: blockMetadataRetryDelay}
+}
+
+// NewHeadsWithMetadata sends a notification each time a new head is appended to
+// the chain, carrying the header along with its Arbitrum block metadata and L1
+// block number. If the metadata can't be retrieved, it is reported as null.
+func (api *ArbHeadsAPI) NewHeadsWithMetadata(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		heads := make(chan core.ChainHeadEvent, 16)
+		headsSub := api.b.SubscribeChainHeadEvent(heads)
+		defer headsSub.Unsubscribe()
+
+		// Heads waiting for their metadata to be retried, in chain order. Later
+		// heads are queued behind them so that notifications stay ordered.
+		var (
+			pending []*pendingHead
+			retry   *time.Timer
+			retryC  <-chan time.Time
+		)
+		defer func() {
+			if retry != nil {
+				retry.Stop()
+			}
+		}()
+		// flush notifies the complete heads at the front of the queue and arms
+		// the retry timer for the first incomplete one.
+		flush := func() {
+			for len(pending) > 0 && pending[0].done {
+				notifier.Notify(rpcSub.ID, pending[0].fields)
+				pending = pending[1:]
+			}
+			if retryC != nil || len(pending) == 0 {
+				return
+			}
+			retry = time.NewTimer(time.Until(pending[0].retryAt))
+			retryC = retry.C
+		}
+		for {
+			select {
+			case ev := <-heads:
+				header := ev.Block.Header()
+				fields, done := api.headWithMetadata(header)
+				pending = append(pending, &pendingHead{
+					number:  header.Number.Uint64(),
+					fields:  fields,
+					done:    done,
+					retryAt: time.Now().Add(api.retryDelay),
+				})
+				flush()
+			case <-retryC:
+				retryC = nil
+				now := time.Now()
+				for _, head := range pending {
+					if !head.done && !head.retryAt.After(now) {
+						api.retryBlockMetadata(head.number, head.fields)
+						head.done = true
+					}
+				}
+				flush()
+			case <-headsSub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// pendingHead is a head notification waiting for its block metadata.
+type pendingHead struct {
+	number  uint64
+	fields  map[string]interface{}
+	done    bool      // Whether the notification is ready to be sent
+	retryAt time.Time // When to ask for the metadata again
+}
+
+// headWithMetadata marshals the header along with its block metadata. It
+// reports false if the metadata wasn't available yet, in which case it should
+// be asked for again with retryBlockMetadata.
+func (api *ArbHeadsAPI) headWithMetadata(header *types.Header) (map[string]interface{}, bool) {
+	fields := ethapi.RPCMarshalHeader(header)
+	fields["l1BlockNumber"] = hexutil.Uint64(types.DeserializeHeaderExtraInformation(header).L1BlockNumber)
+	fields["blockMetadata"] = nil
+
+	backend, ok := api.b.sync.(BlockMetadataBackend)
+	if !ok {
+		return fields, true
+	}
+	metadata, err := backend.BlockMetadataByNumber(context.Background(), header.Number.Uint64())
+	if err != nil || metadata == nil {
+		// The metadata might not have been recorded yet, give it another chance
+		return fields, false
+	}
+	fields["blockMetadata"] = metadata
+	return fields, true
+}
+
+// retryBlockMetadata asks again for the block metadata of a head marshalled by
+// headWithMetadata, leaving it null if it's still unavailable.
+func (api *ArbHeadsAPI) retryBlockMetadata(number uint64, fields map[string]interface{}) {
+	backend := api.b.sync.(BlockMetadataBackend)
+	metadata, err := backend.BlockMetadataByNumber(context.Background(), number)
+	if err != nil {
+		log.Debug("Failed to retrieve block metadata", "number", number, "err", err)
+	} else if metadata != nil {
+		fields["blockMetadata"] = metadata
+	}
+}
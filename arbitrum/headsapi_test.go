@@ -0,0 +1,218 @@
+package arbitrum
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/arbitrum_types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type stubArbInterface struct {
+	chain *core.BlockChain
+}
+
+func (s *stubArbInterface) PublishTransaction(ctx context.Context, tx *types.Transaction, options *arbitrum_types.ConditionalOptions) error {
+	return nil
+}
+func (s *stubArbInterface) BlockChain() *core.BlockChain { return s.chain }
+func (s *stubArbInterface) ArbNode() interface{}         { return nil }
+
+// stubSyncBackend serves block metadata, some of which only becomes available
+// after it was first requested.
+type stubSyncBackend struct {
+	mu       sync.Mutex
+	metadata map[uint64]hexutil.Bytes // Metadata available right away
+	late     map[uint64]hexutil.Bytes // Metadata available from the second request
+	requests map[uint64]int
+}
+
+func (s *stubSyncBackend) SyncProgressMap() map[string]interface{}                  { return nil }
+func (s *stubSyncBackend) SafeBlockNumber(ctx context.Context) (uint64, error)      { return 0, nil }
+func (s *stubSyncBackend) FinalizedBlockNumber(ctx context.Context) (uint64, error) { return 0, nil }
+
+func (s *stubSyncBackend) BlockMetadataByNumber(ctx context.Context, blockNum uint64) (hexutil.Bytes, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[blockNum]++
+	if metadata, ok := s.metadata[blockNum]; ok {
+		return metadata, nil
+	}
+	if metadata, ok := s.late[blockNum]; ok && s.requests[blockNum] > 1 {
+		return metadata, nil
+	}
+	return nil, errors.New("block metadata not found")
+}
+
+type headWithMetadata struct {
+	Hash          common.Hash    `json:"hash"`
+	Number        *hexutil.Big   `json:"number"`
+	L1BlockNumber hexutil.Uint64 `json:"l1BlockNumber"`
+	BlockMetadata *hexutil.Bytes `json:"blockMetadata"`
+}
+
+func TestNewHeadsWithMetadata(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		genesis = &core.Genesis{
+			Config:  params.TestChainConfig,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		_, blocks, _ = core.GenerateChainWithGenesis(genesis, engine, 3, nil)
+	)
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), nil, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	syncBackend := &stubSyncBackend{
+		metadata: map[uint64]hexutil.Bytes{1: {0x01}},
+		late:     map[uint64]hexutil.Bytes{2: {0x02}},
+		requests: make(map[uint64]int),
+	}
+	backend := &APIBackend{
+		b:    &Backend{arb: &stubArbInterface{chain: chain}},
+		sync: syncBackend,
+	}
+	api := NewArbHeadsAPI(backend)
+	api.retryDelay = 10 * time.Millisecond
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("arb", api); err != nil {
+		t.Fatalf("failed to register api: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	heads := make(chan headWithMetadata)
+	sub, err := client.Subscribe(context.Background(), "arb", heads, "newHeadsWithMetadata")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	want := []hexutil.Bytes{{0x01}, {0x02}, nil}
+	for i, block := range blocks {
+		if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("failed to insert block %d: %v", block.NumberU64(), err)
+		}
+		select {
+		case head := <-heads:
+			if head.Hash != block.Hash() || head.Number.ToInt().Cmp(block.Number()) != 0 {
+				t.Fatalf("block %d: head mismatch: have #%v %x, want #%d %x", i+1, head.Number, head.Hash, block.NumberU64(), block.Hash())
+			}
+			if want[i] == nil {
+				if head.BlockMetadata != nil {
+					t.Fatalf("block %d: expected null metadata, have %x", i+1, *head.BlockMetadata)
+				}
+			} else if head.BlockMetadata == nil || string(*head.BlockMetadata) != string(want[i]) {
+				t.Fatalf("block %d: metadata mismatch: have %v, want %x", i+1, head.BlockMetadata, want[i])
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("block %d: no head notification", i+1)
+		}
+	}
+	// Missing metadata is requested exactly twice before giving up
+	syncBackend.mu.Lock()
+	defer syncBackend.mu.Unlock()
+	if have := syncBackend.requests[3]; have != 2 {
+		t.Fatalf("metadata request count mismatch: have %d, want %d", have, 2)
+	}
+}
+
+// Tests that heads keep being processed while the metadata of an earlier one is
+// waiting to be retried, and that they're still notified in order.
+func TestNewHeadsWithMetadataRetryOrder(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		genesis = &core.Genesis{
+			Config:  params.TestChainConfig,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		_, blocks, _ = core.GenerateChainWithGenesis(genesis, engine, 3, nil)
+	)
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), nil, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	syncBackend := &stubSyncBackend{
+		metadata: map[uint64]hexutil.Bytes{2: {0x02}, 3: {0x03}},
+		late:     map[uint64]hexutil.Bytes{1: {0x01}},
+		requests: make(map[uint64]int),
+	}
+	backend := &APIBackend{
+		b:    &Backend{arb: &stubArbInterface{chain: chain}},
+		sync: syncBackend,
+	}
+	api := NewArbHeadsAPI(backend)
+	api.retryDelay = time.Second
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("arb", api); err != nil {
+		t.Fatalf("failed to register api: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	heads := make(chan headWithMetadata)
+	sub, err := client.Subscribe(context.Background(), "arb", heads, "newHeadsWithMetadata")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	for _, block := range blocks {
+		if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("failed to insert block %d: %v", block.NumberU64(), err)
+		}
+	}
+	// The later heads are looked up well before the first one is retried
+	deadline := time.Now().Add(api.retryDelay / 2)
+	for {
+		syncBackend.mu.Lock()
+		requested := syncBackend.requests[3]
+		syncBackend.mu.Unlock()
+		if requested > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("head processing stalled by the metadata retry")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i, block := range blocks {
+		select {
+		case head := <-heads:
+			if head.Hash != block.Hash() {
+				t.Fatalf("head %d: hash mismatch: have %x, want %x", i+1, head.Hash, block.Hash())
+			}
+			if head.BlockMetadata == nil || len(*head.BlockMetadata) != 1 || (*head.BlockMetadata)[0] != byte(i+1) {
+				t.Fatalf("head %d: metadata mismatch: have %v", i+1, head.BlockMetadata)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("head %d: no notification", i+1)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package arbitrum
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	// ErrMultiGasPricingNotEnabled is returned by a MultiGasPricer for the states
+	// of ArbOS versions predating the per-dimension gas pricing.
+	ErrMultiGasPricingNotEnabled = errors.New("multi-dimensional gas pricing not enabled at this ArbOS version")
+
+	errMultiGasPricerNotInstalled = errors.New("multi-dimensional gas pricer not installed")
+)
+
+// MultiGasDimensionPricing is the pricing state of a single resource dimension.
+type MultiGasDimensionPricing struct {
+	Dimension       string         `json:"dimension"`
+	Backlog         hexutil.Uint64 `json:"backlog"`
+	Target          hexutil.Uint64 `json:"target"`          // Gas per second
+	PriceMultiplier hexutil.Uint64 `json:"priceMultiplier"` // In basis points of the base price
+}
+
+// MultiGasPricer reads the per-dimension gas pricing state maintained by ArbOS.
+type MultiGasPricer interface {
+	// MultiGasPricing returns the pricing state of every resource dimension as
+	// of the given state, or ErrMultiGasPricingNotEnabled if the ArbOS version
+	// of the state predates it.
+	MultiGasPricing(statedb *state.StateDB, header *types.Header) ([]MultiGasDimensionPricing, error)
+}
+
+// SetMultiGasPricer installs the pricer answering arb_getMultiGasBacklog.
+func (b *Backend) SetMultiGasPricer(pricer MultiGasPricer) error {
+	if b.multiGasPricer != nil {
+		return errors.New("multi-dimensional gas pricer already set")
+	}
+	b.multiGasPricer = pricer
+	return nil
+}
+
+// MultiGasBacklog is the result of arb_getMultiGasBacklog.
+type MultiGasBacklog struct {
+	BlockHash   common.Hash                `json:"blockHash"`
+	BlockNumber hexutil.Uint64             `json:"blockNumber"`
+	Dimensions  []MultiGasDimensionPricing `json:"dimensions"`
+}
+
+// ArbMultiGasAPI reports the per-dimension gas pricing state of ArbOS.
+type ArbMultiGasAPI struct {
+	b *APIBackend
+}
+
+// NewArbMultiGasAPI creates a new multi-dimensional gas pricing API instance.
+func NewArbMultiGasAPI(b *APIBackend) *ArbMultiGasAPI {
+	return &ArbMultiGasAPI{b}
+}
+
+// GetMultiGasBacklog returns the backlog, target and price multiplier of every
+// resource dimension as of the given block.
+func (api *ArbMultiGasAPI) GetMultiGasBacklog(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*MultiGasBacklog, error) {
+	pricer := api.b.b.multiGasPricer
+	if pricer == nil {
+		return nil, errMultiGasPricerNotInstalled
+	}
+	statedb, header, err := api.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	dimensions, err := pricer.MultiGasPricing(statedb, header)
+	if err != nil {
+		return nil, err
+	}
+	if dimensions == nil {
+		dimensions = []MultiGasDimensionPricing{}
+	}
+	return &MultiGasBacklog{
+		BlockHash:   header.Hash(),
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		Dimensions:  dimensions,
+	}, nil
+}
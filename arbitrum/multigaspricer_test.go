@@ -0,0 +1,111 @@
+package arbitrum
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// stubMultiGasPricer reports the block number as the backlog of every
+// dimension, starting at a given upgrade block.
+type stubMultiGasPricer struct {
+	upgradeBlock uint64
+}
+
+func (p *stubMultiGasPricer) MultiGasPricing(statedb *state.StateDB, header *types.Header) ([]MultiGasDimensionPricing, error) {
+	if statedb == nil {
+		return nil, errors.New("no state")
+	}
+	if header.Number.Uint64() < p.upgradeBlock {
+		return nil, ErrMultiGasPricingNotEnabled
+	}
+	return []MultiGasDimensionPricing{
+		{Dimension: "computation", Backlog: hexutil.Uint64(header.Number.Uint64()), Target: 7_000_000, PriceMultiplier: 10000},
+		{Dimension: "storageGrowth", Backlog: hexutil.Uint64(header.Number.Uint64()), Target: 1_000_000, PriceMultiplier: 12500},
+	}, nil
+}
+
+func TestMultiGasBacklog(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		config  = *params.TestChainConfig
+		genesis = &core.Genesis{
+			Config:  &config,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+	)
+	config.ArbitrumChainParams.EnableArbOS = true
+	_, blocks, _ := core.GenerateChainWithGenesis(genesis, engine, 3, nil)
+
+	db := rawdb.NewMemoryDatabase()
+	genesis.MustCommit(db, triedb.NewDatabase(db, triedb.HashDefaults))
+	chain, err := core.NewBlockChain(db, nil, &config, nil, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	arbConfig := DefaultConfig
+	backend := &APIBackend{
+		b: &Backend{arb: &stubArbInterface{chain: chain}, config: &arbConfig, chainDb: db},
+	}
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("arb", NewArbMultiGasAPI(backend)); err != nil {
+		t.Fatalf("failed to register api: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	var backlog *MultiGasBacklog
+	if err := client.Call(&backlog, "arb_getMultiGasBacklog", "latest"); err == nil || err.Error() != errMultiGasPricerNotInstalled.Error() {
+		t.Errorf("error mismatch without pricer: have %v, want %v", err, errMultiGasPricerNotInstalled)
+	}
+	if err := backend.b.SetMultiGasPricer(&stubMultiGasPricer{upgradeBlock: 2}); err != nil {
+		t.Fatalf("failed to set pricer: %v", err)
+	}
+	if err := backend.b.SetMultiGasPricer(&stubMultiGasPricer{}); err == nil {
+		t.Error("expected error replacing the pricer")
+	}
+	// Blocks before the upgrade have no per-dimension pricing
+	if err := client.Call(&backlog, "arb_getMultiGasBacklog", "0x1"); err == nil || err.Error() != ErrMultiGasPricingNotEnabled.Error() {
+		t.Errorf("error mismatch before upgrade: have %v, want %v", err, ErrMultiGasPricingNotEnabled)
+	}
+	// Later ones are read from the state of the requested block
+	for _, number := range []uint64{2, 3} {
+		if err := client.Call(&backlog, "arb_getMultiGasBacklog", hexutil.Uint64(number)); err != nil {
+			t.Fatalf("block %d: failed to get backlog: %v", number, err)
+		}
+		if backlog.BlockHash != blocks[number-1].Hash() || uint64(backlog.BlockNumber) != number {
+			t.Errorf("block %d: block mismatch: have #%d %x", number, backlog.BlockNumber, backlog.BlockHash)
+		}
+		if len(backlog.Dimensions) != 2 {
+			t.Fatalf("block %d: dimensions mismatch: have %d, want 2", number, len(backlog.Dimensions))
+		}
+		for _, dim := range backlog.Dimensions {
+			if uint64(dim.Backlog) != number {
+				t.Errorf("block %d: %s backlog mismatch: have %d, want %d", number, dim.Dimension, dim.Backlog, number)
+			}
+		}
+		if backlog.Dimensions[1].PriceMultiplier != 12500 {
+			t.Errorf("block %d: price multiplier mismatch: have %d, want 12500", number, backlog.Dimensions[1].PriceMultiplier)
+		}
+	}
+	// Blocks above the head aren't there yet
+	if err := client.Call(&backlog, "arb_getMultiGasBacklog", "0x4"); err == nil {
+		t.Error("expected error for block above head")
+	}
+}
@@ -0,0 +1,147 @@
+package arbitrum
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// pendingNonceTimeout is how long a pending nonce is remembered if the
+	// transaction it belongs to doesn't get included in a block.
+	pendingNonceTimeout = time.Minute
+
+	// pendingNonceLimit is the maximum number of senders tracked at once.
+	pendingNonceLimit = 4096
+)
+
+type pendingNonce struct {
+	nonce uint64         // Highest nonce enqueued but not yet included
+	added mclock.AbsTime // Time the nonce was last raised
+}
+
+// pendingNonces tracks the nonces of transactions that were handed to the
+// sequencer but are not yet included in a block, so that nonce suggestions
+// account for them. Arbitrum has no transaction pool to track these otherwise.
+type pendingNonces struct {
+	mu      sync.Mutex
+	nonces  map[common.Address]pendingNonce
+	clock   mclock.Clock
+	timeout time.Duration
+	limit   int
+}
+
+func newPendingNonces(clock mclock.Clock, timeout time.Duration, limit int) *pendingNonces {
+	return &pendingNonces{
+		nonces:  make(map[common.Address]pendingNonce),
+		clock:   clock,
+		timeout: timeout,
+		limit:   limit,
+	}
+}
+
+// add records that a transaction with the given nonce was enqueued by addr.
+func (p *pendingNonces) add(addr common.Address, nonce uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock.Now()
+	if entry, ok := p.nonces[addr]; ok && !p.expired(entry, now) && entry.nonce >= nonce {
+		return
+	}
+	if _, ok := p.nonces[addr]; !ok && len(p.nonces) >= p.limit {
+		p.evict(now)
+	}
+	p.nonces[addr] = pendingNonce{nonce: nonce, added: now}
+}
+
+// get returns the next nonce to use for addr, given its nonce in the latest state.
+func (p *pendingNonces) get(addr common.Address, stateNonce uint64) uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.nonces[addr]
+	if !ok || p.expired(entry, p.clock.Now()) || entry.nonce < stateNonce {
+		return stateNonce
+	}
+	return entry.nonce + 1
+}
+
+// prune drops the entries whose transactions were included according to the
+// given state nonce lookup, as well as the expired ones.
+func (p *pendingNonces) prune(stateNonce func(common.Address) uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock.Now()
+	for addr, entry := range p.nonces {
+		if p.expired(entry, now) || entry.nonce < stateNonce(addr) {
+			delete(p.nonces, addr)
+		}
+	}
+}
+
+func (p *pendingNonces) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.nonces)
+}
+
+func (p *pendingNonces) expired(entry pendingNonce, now mclock.AbsTime) bool {
+	return time.Duration(now-entry.added) > p.timeout
+}
+
+// evict drops the expired entries, or the oldest one if none have expired.
+// The caller must hold the lock.
+func (p *pendingNonces) evict(now mclock.AbsTime) {
+	var (
+		oldest      common.Address
+		oldestAdded mclock.AbsTime
+		found       bool
+	)
+	for addr, entry := range p.nonces {
+		if p.expired(entry, now) {
+			delete(p.nonces, addr)
+			continue
+		}
+		if !found || entry.added < oldestAdded {
+			oldest, oldestAdded, found = addr, entry.added, true
+		}
+	}
+	if len(p.nonces) >= p.limit && found {
+		delete(p.nonces, oldest)
+	}
+}
+
+// prunePendingNonces drops the pending nonces and transactions of included
+// transactions whenever a new head block is imported.
+func (b *Backend) prunePendingNonces() {
+	heads := make(chan core.ChainHeadEvent, 10)
+	sub := b.BlockChain().SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case head := <-heads:
+			if b.pendingNonces.len() == 0 && b.pendingTxs.len() == 0 {
+				continue
+			}
+			statedb, err := b.BlockChain().StateAt(head.Block.Root())
+			if err != nil {
+				log.Warn("Failed to open head state for pending nonces", "block", head.Block.Number(), "err", err)
+				continue
+			}
+			b.pendingNonces.prune(statedb.GetNonce)
+			b.pendingTxs.prune(statedb.GetNonce)
+		case <-sub.Err():
+			return
+		case <-b.chanClose:
+			return
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package arbitrum
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+)
+
+func TestPendingNoncesSuggestions(t *testing.T) {
+	var (
+		clock   = new(mclock.Simulated)
+		pending = newPendingNonces(clock, time.Minute, 16)
+		addr    = common.Address{1}
+		state   = uint64(5)
+	)
+	// Enqueue three transactions without any getting included
+	for i := uint64(0); i < 3; i++ {
+		nonce := pending.get(addr, state)
+		if nonce != state+i {
+			t.Fatalf("tx %d: nonce mismatch: have %d, want %d", i, nonce, state+i)
+		}
+		pending.add(addr, nonce)
+	}
+	if have := pending.get(addr, state); have != state+3 {
+		t.Fatalf("nonce mismatch: have %d, want %d", have, state+3)
+	}
+	// Re-enqueueing a lower nonce must not lower the suggestion
+	pending.add(addr, state)
+	if have := pending.get(addr, state); have != state+3 {
+		t.Fatalf("nonce mismatch after replacement: have %d, want %d", have, state+3)
+	}
+	// A state nonce above the pending ones takes precedence
+	if have := pending.get(addr, state+10); have != state+10 {
+		t.Fatalf("nonce mismatch with advanced state: have %d, want %d", have, state+10)
+	}
+	// Once the timeout passes, the state nonce is suggested again
+	clock.Run(time.Minute + time.Second)
+	if have := pending.get(addr, state); have != state {
+		t.Fatalf("nonce mismatch after expiry: have %d, want %d", have, state)
+	}
+}
+
+func TestPendingNoncesPrune(t *testing.T) {
+	var (
+		clock   = new(mclock.Simulated)
+		pending = newPendingNonces(clock, time.Minute, 16)
+		state   = map[common.Address]uint64{{1}: 3, {2}: 3, {3}: 3}
+	)
+	pending.add(common.Address{1}, 3) // included below
+	pending.add(common.Address{2}, 4) // partially included below
+	clock.Run(2 * time.Minute)
+	pending.add(common.Address{3}, 3) // not included
+
+	state[common.Address{1}] = 4
+	state[common.Address{2}] = 4
+	pending.prune(func(addr common.Address) uint64 { return state[addr] })
+
+	if have := pending.len(); have != 1 {
+		t.Fatalf("tracked senders mismatch: have %d, want %d", have, 1)
+	}
+	if have := pending.get(common.Address{3}, state[common.Address{3}]); have != 4 {
+		t.Fatalf("nonce mismatch: have %d, want %d", have, 4)
+	}
+}
+
+func TestPendingNoncesLimit(t *testing.T) {
+	var (
+		clock   = new(mclock.Simulated)
+		pending = newPendingNonces(clock, time.Minute, 4)
+	)
+	for i := byte(0); i < 10; i++ {
+		pending.add(common.Address{i}, 1)
+		clock.Run(time.Second)
+	}
+	if have := pending.len(); have != 4 {
+		t.Fatalf("tracked senders mismatch: have %d, want %d", have, 4)
+	}
+	// The oldest senders are evicted first
+	if have := pending.get(common.Address{0}, 0); have != 0 {
+		t.Fatalf("oldest sender not evicted: nonce %d", have)
+	}
+	if have := pending.get(common.Address{9}, 0); have != 2 {
+		t.Fatalf("newest sender evicted: have %d, want %d", have, 2)
+	}
+}
@@ -0,0 +1,133 @@
+package arbitrum
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// pendingTxTimeout is how long an enqueued transaction is reported as
+	// pending if it doesn't get included in a block.
+	pendingTxTimeout = time.Minute
+
+	// pendingTxLimit is the maximum number of transactions tracked at once.
+	pendingTxLimit = 4096
+)
+
+type pendingTx struct {
+	tx     *types.Transaction
+	sender common.Address
+	added  mclock.AbsTime
+}
+
+// pendingTxs is a view of the transactions handed to the sequencer which are
+// not yet included in a block, standing in for the transaction pool Arbitrum
+// doesn't have when answering pending transaction queries.
+type pendingTxs struct {
+	mu      sync.Mutex
+	txs     map[common.Hash]*pendingTx
+	order   []common.Hash // Hashes in the order the transactions were added
+	clock   mclock.Clock
+	timeout time.Duration
+	limit   int
+}
+
+func newPendingTxs(clock mclock.Clock, timeout time.Duration, limit int) *pendingTxs {
+	return &pendingTxs{
+		txs:     make(map[common.Hash]*pendingTx),
+		clock:   clock,
+		timeout: timeout,
+		limit:   limit,
+	}
+}
+
+// add records that tx was enqueued by sender, dropping the expired transactions
+// and the oldest tracked one if the limit is reached.
+func (p *pendingTxs) add(tx *types.Transaction, sender common.Address) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hash := tx.Hash()
+	if _, ok := p.txs[hash]; ok {
+		return
+	}
+	// The transactions are ordered by the time they were added, so the expired
+	// ones are all at the front
+	now := p.clock.Now()
+	for len(p.order) > 0 {
+		oldest := p.txs[p.order[0]]
+		if len(p.order) < p.limit && time.Duration(now-oldest.added) <= p.timeout {
+			break
+		}
+		delete(p.txs, p.order[0])
+		p.order = p.order[1:]
+	}
+	p.txs[hash] = &pendingTx{tx: tx, sender: sender, added: now}
+	p.order = append(p.order, hash)
+}
+
+// get returns the pending transaction with the given hash, or nil if it isn't
+// tracked, expired or was included according to the given state nonce lookup.
+func (p *pendingTxs) get(hash common.Hash, stateNonce func(common.Address) uint64) *types.Transaction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.txs[hash]
+	if !ok || p.stale(entry, p.clock.Now(), stateNonce) {
+		return nil
+	}
+	return entry.tx
+}
+
+// list returns the pending transactions in the order they were enqueued,
+// skipping the expired ones and those included according to the given state
+// nonce lookup.
+func (p *pendingTxs) list(stateNonce func(common.Address) uint64) types.Transactions {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock.Now()
+	txs := make(types.Transactions, 0, len(p.order))
+	for _, hash := range p.order {
+		if entry := p.txs[hash]; !p.stale(entry, now, stateNonce) {
+			txs = append(txs, entry.tx)
+		}
+	}
+	return txs
+}
+
+// prune drops the transactions included according to the given state nonce
+// lookup, as well as the expired ones.
+func (p *pendingTxs) prune(stateNonce func(common.Address) uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock.Now()
+	for hash, entry := range p.txs {
+		if p.stale(entry, now, stateNonce) {
+			delete(p.txs, hash)
+		}
+	}
+	order := p.order[:0]
+	for _, hash := range p.order {
+		if _, ok := p.txs[hash]; ok {
+			order = append(order, hash)
+		}
+	}
+	p.order = order
+}
+
+func (p *pendingTxs) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.txs)
+}
+
+func (p *pendingTxs) stale(entry *pendingTx, now mclock.AbsTime, stateNonce func(common.Address) uint64) bool {
+	return time.Duration(now-entry.added) > p.timeout || entry.tx.Nonce() < stateNonce(entry.sender)
+}
@@ -0,0 +1,156 @@
+package arbitrum
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func newPendingTestTx(nonce uint64) *types.Transaction {
+	return types.NewTx(&types.LegacyTx{Nonce: nonce, Gas: params.TxGas})
+}
+
+func TestPendingTxsView(t *testing.T) {
+	var (
+		clock   = new(mclock.Simulated)
+		pending = newPendingTxs(clock, time.Minute, 16)
+		state   = map[common.Address]uint64{{1}: 3, {2}: 0}
+		nonceAt = func(addr common.Address) uint64 { return state[addr] }
+		txs     = []*types.Transaction{newPendingTestTx(3), newPendingTestTx(4), newPendingTestTx(0)}
+	)
+	pending.add(txs[0], common.Address{1})
+	pending.add(txs[1], common.Address{1})
+	clock.Run(30 * time.Second)
+	pending.add(txs[2], common.Address{2})
+	pending.add(txs[0], common.Address{1}) // duplicates are ignored
+
+	if have := pending.list(nonceAt); len(have) != 3 || have[0] != txs[0] || have[1] != txs[1] || have[2] != txs[2] {
+		t.Fatalf("pending txs mismatch: have %v", have)
+	}
+	// Included transactions drop out of the view right away
+	state[common.Address{1}] = 4
+	if have := pending.list(nonceAt); len(have) != 2 || have[0] != txs[1] {
+		t.Fatalf("pending txs mismatch after inclusion: have %v", have)
+	}
+	if pending.get(txs[0].Hash(), nonceAt) != nil {
+		t.Error("included tx still pending")
+	}
+	if pending.get(txs[1].Hash(), nonceAt) != txs[1] {
+		t.Error("pending tx not found")
+	}
+	// Stuck transactions drop out once the timeout passes
+	clock.Run(31 * time.Second)
+	if have := pending.list(nonceAt); len(have) != 1 || have[0] != txs[2] {
+		t.Fatalf("pending txs mismatch after expiry: have %v", have)
+	}
+	pending.prune(nonceAt)
+	if have := pending.len(); have != 1 {
+		t.Fatalf("tracked txs mismatch: have %d, want %d", have, 1)
+	}
+}
+
+func TestPendingTxsLimit(t *testing.T) {
+	var (
+		clock   = new(mclock.Simulated)
+		pending = newPendingTxs(clock, time.Minute, 4)
+		nonceAt = func(common.Address) uint64 { return 0 }
+		txs     []*types.Transaction
+	)
+	for i := uint64(0); i < 10; i++ {
+		txs = append(txs, newPendingTestTx(i))
+		pending.add(txs[i], common.Address{})
+		clock.Run(time.Second)
+	}
+	if have := pending.len(); have != 4 {
+		t.Fatalf("tracked txs mismatch: have %d, want %d", have, 4)
+	}
+	// The oldest transactions are evicted first
+	if pending.get(txs[5].Hash(), nonceAt) != nil {
+		t.Error("old tx not evicted")
+	}
+	if have := pending.list(nonceAt); len(have) != 4 || have[0] != txs[6] || have[3] != txs[9] {
+		t.Errorf("pending txs mismatch: have %v", have)
+	}
+	// Expired transactions are dropped when new ones are added
+	clock.Run(58 * time.Second)
+	pending.add(newPendingTestTx(10), common.Address{})
+	if have := pending.len(); have != 3 {
+		t.Fatalf("tracked txs mismatch after expiry: have %d, want %d", have, 3)
+	}
+}
+
+func TestPoolTransactionsFromEnqueued(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		engine  = ethash.NewFaker()
+		genesis = &core.Genesis{
+			Config:  params.TestChainConfig,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(genesis.Config)
+		txs    = make([]*types.Transaction, 2)
+	)
+	for i := range txs {
+		txs[i] = types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: uint64(i), To: &common.Address{}, Gas: params.TxGas, GasPrice: big.NewInt(2 * params.InitialBaseFee)})
+	}
+	_, blocks, _ := core.GenerateChainWithGenesis(genesis, engine, 1, func(i int, b *core.BlockGen) {
+		b.AddTx(txs[0])
+	})
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), nil, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	backend := &APIBackend{
+		b: &Backend{
+			arb:           &stubArbInterface{chain: chain},
+			pendingNonces: newPendingNonces(mclock.System{}, pendingNonceTimeout, pendingNonceLimit),
+			pendingTxs:    newPendingTxs(mclock.System{}, pendingTxTimeout, pendingTxLimit),
+		},
+	}
+	events := make(chan core.NewTxsEvent, len(txs))
+	sub := backend.SubscribeNewTxsEvent(events)
+	defer sub.Unsubscribe()
+
+	for i, tx := range txs {
+		if err := backend.SendTx(context.Background(), tx); err != nil {
+			t.Fatalf("failed to send tx %d: %v", i, err)
+		}
+		// The announced transaction must already be visible as pending
+		ev := <-events
+		if len(ev.Txs) != 1 || ev.Txs[0].Hash() != tx.Hash() {
+			t.Fatalf("tx %d: event mismatch: have %v", i, ev.Txs)
+		}
+		if backend.GetPoolTransaction(tx.Hash()) == nil {
+			t.Fatalf("tx %d: not pending after its event", i)
+		}
+	}
+	if pool, err := backend.GetPoolTransactions(); err != nil || len(pool) != 2 {
+		t.Fatalf("pending txs mismatch: have %v, err %v", pool, err)
+	}
+	// Once the first transaction is included, only the second one is pending
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	pool, err := backend.GetPoolTransactions()
+	if err != nil || len(pool) != 1 || pool[0].Hash() != txs[1].Hash() {
+		t.Fatalf("pending txs mismatch after inclusion: have %v, err %v", pool, err)
+	}
+	if backend.GetPoolTransaction(txs[0].Hash()) != nil {
+		t.Error("included tx still pending")
+	}
+}
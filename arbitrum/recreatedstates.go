@@ -0,0 +1,132 @@
+package arbitrum
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// recreatedStates keeps some of the intermediate states built while recreating
+// historical states, so that queries for nearby blocks can start from them
+// rather than from the last state saved to disk.
+//
+// The states are kept in the dirty cache of a trie database shared by all the
+// recreations, isolated from the live one and never committed to disk. Each
+// kept state holds a reference on its root, dropped when it is evicted. A state
+// used as the base of a recreation is pinned, and isn't evicted until released.
+//
+// The limit bounds the number of kept states, not their memory. Each one holds
+// the trie nodes changed since the previous kept state, so the memory used
+// grows with the limit, the interval and the size of the blocks in between.
+type recreatedStates struct {
+	db       state.Database
+	interval uint64 // Blocks between the kept states
+	limit    int    // Maximum number of kept states
+
+	mu    sync.Mutex
+	roots lru.BasicLRU[common.Hash, common.Hash] // State roots of the kept states by block hash
+	pins  map[common.Hash]int                    // Number of recreations using each kept state
+}
+
+func newRecreatedStates(chainDb ethdb.Database, interval uint64, limit int) *recreatedStates {
+	return &recreatedStates{
+		// note: triedb cleans cache is disabled in trie.HashDefaults
+		db:       state.NewDatabaseWithConfig(chainDb, triedb.HashDefaults),
+		interval: interval,
+		limit:    limit,
+		roots:    lru.NewBasicLRU[common.Hash, common.Hash](limit),
+		pins:     make(map[common.Hash]int),
+	}
+}
+
+// shouldKeep reports whether the state of the given block is to be kept once
+// recreated.
+func (r *recreatedStates) shouldKeep(number uint64) bool {
+	return number%r.interval == 0
+}
+
+// keep references the state of the given block, which must be in the shared
+// database, evicting the least recently used unpinned state if the limit is
+// reached. If all the kept states are pinned, the state isn't kept and false is
+// returned, the caller has to hold its own reference while using it.
+func (r *recreatedStates) keep(header *types.Header) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hash := header.Hash()
+	if r.roots.Contains(hash) {
+		return true
+	}
+	if r.roots.Len() >= r.limit {
+		evicted := false
+		for _, oldest := range r.roots.Keys() {
+			if r.pins[oldest] > 0 {
+				continue
+			}
+			root, _ := r.roots.Peek(oldest)
+			r.roots.Remove(oldest)
+			r.db.TrieDB().Dereference(root)
+			evicted = true
+			break
+		}
+		if !evicted {
+			return false
+		}
+	}
+	r.db.TrieDB().Reference(header.Root, common.Hash{})
+	r.roots.Add(hash, header.Root)
+	return true
+}
+
+// pin marks the state of the given block, if kept, as recently used and keeps
+// it from being evicted until the returned function is called.
+func (r *recreatedStates) pin(hash common.Hash) (func(), bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.roots.Get(hash); !ok {
+		return nil, false
+	}
+	r.pins[hash]++
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if r.pins[hash]--; r.pins[hash] == 0 {
+			delete(r.pins, hash)
+		}
+	}, true
+}
+
+// has reports whether the state of the given block is kept.
+func (r *recreatedStates) has(hash common.Hash) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.roots.Contains(hash)
+}
+
+// len returns the number of kept states.
+func (r *recreatedStates) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.roots.Len()
+}
+
+// close drops all the kept states.
+func (r *recreatedStates) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, hash := range r.roots.Keys() {
+		root, _ := r.roots.Peek(hash)
+		r.db.TrieDB().Dereference(root)
+	}
+	r.roots.Purge()
+}
@@ -0,0 +1,231 @@
+package arbitrum
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// countingProcessor counts the blocks processed, recreating states included.
+type countingProcessor struct {
+	core.Processor
+	processed int
+}
+
+func (p *countingProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	p.processed++
+	return p.Processor.Process(block, statedb, cfg)
+}
+
+// newRecreatedStatesTestBackend creates a sparse archive of 28 blocks, each
+// funding a new account, only committing the states of blocks 0 and 1 and
+// keeping the last two states in memory, with a backend keeping the recreated
+// states at the given interval.
+func newRecreatedStatesTestBackend(t *testing.T, interval uint64, count int) (*APIBackend, []*types.Block, *countingProcessor) {
+	var (
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		engine  = ethash.NewFaker()
+		config  = *params.TestChainConfig
+		genesis = &core.Genesis{
+			Config:  &config,
+			Alloc:   types.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		signer      = types.LatestSigner(genesis.Config)
+		cacheConfig = *core.DefaultCacheConfigWithScheme(rawdb.HashScheme)
+		processor   *countingProcessor
+	)
+	config.ArbitrumChainParams.EnableArbOS = true
+	_, blocks, _ := core.GenerateChainWithGenesis(genesis, engine, 28, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+			Nonce:    b.TxNonce(address),
+			To:       &common.Address{byte(i + 1)},
+			Value:    big.NewInt(1),
+			Gas:      params.TxGas,
+			GasPrice: b.BaseFee(),
+		})
+		b.AddTx(tx)
+	})
+
+	cacheConfig.TrieDirtyDisabled = true
+	cacheConfig.MaxNumberOfBlocksToSkipStateSaving = 100
+	cacheConfig.TriesInMemory = 2
+	cacheConfig.TrieRetention = 0
+
+	db := rawdb.NewMemoryDatabase()
+	genesis.MustCommit(db, triedb.NewDatabase(db, triedb.HashDefaults))
+	newProcessor := func(config *params.ChainConfig, bc *core.BlockChain, engine consensus.Engine) core.Processor {
+		processor = &countingProcessor{Processor: core.NewStateProcessor(config, bc, engine)}
+		return processor
+	}
+	chain, err := core.NewBlockChainWithProcessors(db, &cacheConfig, &config, nil, nil, engine, vm.Config{}, nil, nil, nil, newProcessor)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	t.Cleanup(chain.Stop)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	arbConfig := DefaultConfig
+	arbConfig.MaxRecreateStateDepth = DefaultArchiveNodeMaxRecreateStateDepth
+	arbConfig.MaxRecreateStatePersistInterval = interval
+	arbConfig.MaxRecreateStatePersistCount = count
+	backend := &APIBackend{
+		b:             &Backend{arb: &stubArbInterface{chain: chain}, config: &arbConfig, chainDb: db},
+		dbForAPICalls: db,
+	}
+	if interval > 0 {
+		backend.b.recreatedStates = newRecreatedStates(db, interval, count)
+	}
+	return backend, blocks, processor
+}
+
+func TestRecreatedStatesKept(t *testing.T) {
+	for _, interval := range []uint64{0, 8} {
+		backend, blocks, processor := newRecreatedStatesTestBackend(t, interval, 2)
+
+		// recreate returns the number of blocks processed to recreate the
+		// state of the given block
+		recreate := func(number uint64) int {
+			t.Helper()
+			processor.processed = 0
+			statedb, header, err := backend.StateAndHeaderByNumber(context.Background(), rpc.BlockNumber(number))
+			if err != nil {
+				t.Fatalf("interval %d: failed to recreate state %d: %v", interval, number, err)
+			}
+			if statedb.IntermediateRoot(true) != header.Root {
+				t.Fatalf("interval %d: state %d root mismatch", interval, number)
+			}
+			return processor.processed
+		}
+		// Without kept states, both queries recreate from block 1
+		want := []int{20, 18}
+		if interval > 0 {
+			// The second query starts from the state kept at block 16
+			want = []int{20, 3}
+		}
+		if have := []int{recreate(21), recreate(19)}; have[0] != want[0] || have[1] != want[1] {
+			t.Errorf("interval %d: processed blocks mismatch: have %v, want %v", interval, have, want)
+		}
+		if interval == 0 {
+			continue
+		}
+		recreated := backend.b.recreatedStates
+		if !recreated.has(blocks[15].Hash()) || !recreated.has(blocks[7].Hash()) {
+			t.Fatal("intermediate states not kept")
+		}
+		// Kept states are reported as recreatable from there
+		res, err := NewArbStateAvailabilityAPI(backend).GetStateAvailability(context.Background(), rpc.BlockNumberOrHashWithNumber(18))
+		if err != nil || res.Status != StateRecreatable || res.Depth == nil || *res.Depth != 2 {
+			t.Errorf("state availability mismatch: have %+v, err %v", res, err)
+		}
+		// Only the most recently used states are kept, the evicted ones are
+		// dropped from memory. The recreation may start from a state still
+		// held by an earlier query, but goes past block 24 either way.
+		recreate(26)
+		if recreated.len() != 2 || !recreated.has(blocks[15].Hash()) || !recreated.has(blocks[23].Hash()) {
+			t.Fatalf("kept states mismatch: %d kept", recreated.len())
+		}
+		if _, err := state.New(blocks[7].Root(), recreated.db, nil); err == nil {
+			t.Error("evicted state still available")
+		}
+		// Closing drops the remaining ones
+		recreated.close()
+		if recreated.len() != 0 {
+			t.Fatalf("kept states mismatch after close: %d kept", recreated.len())
+		}
+		if _, err := state.New(blocks[15].Root(), recreated.db, nil); err == nil {
+			t.Error("kept state still available after close")
+		}
+	}
+}
+
+// Tests that states pinned by a recreation are not evicted, and that no state is
+// kept beyond the limit while all of them are pinned.
+func TestRecreatedStatesPinned(t *testing.T) {
+	recreated := newRecreatedStates(rawdb.NewMemoryDatabase(), 1, 2)
+	defer recreated.close()
+
+	headers := make([]*types.Header, 4)
+	for i := range headers {
+		headers[i] = &types.Header{Number: big.NewInt(int64(i + 1)), Root: common.Hash{byte(i + 1)}}
+	}
+	if !recreated.keep(headers[0]) || !recreated.keep(headers[1]) {
+		t.Fatal("failed to keep states")
+	}
+
+	// The oldest state is pinned, so the next one is evicted instead
+	unpin0, ok := recreated.pin(headers[0].Hash())
+	if !ok {
+		t.Fatal("failed to pin kept state")
+	}
+	if !recreated.keep(headers[2]) {
+		t.Fatal("failed to keep state")
+	}
+	if !recreated.has(headers[0].Hash()) || recreated.has(headers[1].Hash()) || !recreated.has(headers[2].Hash()) {
+		t.Fatal("pinned state evicted")
+	}
+	// With all the states pinned, new ones aren't kept
+	unpin2, _ := recreated.pin(headers[2].Hash())
+	if recreated.keep(headers[3]) {
+		t.Fatal("state kept with all states pinned")
+	}
+	if recreated.len() != 2 || recreated.has(headers[3].Hash()) {
+		t.Fatalf("state kept beyond the limit: %d kept", recreated.len())
+	}
+	// Once released, the states can be evicted again
+	unpin0()
+	unpin2()
+	recreated.keep(headers[3])
+	if recreated.has(headers[0].Hash()) || !recreated.has(headers[3].Hash()) {
+		t.Fatal("released state not evicted")
+	}
+	if _, ok := recreated.pin(headers[1].Hash()); ok {
+		t.Fatal("evicted state pinned")
+	}
+}
+
+// Tests that recreating a state across states which can't be kept, as all the
+// kept ones are pinned, keeps them referenced while they're built upon.
+func TestRecreatedStatesAllPinned(t *testing.T) {
+	backend, blocks, _ := newRecreatedStatesTestBackend(t, 4, 1)
+	recreated := backend.b.recreatedStates
+	defer recreated.close()
+
+	recreate := func(number uint64) {
+		t.Helper()
+		statedb, header, err := backend.StateAndHeaderByNumber(context.Background(), rpc.BlockNumber(number))
+		if err != nil {
+			t.Fatalf("failed to recreate state %d: %v", number, err)
+		}
+		if statedb.IntermediateRoot(true) != header.Root {
+			t.Fatalf("state %d root mismatch", number)
+		}
+	}
+	recreate(6)
+	if !recreated.has(blocks[3].Hash()) {
+		t.Fatal("intermediate state not kept")
+	}
+	unpin, _ := recreated.pin(blocks[3].Hash())
+	defer unpin()
+
+	// The states of blocks 8 to 24 are built in turn without being kept
+	recreate(26)
+	if recreated.len() != 1 || !recreated.has(blocks[3].Hash()) {
+		t.Fatalf("kept states mismatch: %d kept", recreated.len())
+	}
+}
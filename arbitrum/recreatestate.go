@@ -42,13 +42,11 @@ func FindLastAvailableState(ctx context.Context, bc *core.BlockChain, stateFor S
 			break
 		}
 		if maxDepthInL2Gas > 0 {
-			receipts := bc.GetReceiptsByHash(currentHeader.Hash())
-			if receipts == nil {
-				return nil, lastHeader, nil, fmt.Errorf("failed to get receipts for hash %v", currentHeader.Hash())
-			}
-			for _, receipt := range receipts {
-				l2GasUsed += receipt.GasUsed - receipt.GasUsedForL1
+			hash, number := currentHeader.Hash(), currentHeader.Number.Uint64()
+			if !bc.HasReceipts(hash, number) {
+				return nil, lastHeader, nil, fmt.Errorf("failed to get receipts for hash %v", hash)
 			}
+			l2GasUsed += bc.L2GasUsed(hash, number)
 			if l2GasUsed > uint64(maxDepthInL2Gas) {
 				return nil, lastHeader, nil, ErrDepthLimitExceeded
 			}
@@ -0,0 +1,149 @@
+package arbitrum
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	sendTxInFlightGauge   = metrics.NewRegisteredGauge("arb/sendtx/inflight", nil)
+	sendTxEnqueueTimer    = metrics.NewRegisteredTimer("arb/sendtx/enqueue", nil)
+	sendTxBackloggedMeter = metrics.NewRegisteredMeter("arb/sendtx/backlogged", nil)
+)
+
+// SequencerBackloggedError is returned when a transaction is rejected because
+// too many transactions are already being enqueued to the sequencer.
+type SequencerBackloggedError struct {
+	RetryAfter time.Duration // Hint on how long to wait before resubmitting
+	PerSender  bool          // Whether the limit hit was the per-sender one
+}
+
+func (e *SequencerBackloggedError) Error() string {
+	if e.PerSender {
+		return fmt.Sprintf("sequencer backlogged: too many transactions in flight from sender, retry after %v", e.RetryAfter)
+	}
+	return fmt.Sprintf("sequencer backlogged, retry after %v", e.RetryAfter)
+}
+
+func (e *SequencerBackloggedError) ErrorCode() int { return -32005 } // Limit exceeded
+
+// ErrorData returns the retry hint in whole seconds, as in a Retry-After header.
+func (e *SequencerBackloggedError) ErrorData() interface{} {
+	return map[string]interface{}{"retryAfter": uint64(math.Ceil(e.RetryAfter.Seconds()))}
+}
+
+// sendTxLimiter bounds the number of transactions concurrently being enqueued
+// to the sequencer, overall and per sender, so that callers get an early error
+// rather than piling up until they time out when the sequencer falls behind.
+type sendTxLimiter struct {
+	mu           sync.Mutex
+	maxInFlight  int // 0 = no limit
+	maxPerSender int // 0 = no limit
+	retryAfter   time.Duration
+	inFlight     int
+	senders      map[common.Address]int
+}
+
+func newSendTxLimiter(maxInFlight, maxPerSender int, retryAfter time.Duration) *sendTxLimiter {
+	return &sendTxLimiter{
+		maxInFlight:  maxInFlight,
+		maxPerSender: maxPerSender,
+		retryAfter:   retryAfter,
+		senders:      make(map[common.Address]int),
+	}
+}
+
+// acquire reserves a slot for a transaction from sender, returning the function
+// to release it once the transaction is enqueued, or an error if the limits are
+// exceeded. A nil sender, for a transaction whose sender can't be recovered, is
+// only subject to the overall limit.
+func (l *sendTxLimiter) acquire(sender *common.Address) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxInFlight != 0 && l.inFlight >= l.maxInFlight {
+		sendTxBackloggedMeter.Mark(1)
+		return nil, &SequencerBackloggedError{RetryAfter: l.retryAfter}
+	}
+	if sender != nil && l.maxPerSender != 0 && l.senders[*sender] >= l.maxPerSender {
+		sendTxBackloggedMeter.Mark(1)
+		return nil, &SequencerBackloggedError{RetryAfter: l.retryAfter, PerSender: true}
+	}
+	l.inFlight++
+	if sender != nil {
+		l.senders[*sender]++
+	}
+	sendTxInFlightGauge.Update(int64(l.inFlight))
+
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			sendTxEnqueueTimer.UpdateSince(start)
+			l.release(sender)
+		})
+	}, nil
+}
+
+func (l *sendTxLimiter) release(sender *common.Address) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	if sender != nil {
+		if l.senders[*sender]--; l.senders[*sender] <= 0 {
+			delete(l.senders, *sender)
+		}
+	}
+	sendTxInFlightGauge.Update(int64(l.inFlight))
+}
+
+// SendTxBacklog is the result of admin_getSendTxBacklog.
+type SendTxBacklog struct {
+	InFlight             hexutil.Uint64                    `json:"inFlight"`
+	MaxInFlight          hexutil.Uint64                    `json:"maxInFlight"`
+	MaxInFlightPerSender hexutil.Uint64                    `json:"maxInFlightPerSender"`
+	Senders              map[common.Address]hexutil.Uint64 `json:"senders"`
+}
+
+func (l *sendTxLimiter) backlog() *SendTxBacklog {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	backlog := &SendTxBacklog{
+		InFlight:             hexutil.Uint64(l.inFlight),
+		MaxInFlight:          hexutil.Uint64(l.maxInFlight),
+		MaxInFlightPerSender: hexutil.Uint64(l.maxPerSender),
+		Senders:              make(map[common.Address]hexutil.Uint64, len(l.senders)),
+	}
+	for sender, count := range l.senders {
+		backlog.Senders[sender] = hexutil.Uint64(count)
+	}
+	return backlog
+}
+
+// ArbSendTxAdminAPI offers administrative RPC methods for inspecting the
+// transactions being enqueued to the sequencer.
+type ArbSendTxAdminAPI struct {
+	b *Backend
+}
+
+// NewArbSendTxAdminAPI creates a new send transaction admin API instance.
+func NewArbSendTxAdminAPI(b *Backend) *ArbSendTxAdminAPI {
+	return &ArbSendTxAdminAPI{b}
+}
+
+// GetSendTxBacklog returns the number of transactions currently being enqueued
+// to the sequencer, in total and per sender, along with the configured limits.
+func (api *ArbSendTxAdminAPI) GetSendTxBacklog() *SendTxBacklog {
+	if api.b.sendTxLimiter == nil {
+		return &SendTxBacklog{Senders: map[common.Address]hexutil.Uint64{}}
+	}
+	return api.b.sendTxLimiter.backlog()
+}
@@ -0,0 +1,172 @@
+package arbitrum
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/arbitrum_types"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// slowArbInterface holds every published transaction until released.
+type slowArbInterface struct {
+	stubArbInterface
+	published chan struct{}
+	release   chan struct{}
+}
+
+func (s *slowArbInterface) PublishTransaction(ctx context.Context, tx *types.Transaction, options *arbitrum_types.ConditionalOptions) error {
+	s.published <- struct{}{}
+	select {
+	case <-s.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newSendTxLimiterTestBackend(t *testing.T, maxInFlight, maxPerSender int) (*APIBackend, *slowArbInterface) {
+	var (
+		engine  = ethash.NewFaker()
+		genesis = &core.Genesis{
+			Config:  params.TestChainConfig,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+	)
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), nil, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	t.Cleanup(chain.Stop)
+
+	arb := &slowArbInterface{
+		stubArbInterface: stubArbInterface{chain: chain},
+		published:        make(chan struct{}),
+		release:          make(chan struct{}),
+	}
+	backend := &APIBackend{
+		b: &Backend{
+			arb:           arb,
+			pendingNonces: newPendingNonces(mclock.System{}, pendingNonceTimeout, pendingNonceLimit),
+			pendingTxs:    newPendingTxs(mclock.System{}, pendingTxTimeout, pendingTxLimit),
+			sendTxLimiter: newSendTxLimiter(maxInFlight, maxPerSender, 3*time.Second),
+		},
+	}
+	return backend, arb
+}
+
+func TestSendTxBackPressure(t *testing.T) {
+	backend, arb := newSendTxLimiterTestBackend(t, 2, 1)
+	var (
+		signer  = types.LatestSigner(arb.chain.Config())
+		key1, _ = crypto.GenerateKey()
+		key2, _ = crypto.GenerateKey()
+		key3, _ = crypto.GenerateKey()
+		sender1 = crypto.PubkeyToAddress(key1.PublicKey)
+		sender2 = crypto.PubkeyToAddress(key2.PublicKey)
+		errs    = make(chan error, 2)
+		signTx  = func(key *ecdsa.PrivateKey, nonce uint64) *types.Transaction {
+			return types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: nonce, Gas: params.TxGas, GasPrice: big.NewInt(params.InitialBaseFee)})
+		}
+	)
+	checkBacklogged := func(err error, perSender bool) {
+		t.Helper()
+		var rpcErr rpc.Error
+		if !errors.As(err, &rpcErr) || rpcErr.ErrorCode() != -32005 {
+			t.Fatalf("expected backlogged rpc error, got %v", err)
+		}
+		backlogged := err.(*SequencerBackloggedError)
+		if backlogged.PerSender != perSender {
+			t.Errorf("per-sender mismatch: have %v, want %v", backlogged.PerSender, perSender)
+		}
+		data := backlogged.ErrorData().(map[string]interface{})
+		if data["retryAfter"] != uint64(3) {
+			t.Errorf("retry hint mismatch: have %v, want 3", data["retryAfter"])
+		}
+	}
+
+	// Fill up the per-sender limit of the first sender
+	go func() { errs <- backend.SendTx(context.Background(), signTx(key1, 0)) }()
+	<-arb.published
+	checkBacklogged(backend.SendTx(context.Background(), signTx(key1, 1)), true)
+
+	// Fill up the node limit through the conditional transaction path
+	go func() {
+		errs <- backend.SendConditionalTx(context.Background(), signTx(key2, 0), &arbitrum_types.ConditionalOptions{})
+	}()
+	<-arb.published
+	checkBacklogged(backend.SendTx(context.Background(), signTx(key3, 0)), false)
+	checkBacklogged(backend.SendConditionalTx(context.Background(), signTx(key3, 0), &arbitrum_types.ConditionalOptions{}), false)
+
+	backlog := NewArbSendTxAdminAPI(backend.b).GetSendTxBacklog()
+	if backlog.InFlight != 2 || backlog.Senders[sender1] != 1 || backlog.Senders[sender2] != 1 {
+		t.Errorf("unexpected backlog: %+v", backlog)
+	}
+
+	// Release the pending transactions and check the backlog drains
+	close(arb.release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("failed to send transaction: %v", err)
+		}
+	}
+	if backlog := NewArbSendTxAdminAPI(backend.b).GetSendTxBacklog(); backlog.InFlight != 0 || len(backlog.Senders) != 0 {
+		t.Errorf("backlog not drained: %+v", backlog)
+	}
+}
+
+// Tests that transactions whose sender can't be recovered aren't lumped
+// together under a single sender, but still count towards the node limit.
+func TestSendTxBackPressureUnknownSender(t *testing.T) {
+	backend, arb := newSendTxLimiterTestBackend(t, 2, 1)
+
+	// Sign for a different chain, so the sender can't be recovered
+	var (
+		key, _ = crypto.GenerateKey()
+		signer = types.LatestSignerForChainID(big.NewInt(1337))
+		errs   = make(chan error, 2)
+	)
+	signTx := func(nonce uint64) *types.Transaction {
+		return types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: nonce, Gas: params.TxGas, GasPrice: big.NewInt(params.InitialBaseFee)})
+	}
+	if _, err := types.Sender(types.LatestSigner(arb.chain.Config()), signTx(0)); err == nil {
+		t.Fatal("expected sender recovery to fail")
+	}
+	for i := uint64(0); i < 2; i++ {
+		go func(nonce uint64) { errs <- backend.SendTx(context.Background(), signTx(nonce)) }(i)
+		select {
+		case <-arb.published:
+		case err := <-errs:
+			t.Fatalf("transaction %d not published: %v", i, err)
+		}
+	}
+	backlog := NewArbSendTxAdminAPI(backend.b).GetSendTxBacklog()
+	if backlog.InFlight != 2 || len(backlog.Senders) != 0 {
+		t.Errorf("unexpected backlog: %+v", backlog)
+	}
+	err := backend.SendTx(context.Background(), signTx(2))
+	if backlogged, ok := err.(*SequencerBackloggedError); !ok || backlogged.PerSender {
+		t.Fatalf("expected node backlogged error, got %v", err)
+	}
+	close(arb.release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("failed to send transaction: %v", err)
+		}
+	}
+	if backlog := NewArbSendTxAdminAPI(backend.b).GetSendTxBacklog(); backlog.InFlight != 0 {
+		t.Errorf("backlog not drained: %+v", backlog)
+	}
+}
@@ -0,0 +1,106 @@
+package arbitrum
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// The ways the state of a block can be served, from the cheapest to the most
+// expensive one.
+const (
+	StateLive            = "live"            // Held in the in-memory trie cache
+	StateCommitted       = "committed"       // Persisted to the database
+	StateRecreatable     = "recreatable"     // Recreated by re-executing blocks
+	StateArchiveFallback = "archiveFallback" // Pre-Nitro, served by the fallback client
+	StateUnavailable     = "unavailable"
+)
+
+// StateAvailability is the result of arb_getStateAvailability.
+type StateAvailability struct {
+	BlockHash   common.Hash     `json:"blockHash,omitempty"`
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+	Status      string          `json:"status"`
+	Depth       *hexutil.Uint64 `json:"depth,omitempty"` // Blocks to re-execute, for recreatable states
+}
+
+// ArbStateAvailabilityAPI lets clients find out how expensive serving the
+// state of a block would be, so they can decide whether to query it now.
+type ArbStateAvailabilityAPI struct {
+	b *APIBackend
+}
+
+// NewArbStateAvailabilityAPI creates a new state availability API instance.
+func NewArbStateAvailabilityAPI(b *APIBackend) *ArbStateAvailabilityAPI {
+	return &ArbStateAvailabilityAPI{b}
+}
+
+// GetStateAvailability reports how the state of the given block would be
+// served, without recreating or loading any of it.
+func (api *ArbStateAvailabilityAPI) GetStateAvailability(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*StateAvailability, error) {
+	bc := api.b.BlockChain()
+
+	// Pre-Nitro blocks aren't stored locally, don't look them up
+	if number, ok := blockNrOrHash.Number(); ok && number >= 0 && uint64(number) < bc.Config().ArbitrumChainParams.GenesisBlockNum {
+		return &StateAvailability{BlockNumber: hexutil.Uint64(number), Status: api.classicStatus()}, nil
+	}
+	header, err := api.b.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, errors.New("header not found")
+	}
+	result := &StateAvailability{
+		BlockHash:   header.Hash(),
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+	}
+	switch {
+	case !bc.Config().IsArbitrumNitro(header.Number):
+		result.Status = api.classicStatus()
+
+	case bc.HasState(header.Root):
+		if bc.TrieGCContains(header.Root) {
+			result.Status = StateLive
+		} else {
+			result.Status = StateCommitted
+		}
+
+	default:
+		// Look for the state recreation would start from the same way
+		// StateAndHeaderFromHeader does, only checking the states exist
+		ephemeral := state.NewDatabaseWithConfig(api.b.ChainDb(), triedb.HashDefaults)
+		recreated := api.b.b.recreatedStates
+		hasState := func(header *types.Header) (*state.StateDB, StateReleaseFunc, error) {
+			if recreated != nil && recreated.has(header.Hash()) {
+				return nil, NoopStateRelease, nil
+			}
+			_, err := ephemeral.OpenTrie(header.Root)
+			return nil, NoopStateRelease, err
+		}
+		_, lastHeader, _, err := FindLastAvailableState(ctx, bc, hasState, header, nil, api.b.b.config.MaxRecreateStateDepth)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err != nil {
+			result.Status = StateUnavailable
+		} else {
+			depth := hexutil.Uint64(header.Number.Uint64() - lastHeader.Number.Uint64())
+			result.Status, result.Depth = StateRecreatable, &depth
+		}
+	}
+	return result, nil
+}
+
+func (api *ArbStateAvailabilityAPI) classicStatus() string {
+	if api.b.fallbackClient == nil {
+		return StateUnavailable
+	}
+	return StateArchiveFallback
+}
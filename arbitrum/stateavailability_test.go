@@ -0,0 +1,108 @@
+package arbitrum
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+func TestStateAvailability(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		config  = *params.TestChainConfig
+		genesis = &core.Genesis{
+			Config:  &config,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		cacheConfig = *core.DefaultCacheConfigWithScheme(rawdb.HashScheme)
+	)
+	config.ArbitrumChainParams.EnableArbOS = true
+	_, blocks, _ := core.GenerateChainWithGenesis(genesis, engine, 12, nil)
+
+	// Sparse archive committing the state of every fourth block, starting at
+	// block 1, and keeping the last two states in memory
+	cacheConfig.TrieDirtyDisabled = true
+	cacheConfig.MaxNumberOfBlocksToSkipStateSaving = 3
+	cacheConfig.TriesInMemory = 2
+	cacheConfig.TrieRetention = 0
+
+	db := rawdb.NewMemoryDatabase()
+	genesis.MustCommit(db, triedb.NewDatabase(db, triedb.HashDefaults))
+	chain, err := core.NewBlockChain(db, &cacheConfig, &config, nil, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer func() { chain.Stop() }()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	newBackend := func(chain *core.BlockChain, maxRecreateStateDepth int64) *APIBackend {
+		arbConfig := DefaultConfig
+		arbConfig.MaxRecreateStateDepth = maxRecreateStateDepth
+		return &APIBackend{
+			b:             &Backend{arb: &stubArbInterface{chain: chain}, config: &arbConfig, chainDb: db},
+			dbForAPICalls: db,
+		}
+	}
+	backend := newBackend(chain, DefaultArchiveNodeMaxRecreateStateDepth)
+
+	check := func(backend *APIBackend, blockNrOrHash rpc.BlockNumberOrHash, status string, depth uint64) {
+		t.Helper()
+		api := NewArbStateAvailabilityAPI(backend)
+		res, err := api.GetStateAvailability(context.Background(), blockNrOrHash)
+		if err != nil {
+			t.Fatalf("%v: failed to get state availability: %v", blockNrOrHash, err)
+		}
+		if res.Status != status {
+			t.Errorf("%v: status mismatch: have %s, want %s", blockNrOrHash, res.Status, status)
+		}
+		if (res.Depth == nil) != (status != StateRecreatable) || (res.Depth != nil && uint64(*res.Depth) != depth) {
+			t.Errorf("%v: depth mismatch: have %v, want %d", blockNrOrHash, res.Depth, depth)
+		}
+	}
+	byNumber := func(number uint64) rpc.BlockNumberOrHash {
+		return rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(number))
+	}
+	for _, number := range []uint64{0, 1, 5, 9} {
+		check(backend, byNumber(number), StateCommitted, 0)
+	}
+	for _, number := range []uint64{11, 12} {
+		check(backend, byNumber(number), StateLive, 0)
+	}
+	check(backend, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), StateLive, 0)
+	check(backend, byNumber(4), StateRecreatable, 3)
+	check(backend, byNumber(7), StateRecreatable, 2)
+	check(backend, rpc.BlockNumberOrHashWithHash(blocks[9].Hash(), false), StateRecreatable, 1)
+
+	// Nothing was recreated, but the states classified as such can be
+	if _, _, err := backend.StateAndHeaderByNumber(context.Background(), 7); err != nil {
+		t.Fatalf("failed to recreate state: %v", err)
+	}
+	// A node not recreating states can't serve them
+	noRecreate := newBackend(chain, 0)
+	check(noRecreate, byNumber(7), StateUnavailable, 0)
+	check(noRecreate, byNumber(9), StateCommitted, 0)
+
+	// Pre-Nitro states are only available through the fallback client. Reopen
+	// the chain with the first five blocks predating its Nitro genesis.
+	chain.Stop()
+	nitroConfig := config
+	nitroConfig.ArbitrumChainParams.GenesisBlockNum = 5
+	chain, err = core.NewBlockChain(db, &cacheConfig, &nitroConfig, nil, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen chain: %v", err)
+	}
+	nitro := newBackend(chain, DefaultArchiveNodeMaxRecreateStateDepth)
+	check(nitro, byNumber(3), StateUnavailable, 0)
+	nitro.fallbackClient = &sleepyFallbackClient{}
+	check(nitro, byNumber(3), StateArchiveFallback, 0)
+	check(nitro, rpc.BlockNumberOrHashWithHash(blocks[2].Hash(), false), StateArchiveFallback, 0)
+}
@@ -0,0 +1,85 @@
+package arbitrum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/native"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const storageGrowthTracer = "storageGrowthTracer"
+
+// TxStorageGrowth is the storage growth caused by a single transaction.
+type TxStorageGrowth struct {
+	TxHash common.Hash `json:"txHash"`
+	*native.StorageGrowth
+}
+
+// StorageGrowthProof lists the accounts and storage slots created by the
+// transactions of a block, both per transaction and aggregated over the block.
+type StorageGrowthProof struct {
+	BlockHash    common.Hash          `json:"blockHash"`
+	BlockNumber  hexutil.Uint64       `json:"blockNumber"`
+	Transactions []TxStorageGrowth    `json:"transactions"`
+	Total        native.StorageGrowth `json:"total"`
+}
+
+// ArbStorageGrowthAPI reports the storage growth of blocks by re-executing
+// them with the storageGrowthTracer.
+type ArbStorageGrowthAPI struct {
+	b      *APIBackend
+	tracer *tracers.API
+}
+
+// NewArbStorageGrowthAPI creates a new storage growth API instance.
+func NewArbStorageGrowthAPI(b *APIBackend) *ArbStorageGrowthAPI {
+	return &ArbStorageGrowthAPI{b: b, tracer: tracers.NewAPI(b)}
+}
+
+// GetStorageGrowthProof returns the accounts and storage slots created by each
+// transaction of the given block, along with their union over the whole block.
+func (api *ArbStorageGrowthAPI) GetStorageGrowthProof(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*StorageGrowthProof, error) {
+	block, err := api.b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errors.New("block not found")
+	}
+	tracer := storageGrowthTracer
+	results, err := api.tracer.TraceBlockByHash(ctx, block.Hash(), &tracers.TraceConfig{Tracer: &tracer})
+	if err != nil {
+		return nil, err
+	}
+	proof := &StorageGrowthProof{
+		BlockHash:    block.Hash(),
+		BlockNumber:  hexutil.Uint64(block.NumberU64()),
+		Transactions: make([]TxStorageGrowth, 0, len(results)),
+		Total: native.StorageGrowth{
+			Accounts: []common.Address{},
+			Storage:  make(map[common.Address][]common.Hash),
+		},
+	}
+	for _, res := range results {
+		if res.Error != "" {
+			return nil, fmt.Errorf("failed to trace transaction %v: %s", res.TxHash, res.Error)
+		}
+		enc, err := json.Marshal(res.Result)
+		if err != nil {
+			return nil, err
+		}
+		growth := new(native.StorageGrowth)
+		if err := json.Unmarshal(enc, growth); err != nil {
+			return nil, err
+		}
+		proof.Transactions = append(proof.Transactions, TxStorageGrowth{TxHash: res.TxHash, StorageGrowth: growth})
+		proof.Total.Merge(growth)
+	}
+	return proof, nil
+}
@@ -20,3 +20,8 @@ package lru
 func (c *BasicLRU[K, V]) Capacity() int {
 	return c.cap
 }
+
+// Capacity returns the maximum number of items the cache can hold.
+func (c *Cache[K, V]) Capacity() int {
+	return c.cache.Capacity()
+}
@@ -87,12 +87,25 @@ var (
 	blockReorgAddMeter  = metrics.NewRegisteredMeter("chain/reorg/add", nil)
 	blockReorgDropMeter = metrics.NewRegisteredMeter("chain/reorg/drop", nil)
 
+	stateSavingSkippedCounter = metrics.NewRegisteredCounter("chain/statesaving/skipped", nil)
+
+	bodyCacheHitMeter      = metrics.NewRegisteredMeter("chain/cache/bodies/hits", nil)
+	bodyCacheMissMeter     = metrics.NewRegisteredMeter("chain/cache/bodies/misses", nil)
+	blockCacheHitMeter     = metrics.NewRegisteredMeter("chain/cache/blocks/hits", nil)
+	blockCacheMissMeter    = metrics.NewRegisteredMeter("chain/cache/blocks/misses", nil)
+	receiptsCacheHitMeter  = metrics.NewRegisteredMeter("chain/cache/receipts/hits", nil)
+	receiptsCacheMissMeter = metrics.NewRegisteredMeter("chain/cache/receipts/misses", nil)
+	txLookupCacheHitMeter  = metrics.NewRegisteredMeter("chain/cache/txlookups/hits", nil)
+	txLookupCacheMissMeter = metrics.NewRegisteredMeter("chain/cache/txlookups/misses", nil)
+
 	blockPrefetchExecuteTimer   = metrics.NewRegisteredTimer("chain/prefetch/executes", nil)
 	blockPrefetchInterruptMeter = metrics.NewRegisteredMeter("chain/prefetch/interrupts", nil)
 
 	errInsertionInterrupted = errors.New("insertion is interrupted")
 	errChainStopped         = errors.New("blockchain is stopped")
 	errInvalidOldChain      = errors.New("invalid old chain")
+	errNonCanonicalHeader   = errors.New("header is not canonical")
+	errFinalizedRewind      = errors.New("finalized block moves backwards")
 	errInvalidNewChain      = errors.New("invalid new chain")
 )
 
@@ -150,6 +163,17 @@ type CacheConfig struct {
 	MaxNumberOfBlocksToSkipStateSaving uint32
 	MaxAmountOfGasToSkipStateSaving    uint64
 
+	// Arbitrum: configure the in-memory chain data caches. Zero or negative
+	// values fall back to the package defaults.
+	BodyCacheLimit     int // Number of block bodies to keep in memory
+	BlockCacheLimit    int // Number of full blocks to keep in memory
+	ReceiptsCacheLimit int // Number of block receipt sets to keep in memory
+	TxLookupCacheLimit int // Number of transaction lookups to keep in memory
+
+	// Arbitrum: skip the sampling check of the receipts of an external ancient
+	// store the database is initialized from
+	SkipAncientReceiptsCheck bool
+
 	SnapshotNoBuild bool // Whether the background generation is allowed
 	SnapshotWait    bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
 }
@@ -189,6 +213,10 @@ var defaultCacheConfig = &CacheConfig{
 	TrieRetention:                      30 * time.Minute,
 	MaxNumberOfBlocksToSkipStateSaving: 0,
 	MaxAmountOfGasToSkipStateSaving:    0,
+	BodyCacheLimit:                     bodyCacheLimit,
+	BlockCacheLimit:                    blockCacheLimit,
+	ReceiptsCacheLimit:                 receiptsCacheLimit,
+	TxLookupCacheLimit:                 txLookupCacheLimit,
 
 	TrieCleanLimit: 256,
 	TrieDirtyLimit: 256,
@@ -234,8 +262,10 @@ type BlockChain struct {
 	db            ethdb.Database                   // Low level persistent database to store final content in
 	snaps         *snapshot.Tree                   // Snapshot tree for fast trie leaf access
 	triegc        *prque.Prque[int64, trieGcEntry] // Priority queue mapping block numbers to tries to gc
-	gcproc        time.Duration                    // Accumulates canonical block processing for trie dumping
-	lastWrite     uint64                           // Last block when the state was flushed
+	triegcRoots   map[common.Hash]int              // Arbitrum: number of triegc entries per root, guarded by triegcLock
+	triegcLock    sync.Mutex                       // Arbitrum: lets triegcRoots be read without the chain mutex
+	gcproc        atomic.Int64                     // Accumulates canonical block processing for trie dumping
+	lastWrite     atomic.Uint64                    // Last block when the state was flushed
 	flushInterval atomic.Int64                     // Time interval (processing time) after which to flush a state
 	triedb        *triedb.Database                 // The database handler for maintaining trie nodes.
 	stateCache    state.Database                   // State database to reuse between imports (contains state cache)
@@ -248,7 +278,11 @@ type BlockChain struct {
 	chainHeadFeed event.Feed
 	logsFeed      event.Feed
 	blockProcFeed event.Feed
+	insertFeed    event.Feed
+	finalizedFeed event.Feed
 	scope         event.SubscriptionScope
+	rmLogsScope   event.SubscriptionScope // Tracks the removed log subscribers, to skip collecting logs without any
+	logsScope     event.SubscriptionScope // Tracks the log subscribers, to skip collecting logs without any
 	genesisBlock  *types.Block
 
 	// This mutex synchronizes chain write operations.
@@ -264,6 +298,7 @@ type BlockChain struct {
 	bodyRLPCache  *lru.Cache[common.Hash, rlp.RawValue]
 	receiptsCache *lru.Cache[common.Hash, []*types.Receipt]
 	blockCache    *lru.Cache[common.Hash, *types.Block]
+	l2GasCache    *lru.Cache[common.Hash, uint64]
 
 	txLookupLock  sync.RWMutex
 	txLookupCache *lru.Cache[common.Hash, txLookup]
@@ -272,6 +307,8 @@ type BlockChain struct {
 	quit          chan struct{} // shutdown signal, closed in Stop.
 	stopping      atomic.Bool   // false if chain is running, true when stopped
 	procInterrupt atomic.Bool   // interrupt signaler for block processing
+	procCounter   atomic.Int32  // number of block imports in progress, for blockProcFeed
+	procLock      sync.Mutex    // serializes procCounter updates with their blockProcFeed sends
 
 	engine     consensus.Engine
 	validator  Validator // Block and state validator interface
@@ -283,6 +320,7 @@ type BlockChain struct {
 
 	numberOfBlocksToSkipStateSaving      uint32
 	amountOfGasInBlocksToSkipStateSaving uint64
+	lastStateSavingDecision              StateSavingDecision
 }
 
 type trieGcEntry struct {
@@ -294,9 +332,20 @@ type trieGcEntry struct {
 // available in the database. It initialises the default Ethereum Validator
 // and Processor.
 func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, genesis *Genesis, overrides *ChainOverrides, engine consensus.Engine, vmConfig vm.Config, shouldPreserve func(header *types.Header) bool, txLookupLimit *uint64) (*BlockChain, error) {
+	return NewBlockChainWithProcessors(db, cacheConfig, chainConfig, genesis, overrides, engine, vmConfig, shouldPreserve, txLookupLimit, nil, nil)
+}
+
+// NewBlockChainWithProcessors is like NewBlockChain, but creates the Validator
+// and Processor with the given factories, if not nil, so custom ones are in
+// place before any block is handled.
+func NewBlockChainWithProcessors(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, genesis *Genesis, overrides *ChainOverrides, engine consensus.Engine, vmConfig vm.Config, shouldPreserve func(header *types.Header) bool, txLookupLimit *uint64, newValidator ValidatorFactory, newProcessor ProcessorFactory) (*BlockChain, error) {
 	if cacheConfig == nil {
 		cacheConfig = defaultCacheConfig
 	}
+	// Arbitrum: reject inconsistent state retention settings upfront
+	if err := cacheConfig.Validate(); err != nil {
+		return nil, err
+	}
 	// Open trie database with provided config
 	triedb := triedb.NewDatabase(db, cacheConfig.triedbConfig(genesis != nil && genesis.IsVerkle()))
 
@@ -304,10 +353,16 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	var genesisErr error
 
 	if chainConfig != nil && chainConfig.IsArbitrum() {
-		genesisHash = rawdb.ReadCanonicalHash(db, chainConfig.ArbitrumChainParams.GenesisBlockNum)
-		if genesisHash == (common.Hash{}) {
-			return nil, ErrNoGenesis
+		if overrides != nil && overrides.OverrideMultiGas != nil {
+			config := *chainConfig
+			config.ArbitrumChainParams.MultiGasActivationTime = overrides.OverrideMultiGas
+			chainConfig = &config
 		}
+		genesisHash, genesisErr = readArbitrumGenesisHash(db, chainConfig)
+		if genesisErr != nil {
+			return nil, genesisErr
+		}
+		genesisErr = checkMultiGasCompatible(db, genesisHash, chainConfig)
 	} else {
 		// Setup the genesis block, commit the provided genesis specification
 		// to database if the genesis block is not present yet, or load the
@@ -331,13 +386,15 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		db:            db,
 		triedb:        triedb,
 		triegc:        prque.New[int64, trieGcEntry](nil),
+		triegcRoots:   make(map[common.Hash]int),
 		quit:          make(chan struct{}),
 		chainmu:       syncx.NewClosableMutex(),
-		bodyCache:     lru.NewCache[common.Hash, *types.Body](bodyCacheLimit),
-		bodyRLPCache:  lru.NewCache[common.Hash, rlp.RawValue](bodyCacheLimit),
-		receiptsCache: lru.NewCache[common.Hash, []*types.Receipt](receiptsCacheLimit),
-		blockCache:    lru.NewCache[common.Hash, *types.Block](blockCacheLimit),
-		txLookupCache: lru.NewCache[common.Hash, txLookup](txLookupCacheLimit),
+		bodyCache:     lru.NewCache[common.Hash, *types.Body](cacheLimitOrDefault(cacheConfig.BodyCacheLimit, bodyCacheLimit)),
+		bodyRLPCache:  lru.NewCache[common.Hash, rlp.RawValue](cacheLimitOrDefault(cacheConfig.BodyCacheLimit, bodyCacheLimit)),
+		receiptsCache: lru.NewCache[common.Hash, []*types.Receipt](cacheLimitOrDefault(cacheConfig.ReceiptsCacheLimit, receiptsCacheLimit)),
+		blockCache:    lru.NewCache[common.Hash, *types.Block](cacheLimitOrDefault(cacheConfig.BlockCacheLimit, blockCacheLimit)),
+		txLookupCache: lru.NewCache[common.Hash, txLookup](cacheLimitOrDefault(cacheConfig.TxLookupCacheLimit, txLookupCacheLimit)),
+		l2GasCache:    lru.NewCache[common.Hash, uint64](l2GasCacheLimit),
 		engine:        engine,
 		vmConfig:      vmConfig,
 		logger:        vmConfig.Tracer,
@@ -345,9 +402,17 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	bc.flushInterval.Store(int64(cacheConfig.TrieTimeLimit))
 	bc.forker = NewForkChoice(bc, shouldPreserve)
 	bc.stateCache = state.NewDatabaseWithNodeDB(bc.db, bc.triedb)
-	bc.validator = NewBlockValidator(chainConfig, bc, engine)
+	if newValidator != nil {
+		bc.validator = newValidator(chainConfig, bc, engine)
+	} else {
+		bc.validator = NewBlockValidator(chainConfig, bc, engine)
+	}
 	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
-	bc.processor = NewStateProcessor(chainConfig, bc, engine)
+	if newProcessor != nil {
+		bc.processor = newProcessor(chainConfig, bc, engine)
+	} else {
+		bc.processor = NewStateProcessor(chainConfig, bc, engine)
+	}
 
 	var err error
 	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.insertStopped)
@@ -375,12 +440,24 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	// missing chain indexes and chain flags. This procedure can survive crash
 	// and can be resumed in next restart since chain flags are updated in last step.
 	if bc.empty() {
+		// Arbitrum: reject corrupted or foreign ancient stores before indexing them
+		if !bc.cacheConfig.SkipAncientReceiptsCheck {
+			if err := bc.checkAncientReceipts(); err != nil {
+				return nil, err
+			}
+		}
 		rawdb.InitDatabaseFromFreezer(bc.db)
 	}
 	// Load blockchain states from disk
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
+	// The shutdown commit marker is only meaningful for the startup directly
+	// following that shutdown, consume it right away.
+	stopCommitHash := rawdb.ReadLastStopCommitHash(bc.db)
+	if stopCommitHash != (common.Hash{}) {
+		rawdb.DeleteLastStopCommitHash(bc.db)
+	}
 	// Make sure the state associated with the block is available, or log out
 	// if there is no available state, waiting for state sync.
 	head := bc.CurrentBlock()
@@ -414,7 +491,9 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 					log.Warn("Snapshot root not found or too far back. Recreating snapshot from scratch.")
 					rawdb.DeleteSnapshotRecoveryNumber(bc.db)
 				}
-			} else {
+			} else if repaired, err := bc.repairHeadFromStopMarker(head, stopCommitHash); err != nil {
+				return nil, err
+			} else if !repaired {
 				log.Warn("Head state missing, repairing", "number", head.Number, "hash", head.Hash())
 				if _, _, err := bc.setHeadBeyondRoot(head.Number.Uint64(), 0, common.Hash{}, true, 0); err != nil {
 					return nil, err
@@ -649,11 +728,42 @@ func (bc *BlockChain) SetHeadWithTimestamp(timestamp uint64) error {
 }
 
 // SetFinalized sets the finalized block.
+//
+// Deprecated: SetFinalized does not validate the header, use SetFinalizedChecked.
 func (bc *BlockChain) SetFinalized(header *types.Header) {
+	bc.setFinalized(header)
+}
+
+// SetFinalizedChecked sets the finalized block after ensuring that it is part
+// of the canonical chain and that it doesn't move the finalized block backwards.
+// The latter check can be skipped by setting force, which also permits clearing
+// the finalized block with a nil header.
+func (bc *BlockChain) SetFinalizedChecked(header *types.Header, force bool) error {
+	if header != nil {
+		if err := bc.checkCanonical(header); err != nil {
+			return err
+		}
+	}
+	if !force {
+		if current := bc.CurrentFinalBlock(); current != nil {
+			if header == nil {
+				return fmt.Errorf("%w: #%d -> none", errFinalizedRewind, current.Number)
+			}
+			if header.Number.Cmp(current.Number) < 0 {
+				return fmt.Errorf("%w: #%d -> #%d", errFinalizedRewind, current.Number, header.Number)
+			}
+		}
+	}
+	bc.setFinalized(header)
+	return nil
+}
+
+func (bc *BlockChain) setFinalized(header *types.Header) {
 	bc.currentFinalBlock.Store(header)
 	if header != nil {
 		rawdb.WriteFinalizedBlockHash(bc.db, header.Hash())
 		headFinalizedBlockGauge.Update(int64(header.Number.Uint64()))
+		bc.finalizedFeed.Send(FinalizedHeaderEvent{Header: header})
 	} else {
 		rawdb.WriteFinalizedBlockHash(bc.db, common.Hash{})
 		headFinalizedBlockGauge.Update(0)
@@ -661,7 +771,35 @@ func (bc *BlockChain) SetFinalized(header *types.Header) {
 }
 
 // SetSafe sets the safe block.
+//
+// Deprecated: SetSafe does not validate the header, use SetSafeChecked.
 func (bc *BlockChain) SetSafe(header *types.Header) {
+	bc.setSafe(header)
+}
+
+// SetSafeChecked sets the safe block after ensuring that it is part of the
+// canonical chain. A nil header clears the safe block.
+func (bc *BlockChain) SetSafeChecked(header *types.Header) error {
+	if header != nil {
+		if err := bc.checkCanonical(header); err != nil {
+			return err
+		}
+	}
+	bc.setSafe(header)
+	return nil
+}
+
+// checkCanonical returns an error if the header is not the canonical one at
+// its height.
+func (bc *BlockChain) checkCanonical(header *types.Header) error {
+	hash, number := header.Hash(), header.Number.Uint64()
+	if canonical := rawdb.ReadCanonicalHash(bc.db, number); canonical != hash {
+		return fmt.Errorf("%w: #%d [%x..], canonical [%x..]", errNonCanonicalHeader, number, hash.Bytes()[:4], canonical.Bytes()[:4])
+	}
+	return nil
+}
+
+func (bc *BlockChain) setSafe(header *types.Header) {
 	bc.currentSafeBlock.Store(header)
 	if header != nil {
 		headSafeBlockGauge.Update(int64(header.Number.Uint64()))
@@ -671,7 +809,7 @@ func (bc *BlockChain) SetSafe(header *types.Header) {
 }
 
 // rewindHashHead implements the logic of rewindHead in the context of hash scheme.
-func (bc *BlockChain) rewindHashHead(head *types.Header, root common.Hash, rewindLimit uint64) (*types.Header, uint64, bool) {
+func (bc *BlockChain) rewindHashHead(head *types.Header, root common.Hash, rewindLimit uint64) (*types.Header, uint64, bool, error) {
 	var (
 		limit      uint64                             // The oldest block that will be searched for this rewinding
 		rootFound  = root == common.Hash{}            // Flag whether we're beyond the requested root (no root, always true)
@@ -701,6 +839,7 @@ func (bc *BlockChain) rewindHashHead(head *types.Header, root common.Hash, rewin
 	lastFullBlock := uint64(0)
 	lastFullBlockHash := common.Hash{}
 	gasRolledBack := uint64(0)
+	historyTail, _ := bc.db.Tail()
 	for {
 		logger := log.Trace
 		if time.Since(logged) > time.Second*8 {
@@ -711,30 +850,32 @@ func (bc *BlockChain) rewindHashHead(head *types.Header, root common.Hash, rewin
 
 		if rewindLimit > 0 && lastFullBlock != 0 {
 			// Arbitrum: track the amount of gas rolled back and stop the rollback early if necessary
-			gasUsedInBlock := head.GasUsed
-			if bc.chainConfig.IsArbitrum() {
-				receipts := bc.GetReceiptsByHash(head.Hash())
-				for _, receipt := range receipts {
-					gasUsedInBlock -= receipt.GasUsedForL1
-				}
-			}
-			gasRolledBack += gasUsedInBlock
+			gasRolledBack += bc.rewindGasUsed(head)
 			if gasRolledBack >= rewindLimit {
 				rootNumber = lastFullBlock
 				head = bc.GetHeader(lastFullBlockHash, lastFullBlock)
 				log.Debug("Rewound to block with state but not snapshot", "number", head.Number.Uint64(), "hash", head.Hash())
-				return head, rootNumber, rootFound
+				return head, rootNumber, rootFound, nil
 			}
 		}
 		// If a root threshold was requested but not yet crossed, check
 		if !rootFound && head.Root == root {
 			rootFound, rootNumber = true, head.Number.Uint64()
 		}
+		// Arbitrum: if the pruned history is reached, there's no state to
+		// find below it, stop at the last block with state or fail if none.
+		if head.Number.Uint64() < historyTail {
+			if lastFullBlock != 0 {
+				log.Error("Pruned history reached, rewinding to last block with state", "number", head.Number, "tail", historyTail, "state", lastFullBlock)
+				return bc.GetHeader(lastFullBlockHash, lastFullBlock), rootNumber, rootFound, nil
+			}
+			return nil, 0, false, fmt.Errorf("%w: no state above tail %d", errPrunedHistoryRewind, historyTail)
+		}
 		// If search limit is reached, return the genesis block as the
 		// new chain head.
 		if head.Number.Uint64() < limit {
 			log.Info("Rewinding limit reached, resetting to genesis", "number", head.Number, "hash", head.Hash(), "limit", limit)
-			return bc.genesisBlock.Header(), rootNumber, rootFound
+			return bc.genesisBlock.Header(), rootNumber, rootFound, nil
 		}
 		// If the associated state is not reachable, continue searching
 		// backwards until an available state is found.
@@ -744,14 +885,14 @@ func (bc *BlockChain) rewindHashHead(head *types.Header, root common.Hash, rewin
 			parent := bc.GetHeader(head.ParentHash, head.Number.Uint64()-1)
 			if parent == nil {
 				log.Error("Missing block in the middle, resetting to genesis", "number", head.Number.Uint64()-1, "hash", head.ParentHash)
-				return bc.genesisBlock.Header(), rootNumber, rootFound
+				return bc.genesisBlock.Header(), rootNumber, rootFound, nil
 			}
 			head = parent
 
 			// If the genesis block is reached, stop searching.
 			if head.Number.Uint64() == 0 {
 				log.Info("Genesis block reached", "number", head.Number, "hash", head.Hash())
-				return head, rootNumber, rootFound
+				return head, rootNumber, rootFound, nil
 			}
 			continue // keep rewinding
 		}
@@ -759,7 +900,7 @@ func (bc *BlockChain) rewindHashHead(head *types.Header, root common.Hash, rewin
 		// has already been crossed. If not, continue rewinding.
 		if rootFound || head.Number.Uint64() == 0 {
 			log.Info("Rewound to block with state", "number", head.Number, "hash", head.Hash())
-			return head, rootNumber, rootFound
+			return head, rootNumber, rootFound, nil
 		}
 		if (bc.HasState(head.Root) || bc.stateRecoverable(head.Root)) && lastFullBlock == 0 {
 			lastFullBlock = head.Number.Uint64()
@@ -853,10 +994,10 @@ func (bc *BlockChain) rewindPathHead(head *types.Header, root common.Hash) (*typ
 // representing the state corresponding to snapshot disk layer, is deemed impassable,
 // then block number zero is returned, indicating that snapshot recovery is disabled
 // and the whole snapshot should be auto-generated in case of head mismatch.
-func (bc *BlockChain) rewindHead(head *types.Header, root common.Hash, rewindLimit uint64) (*types.Header, uint64, bool) {
+func (bc *BlockChain) rewindHead(head *types.Header, root common.Hash, rewindLimit uint64) (*types.Header, uint64, bool, error) {
 	if bc.triedb.Scheme() == rawdb.PathScheme {
 		newHead, rootNumber := bc.rewindPathHead(head, root)
-		return newHead, rootNumber, head.Number.Uint64() != 0
+		return newHead, rootNumber, head.Number.Uint64() != 0, nil
 	}
 	return bc.rewindHashHead(head, root, rewindLimit)
 }
@@ -885,6 +1026,7 @@ func (bc *BlockChain) setHeadBeyondRoot(head uint64, time uint64, root common.Ha
 		// Track the block number of the requested root hash
 		blockNumber uint64 // (no root == always 0)
 		rootFound   bool
+		rewindErr   error // Arbitrum: rewind failure, leaving the head block in place
 		// Retrieve the last pivot block to short circuit rollbacks beyond it
 		// and the current freezer limit to start nuking it's underflown.
 		pivot = rawdb.ReadLastPivotNumber(bc.db)
@@ -894,8 +1036,12 @@ func (bc *BlockChain) setHeadBeyondRoot(head uint64, time uint64, root common.Ha
 		// block. Note, depth equality is permitted to allow using SetHead as a
 		// chain reparation mechanism without deleting any data!
 		if currentBlock := bc.CurrentBlock(); currentBlock != nil && header.Number.Uint64() <= currentBlock.Number.Uint64() {
-			var newHeadBlock *types.Header
-			newHeadBlock, blockNumber, rootFound = bc.rewindHead(header, root, rewindLimit)
+			newHeadBlock, number, found, err := bc.rewindHead(header, root, rewindLimit)
+			if err != nil {
+				rewindErr = err
+				return bc.CurrentBlock(), false
+			}
+			blockNumber, rootFound = number, found
 			rawdb.WriteHeadBlockHash(db, newHeadBlock.Hash())
 
 			// Degrade the chain markers if they are explicitly reverted.
@@ -971,10 +1117,17 @@ func (bc *BlockChain) setHeadBeyondRoot(head uint64, time uint64, root common.Ha
 	// If SetHead was only called as a chain reparation method, try to skip
 	// touching the header chain altogether, unless the freezer is broken
 	if repair {
-		if target, force := updateFn(bc.db, bc.CurrentBlock()); force {
+		if target, force := updateFn(bc.db, bc.CurrentBlock()); rewindErr != nil {
+			return 0, false, rewindErr
+		} else if force {
 			bc.hc.SetHead(target.Number.Uint64(), nil, delFn)
 		}
 	} else {
+		// Arbitrum: the header chain can't be left half rewound, so check that
+		// the rewind from the target finds a state before touching anything
+		if err := bc.checkRewindAbovePrunedHistory(head, time, root, rewindLimit); err != nil {
+			return 0, false, err
+		}
 		// Rewind the chain to the requested head and keep going backwards until a
 		// block with a state is found or snap sync pivot is passed
 		if time > 0 {
@@ -990,6 +1143,7 @@ func (bc *BlockChain) setHeadBeyondRoot(head uint64, time uint64, root common.Ha
 	bc.bodyRLPCache.Purge()
 	bc.receiptsCache.Purge()
 	bc.blockCache.Purge()
+	bc.l2GasCache.Purge()
 	bc.txLookupCache.Purge()
 
 	// Clear safe block, finalized block if needed
@@ -1001,7 +1155,9 @@ func (bc *BlockChain) setHeadBeyondRoot(head uint64, time uint64, root common.Ha
 		log.Error("SetHead invalidated finalized block")
 		bc.SetFinalized(nil)
 	}
-
+	if rewindErr != nil {
+		return 0, false, rewindErr
+	}
 	return blockNumber, rootFound, bc.loadLastState()
 }
 
@@ -1201,8 +1357,12 @@ func (bc *BlockChain) Stop() {
 		//  - HEAD-127: So we have a hard limit on the number of blocks reexecuted
 		// It applies for both full node and sparse archive node
 		if !bc.cacheConfig.TrieDirtyDisabled || bc.cacheConfig.MaxNumberOfBlocksToSkipStateSaving > 0 || bc.cacheConfig.MaxAmountOfGasToSkipStateSaving > 0 {
-			triedb := bc.triedb
-
+			var (
+				triedb     = bc.triedb
+				commitErrs []error
+				failed     = make(map[common.Hash]struct{}) // Roots which couldn't be persisted
+				committed  *types.Block                     // Most recent block with a persisted state
+			)
 			for _, offset := range []uint64{0, 1, bc.cacheConfig.TriesInMemory - 1, math.MaxUint64} {
 				if number := bc.CurrentBlock().Number.Uint64(); number > offset || offset == math.MaxUint64 {
 					var recent *types.Block
@@ -1218,7 +1378,13 @@ func (bc *BlockChain) Stop() {
 
 					log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
 					if err := triedb.Commit(recent.Root(), true); err != nil {
-						log.Error("Failed to commit recent state trie", "err", err)
+						log.Error("Failed to commit recent state trie", "block", recent.Number(), "err", err)
+						commitErrs = append(commitErrs, fmt.Errorf("block #%d: %w", recent.NumberU64(), err))
+						failed[recent.Root()] = struct{}{}
+						continue
+					}
+					if committed == nil || recent.NumberU64() > committed.NumberU64() {
+						committed = recent
 					}
 				}
 			}
@@ -1226,12 +1392,28 @@ func (bc *BlockChain) Stop() {
 				log.Info("Writing snapshot state to disk", "root", snapBase)
 				if err := triedb.Commit(snapBase, true); err != nil {
 					log.Error("Failed to commit recent state trie", "err", err)
+					commitErrs = append(commitErrs, fmt.Errorf("snapshot base %x: %w", snapBase, err))
+					failed[snapBase] = struct{}{}
 				}
 			}
+			// Record the most recent persisted state, so that the next startup can
+			// repair the head directly to it instead of searching for it.
+			if committed != nil {
+				rawdb.WriteLastStopCommitHash(bc.db, committed.Hash())
+			}
+			// Keep the roots which failed to commit referenced, the dirty nodes
+			// are then still available for any last attempt to persist them.
 			for !bc.triegc.Empty() {
-				triedb.Dereference(bc.triegc.PopItem().Root)
+				entry, _ := bc.popTrieGC()
+				root := entry.Root
+				if _, ok := failed[root]; ok {
+					continue
+				}
+				triedb.Dereference(root)
 			}
-			if _, nodes, _ := triedb.Size(); nodes != 0 { // all memory is contained within the nodes return for hashdb
+			if len(commitErrs) > 0 {
+				log.Error("Failed to persist recent states on shutdown", "failed", len(failed), "err", errors.Join(commitErrs...))
+			} else if _, nodes, _ := triedb.Size(); nodes != 0 { // all memory is contained within the nodes return for hashdb
 				log.Error("Dangling trie nodes after full cleanup")
 			}
 		}
@@ -1276,6 +1458,9 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 	bc.wg.Add(1)
 	defer bc.wg.Done()
 
+	bc.startBlockProcessing()
+	defer bc.stopBlockProcessing()
+
 	var (
 		ancientBlocks, liveBlocks     types.Blocks
 		ancientReceipts, liveReceipts []types.Receipts
@@ -1395,13 +1580,15 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 			batch       = bc.db.NewBatch()
 			canonHashes = make(map[common.Hash]struct{}, len(blockChain))
 		)
-		for _, block := range blockChain {
+		for i, block := range blockChain {
 			canonHashes[block.Hash()] = struct{}{}
 			if block.NumberU64() == 0 {
 				continue
 			}
 			rawdb.DeleteCanonicalHash(batch, block.NumberU64())
 			rawdb.DeleteBlockWithoutNumber(batch, block.Hash(), block.NumberU64())
+			// Arbitrum: the L2 gas used stays in the key-value store
+			rawdb.WriteL2GasUsed(batch, block.Hash(), block.NumberU64(), receiptChainL2GasUsed(block, receiptChain[i]))
 		}
 		// Delete side chain hash-to-number mappings.
 		for _, nh := range rawdb.ReadAllHashesInRange(bc.db, first.NumberU64(), last.NumberU64()) {
@@ -1446,6 +1633,8 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 			// Write all the data out into the database
 			rawdb.WriteBody(batch, block.Hash(), block.NumberU64(), block.Body())
 			rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receiptChain[i])
+			// Arbitrum: store the L2 gas used along the receipts
+			rawdb.WriteL2GasUsed(batch, block.Hash(), block.NumberU64(), receiptChainL2GasUsed(block, receiptChain[i]))
 
 			// Write everything belongs to the blocks into the database. So that
 			// we can ensure all components of body is completed(body, receipts)
@@ -1522,16 +1711,42 @@ func (bc *BlockChain) writeBlockWithoutState(block *types.Block, td *big.Int) (e
 }
 
 // writeKnownBlock updates the head block flag with a known block
-// and introduces chain reorg if necessary.
+// and introduces chain reorg if necessary, sending out the head events
+// like any other block becoming canonical.
 func (bc *BlockChain) writeKnownBlock(block *types.Block) error {
-	current := bc.CurrentBlock()
-	if block.ParentHash() != current.Hash() {
-		if err := bc.reorg(current, block); err != nil {
-			return err
+	// Arbitrum: a canonical block not above the head is already part of the
+	// chain, setting it as head would only rewind the chain through a reorg
+	if current := bc.CurrentBlock(); block.NumberU64() <= current.Number.Uint64() && rawdb.ReadCanonicalHash(bc.db, block.NumberU64()) == block.Hash() {
+		log.Debug("Known block already canonical", "number", block.Number(), "hash", block.Hash(), "head", current.Number)
+		return nil
+	}
+	_, _, err := bc.setHeadAndNotify(block, bc.collectLogs(block, false), false)
+	return err
+}
+
+// setHeadAndNotify sets the given block as the new head, reorganising the chain
+// first if the block doesn't extend the current head, and sends out the events
+// of the new head. The events of the reorg itself (removed blocks and logs, and
+// reborn logs of the intermediate blocks) are sent by reorg, so subscribers see
+// the same sequence regardless of the entry point driving the head change. It
+// returns the numbers of blocks added to and dropped from the canonical chain.
+func (bc *BlockChain) setHeadAndNotify(block *types.Block, logs []*types.Log, emitHeadEvent bool) (added int, dropped int, err error) {
+	added = 1
+	if current := bc.CurrentBlock(); block.ParentHash() != current.Hash() {
+		if added, dropped, err = bc.reorg(current, block); err != nil {
+			return 0, 0, err
 		}
 	}
 	bc.writeHeadBlock(block)
-	return nil
+
+	bc.chainFeed.Send(ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
+	if len(logs) > 0 {
+		bc.logsFeed.Send(logs)
+	}
+	if emitHeadEvent {
+		bc.chainHeadFeed.Send(ChainHeadEvent{Block: block})
+	}
+	return added, dropped, nil
 }
 
 // writeBlockWithState writes block, metadata and corresponding state data to the
@@ -1553,6 +1768,7 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	rawdb.WriteTd(blockBatch, block.Hash(), block.NumberU64(), externTd)
 	rawdb.WriteBlock(blockBatch, block)
 	rawdb.WriteReceipts(blockBatch, block.Hash(), block.NumberU64(), receipts)
+	rawdb.WriteL2GasUsed(blockBatch, block.Hash(), block.NumberU64(), l2GasUsed(receipts))
 	rawdb.WritePreimages(blockBatch, statedb.Preimages())
 	if err := blockBatch.Write(); err != nil {
 		log.Crit("Failed to write block into disk", "err", err)
@@ -1590,6 +1806,9 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 				gasLimitReached = true
 			}
 		}
+		if maySkipCommiting {
+			bc.recordStateSavingDecision(block, blockLimitReached, gasLimitReached)
+		}
 		if !maySkipCommiting || blockLimitReached || gasLimitReached {
 			bc.numberOfBlocksToSkipStateSaving = bc.cacheConfig.MaxNumberOfBlocksToSkipStateSaving
 			bc.amountOfGasInBlocksToSkipStateSaving = bc.cacheConfig.MaxAmountOfGasToSkipStateSaving
@@ -1600,7 +1819,7 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 
 	// Full node or sparse archive node that's not keeping all states, do proper garbage collection
 	bc.triedb.Reference(root, common.Hash{}) // metadata reference to keep trie alive
-	bc.triegc.Push(trieGcEntry{root, block.Header().Time}, -int64(block.NumberU64()))
+	bc.pushTrieGC(trieGcEntry{root, block.Header().Time}, -int64(block.NumberU64()))
 
 	blockLimit := int64(block.NumberU64()) - int64(bc.cacheConfig.TriesInMemory)   // only cleared if below that
 	timeLimit := time.Now().Unix() - int64(bc.cacheConfig.TrieRetention.Seconds()) // only cleared if less than that
@@ -1618,9 +1837,9 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 		var prevNum uint64
 		// Garbage collect anything below our required write retention
 		for !bc.triegc.Empty() {
-			triegcEntry, number := bc.triegc.Pop()
+			triegcEntry, number := bc.popTrieGC()
 			if uint64(-number) > uint64(blockLimit) || triegcEntry.Timestamp > uint64(timeLimit) {
-				bc.triegc.Push(triegcEntry, number)
+				bc.pushTrieGC(triegcEntry, number)
 				break
 			}
 			if prevEntry != nil {
@@ -1629,10 +1848,14 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 			prevEntry = &triegcEntry
 			prevNum = uint64(-number)
 		}
-		flushInterval := time.Duration(bc.flushInterval.Load())
+		var (
+			flushInterval = time.Duration(bc.flushInterval.Load())
+			gcproc        = time.Duration(bc.gcproc.Load())
+			lastWrite     = bc.lastWrite.Load()
+		)
 		// If we exceeded out time allowance, flush an entire trie to disk
 		// In case of archive node that skips some trie commits we don't flush tries here
-		if bc.gcproc > flushInterval && prevEntry != nil && !archiveNode {
+		if gcproc > flushInterval && prevEntry != nil && !archiveNode {
 			// If the header is missing (canonical chain behind), we're reorging a low
 			// diff sidechain. Suspend committing until this operation is completed.
 			header := bc.GetHeaderByNumber(prevNum)
@@ -1641,13 +1864,13 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 			} else {
 				// If we're exceeding limits but haven't reached a large enough memory gap,
 				// warn the user that the system is becoming unstable.
-				if blockLimit < int64(bc.lastWrite+bc.cacheConfig.TriesInMemory) && bc.gcproc >= 2*flushInterval {
-					log.Info("State in memory for too long, committing", "time", bc.gcproc, "allowance", flushInterval, "optimum", float64(prevNum-bc.lastWrite)/float64(bc.cacheConfig.TriesInMemory))
+				if blockLimit < int64(lastWrite+bc.cacheConfig.TriesInMemory) && gcproc >= 2*flushInterval {
+					log.Info("State in memory for too long, committing", "time", gcproc, "allowance", flushInterval, "optimum", float64(prevNum-lastWrite)/float64(bc.cacheConfig.TriesInMemory))
 				}
 				// Flush an entire trie and restart the counters
 				bc.triedb.Commit(header.Root, true)
-				bc.lastWrite = prevNum
-				bc.gcproc = 0
+				bc.lastWrite.Store(prevNum)
+				bc.gcproc.Store(0)
 			}
 		}
 		if prevEntry != nil {
@@ -1659,47 +1882,54 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 
 // writeBlockAndSetHead is the internal implementation of WriteBlockAndSetHead.
 // This function expects the chain mutex to be held.
-func (bc *BlockChain) writeBlockAndSetHead(block *types.Block, receipts []*types.Receipt, logs []*types.Log, state *state.StateDB, emitHeadEvent bool) (status WriteStatus, err error) {
+func (bc *BlockChain) writeBlockAndSetHead(block *types.Block, receipts []*types.Receipt, logs []*types.Log, state *state.StateDB, emitHeadEvent bool, procTime time.Duration) (status WriteStatus, err error) {
 	if err := bc.writeBlockWithState(block, receipts, state); err != nil {
 		return NonStatTy, err
 	}
-	currentBlock := bc.CurrentBlock()
-	reorg, err := bc.forker.ReorgNeeded(currentBlock, block.Header())
+	reorg, err := bc.forker.ReorgNeeded(bc.CurrentBlock(), block.Header())
 	if err != nil {
 		return NonStatTy, err
 	}
-	if reorg {
-		// Reorganise the chain if the parent is not the head block
-		if block.ParentHash() != currentBlock.Hash() {
-			if err := bc.reorg(currentBlock, block); err != nil {
-				return NonStatTy, err
-			}
-		}
-		status = CanonStatTy
-	} else {
-		status = SideStatTy
+	if !reorg {
+		bc.chainSideFeed.Send(ChainSideEvent{Block: block})
+		bc.insertFeed.Send(ChainInsertEvent{Block: block, Status: SideStatTy, ProcTime: procTime})
+		return SideStatTy, nil
 	}
-	// Set new head.
-	if status == CanonStatTy {
-		bc.writeHeadBlock(block)
+	// Set new head, reorganising the chain if the parent is not the head block.
+	//
+	// In theory, we should fire a ChainHeadEvent when we inject
+	// a canonical block, but sometimes we can insert a batch of
+	// canonical blocks. Avoid firing too many ChainHeadEvents,
+	// we will fire an accumulated ChainHeadEvent and disable fire
+	// event here.
+	added, dropped, err := bc.setHeadAndNotify(block, logs, emitHeadEvent)
+	if err != nil {
+		return NonStatTy, err
 	}
-	if status == CanonStatTy {
-		bc.chainFeed.Send(ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
-		if len(logs) > 0 {
-			bc.logsFeed.Send(logs)
-		}
-		// In theory, we should fire a ChainHeadEvent when we inject
-		// a canonical block, but sometimes we can insert a batch of
-		// canonical blocks. Avoid firing too many ChainHeadEvents,
-		// we will fire an accumulated ChainHeadEvent and disable fire
-		// event here.
-		if emitHeadEvent {
-			bc.chainHeadFeed.Send(ChainHeadEvent{Block: block})
-		}
-	} else {
-		bc.chainSideFeed.Send(ChainSideEvent{Block: block})
+	bc.insertFeed.Send(ChainInsertEvent{Block: block, Status: CanonStatTy, Reorg: dropped > 0, Added: added, Dropped: dropped, ProcTime: procTime})
+	return CanonStatTy, nil
+}
+
+// startBlockProcessing marks the start of a block import, notifying the block
+// processing subscribers unless another import is already in progress.
+func (bc *BlockChain) startBlockProcessing() {
+	bc.procLock.Lock()
+	defer bc.procLock.Unlock()
+
+	if bc.procCounter.Add(1) == 1 {
+		bc.blockProcFeed.Send(true)
+	}
+}
+
+// stopBlockProcessing marks the end of a block import, notifying the block
+// processing subscribers once no other import is in progress.
+func (bc *BlockChain) stopBlockProcessing() {
+	bc.procLock.Lock()
+	defer bc.procLock.Unlock()
+
+	if bc.procCounter.Add(-1) == 0 {
+		bc.blockProcFeed.Send(false)
 	}
-	return status, nil
 }
 
 // InsertChain attempts to insert the given batch of blocks in to the canonical
@@ -1711,8 +1941,8 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 	if len(chain) == 0 {
 		return 0, nil
 	}
-	bc.blockProcFeed.Send(true)
-	defer bc.blockProcFeed.Send(false)
+	bc.startBlockProcessing()
+	defer bc.stopBlockProcessing()
 
 	// Do a sanity check that the provided chain is actually ordered and linked.
 	for i := 1; i < len(chain); i++ {
@@ -1751,8 +1981,8 @@ func (bc *BlockChain) insertChain(chain types.Blocks, setHead bool) (int, error)
 		return 0, nil
 	}
 
-	// Start a parallel signature recovery (signer will fluke on fork transition, minimal perf loss)
-	SenderCacher.RecoverFromBlocks(types.MakeSigner(bc.chainConfig, chain[0].Number(), chain[0].Time()), chain)
+	// Start a parallel signature recovery, switching signers at fork transitions
+	SenderCacher.RecoverFromChain(bc.chainConfig, chain)
 
 	var (
 		stats     = insertStats{startTime: mclock.Now()}
@@ -1970,7 +2200,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, setHead bool) (int, error)
 		if !setHead {
 			// After merge we expect few side chains. Simply count
 			// all blocks the CL gives us for GC processing time
-			bc.gcproc += res.procTime
+			bc.gcproc.Add(int64(res.procTime))
 			return it.index, nil // Direct block insertion of a single block
 		}
 		switch res.status {
@@ -1983,7 +2213,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, setHead bool) (int, error)
 			lastCanon = block
 
 			// Only count canonical blocks for GC processing time
-			bc.gcproc += res.procTime
+			bc.gcproc.Add(int64(res.procTime))
 
 		case SideStatTy:
 			log.Debug("Inserted forked block", "number", block.Number(), "hash", block.Hash(),
@@ -2071,7 +2301,7 @@ func (bc *BlockChain) processBlock(block *types.Block, statedb *state.StateDB, s
 		// Don't set the head, only insert the block
 		err = bc.writeBlockWithState(block, receipts, statedb)
 	} else {
-		status, err = bc.writeBlockAndSetHead(block, receipts, logs, statedb, false)
+		status, err = bc.writeBlockAndSetHead(block, receipts, logs, statedb, false, proctime)
 	}
 	if err != nil {
 		return nil, err
@@ -2303,8 +2533,9 @@ func (bc *BlockChain) collectLogs(b *types.Block, removed bool) []*types.Log {
 // blocks and inserts them to be part of the new canonical chain and accumulates
 // potential missing transactions and post an event about them.
 // Note the new head block won't be processed here, callers need to handle it
-// externally.
-func (bc *BlockChain) reorg(oldHead *types.Header, newHead *types.Block) error {
+// externally. The numbers of blocks added to and dropped from the canonical
+// chain are returned, the new head included.
+func (bc *BlockChain) reorg(oldHead *types.Header, newHead *types.Block) (int, int, error) {
 	var (
 		newChain    types.Blocks
 		oldChain    types.Blocks
@@ -2315,7 +2546,7 @@ func (bc *BlockChain) reorg(oldHead *types.Header, newHead *types.Block) error {
 	)
 	oldBlock := bc.GetBlock(oldHead.Hash(), oldHead.Number.Uint64())
 	if oldBlock == nil {
-		return errors.New("current head block missing")
+		return 0, 0, errors.New("current head block missing")
 	}
 	newBlock := newHead
 
@@ -2335,10 +2566,10 @@ func (bc *BlockChain) reorg(oldHead *types.Header, newHead *types.Block) error {
 		}
 	}
 	if oldBlock == nil {
-		return errInvalidOldChain
+		return 0, 0, errInvalidOldChain
 	}
 	if newBlock == nil {
-		return errInvalidNewChain
+		return 0, 0, errInvalidNewChain
 	}
 	// Both sides of the reorg are at the same number, reduce both until the common
 	// ancestor is found
@@ -2358,11 +2589,11 @@ func (bc *BlockChain) reorg(oldHead *types.Header, newHead *types.Block) error {
 		// Step back with both chains
 		oldBlock = bc.GetBlock(oldBlock.ParentHash(), oldBlock.NumberU64()-1)
 		if oldBlock == nil {
-			return errInvalidOldChain
+			return 0, 0, errInvalidOldChain
 		}
 		newBlock = bc.GetBlock(newBlock.ParentHash(), newBlock.NumberU64()-1)
 		if newBlock == nil {
-			return errInvalidNewChain
+			return 0, 0, errInvalidNewChain
 		}
 	}
 
@@ -2446,14 +2677,21 @@ func (bc *BlockChain) reorg(oldHead *types.Header, newHead *types.Block) error {
 	// Send out events for logs from the old canon chain, and 'reborn'
 	// logs from the new canon chain. The number of logs can be very
 	// high, so the events are sent in batches of size around 512.
+	// Deriving the logs is expensive, so it's skipped if nobody listens.
 
 	// Deleted logs + blocks:
-	var deletedLogs []*types.Log
+	var (
+		deletedLogs   []*types.Log
+		collectRemove = bc.rmLogsScope.Count() > 0
+	)
 	for i := len(oldChain) - 1; i >= 0; i-- {
 		// Also send event for blocks removed from the canon chain.
 		bc.chainSideFeed.Send(ChainSideEvent{Block: oldChain[i]})
 
 		// Collect deleted logs for notification
+		if !collectRemove {
+			continue
+		}
 		if logs := bc.collectLogs(oldChain[i], true); len(logs) > 0 {
 			deletedLogs = append(deletedLogs, logs...)
 		}
@@ -2465,6 +2703,9 @@ func (bc *BlockChain) reorg(oldHead *types.Header, newHead *types.Block) error {
 	if len(deletedLogs) > 0 {
 		bc.rmLogsFeed.Send(RemovedLogsEvent{deletedLogs})
 	}
+	if bc.logsScope.Count() == 0 {
+		return len(newChain), len(oldChain), nil
+	}
 
 	// New logs:
 	var rebirthLogs []*types.Log
@@ -2480,7 +2721,7 @@ func (bc *BlockChain) reorg(oldHead *types.Header, newHead *types.Block) error {
 	if len(rebirthLogs) > 0 {
 		bc.logsFeed.Send(rebirthLogs)
 	}
-	return nil
+	return len(newChain), len(oldChain), nil
 }
 
 // InsertBlockWithoutSetHead executes the block, runs the necessary verification
@@ -2516,20 +2757,11 @@ func (bc *BlockChain) SetCanonical(head *types.Block) (common.Hash, error) {
 	}
 	// Run the reorg if necessary and set the given block as new head.
 	start := time.Now()
-	if head.ParentHash() != bc.CurrentBlock().Hash() {
-		if err := bc.reorg(bc.CurrentBlock(), head); err != nil {
-			return common.Hash{}, err
-		}
-	}
-	bc.writeHeadBlock(head)
-
-	// Emit events
-	logs := bc.collectLogs(head, false)
-	bc.chainFeed.Send(ChainEvent{Block: head, Hash: head.Hash(), Logs: logs})
-	if len(logs) > 0 {
-		bc.logsFeed.Send(logs)
+	added, dropped, err := bc.setHeadAndNotify(head, bc.collectLogs(head, false), true)
+	if err != nil {
+		return common.Hash{}, err
 	}
-	bc.chainHeadFeed.Send(ChainHeadEvent{Block: head})
+	bc.insertFeed.Send(ChainInsertEvent{Block: head, Status: CanonStatTy, Reorg: dropped > 0, Added: added, Dropped: dropped})
 
 	context := []interface{}{
 		"number", head.Number(),
@@ -2588,6 +2820,7 @@ func (bc *BlockChain) skipBlock(err error, it *insertIterator) bool {
 // reportBlock logs a bad block error.
 func (bc *BlockChain) reportBlock(block *types.Block, receipts types.Receipts, err error) {
 	rawdb.WriteBadBlock(bc.db, block)
+	rawdb.WriteBadBlockDetail(bc.db, block.Hash(), receipts, err)
 	log.Error(summarizeBadBlock(block, receipts, bc.Config(), err))
 }
 
@@ -18,23 +18,61 @@
 package core
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
+	"math/rand"
+	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
+// ValidatorFactory creates the block validator of a chain. The chain is still
+// being initialised when it is called, so it should only be stored.
+type ValidatorFactory func(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine) Validator
+
+// ProcessorFactory creates the block processor of a chain. The chain is still
+// being initialised when it is called, so it should only be stored.
+type ProcessorFactory func(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine) Processor
+
+// TrieFlushStatus reports the progress towards the next flush of the in-memory
+// tries, which happens once enough block processing time has accumulated.
+type TrieFlushStatus struct {
+	FlushInterval       time.Duration // Processing time after which the tries are flushed
+	AccumulatedProcTime time.Duration // Processing time accumulated since the last flush
+	LastFlushBlock      uint64        // Block whose state was last flushed due to the interval
+}
+
+// TrieFlushStatus returns the current trie flush status. It is safe to call
+// concurrently with block imports.
+func (bc *BlockChain) TrieFlushStatus() TrieFlushStatus {
+	return TrieFlushStatus{
+		FlushInterval:       time.Duration(bc.flushInterval.Load()),
+		AccumulatedProcTime: time.Duration(bc.gcproc.Load()),
+		LastFlushBlock:      bc.lastWrite.Load(),
+	}
+}
+
 // WriteBlockAndSetHeadWithTime also counts processTime, which will cause intermittent TrieDirty cache writes
 func (bc *BlockChain) WriteBlockAndSetHeadWithTime(block *types.Block, receipts []*types.Receipt, logs []*types.Log, state *state.StateDB, emitHeadEvent bool, processTime time.Duration) (status WriteStatus, err error) {
 	if !bc.chainmu.TryLock() {
 		return NonStatTy, errChainStopped
 	}
 	defer bc.chainmu.Unlock()
-	bc.gcproc += processTime
-	return bc.writeBlockAndSetHead(block, receipts, logs, state, emitHeadEvent)
+	bc.gcproc.Add(int64(processTime))
+	return bc.writeBlockAndSetHead(block, receipts, logs, state, emitHeadEvent, processTime)
 }
 
 func (bc *BlockChain) ReorgToOldBlock(newHead *types.Block) error {
@@ -69,3 +107,774 @@ func (bc *BlockChain) RecoverState(block *types.Block) error {
 	_, err := bc.recoverAncestors(block)
 	return err
 }
+
+// repairHeadFromStopMarker rewinds the head block to the most recent state that
+// was persisted by the last shutdown, as recorded by the given marker. It reports
+// false if the marker is absent or unusable, in which case the generic rewind
+// should be used instead.
+func (bc *BlockChain) repairHeadFromStopMarker(head *types.Header, hash common.Hash) (bool, error) {
+	if hash == (common.Hash{}) {
+		return false, nil
+	}
+	number := bc.hc.GetBlockNumber(hash)
+	if number == nil || *number > head.Number.Uint64() || bc.GetCanonicalHash(*number) != hash {
+		log.Warn("Ignoring stale shutdown commit marker", "hash", hash)
+		return false, nil
+	}
+	header := bc.GetHeader(hash, *number)
+	if header == nil || !bc.HasState(header.Root) {
+		log.Warn("Ignoring shutdown commit marker without state", "number", *number, "hash", hash)
+		return false, nil
+	}
+	log.Warn("Head state missing, repairing from shutdown commit marker", "number", head.Number, "hash", head.Hash(), "marker", *number, "markerhash", hash)
+	rawdb.WriteHeadBlockHash(bc.db, hash)
+	return true, bc.loadLastState()
+}
+
+// cacheLimitOrDefault returns the configured cache size, or the given default
+// if the configured value is not positive.
+func cacheLimitOrDefault(limit, def int) int {
+	if limit <= 0 {
+		return def
+	}
+	return limit
+}
+
+// Validate checks the consistency of the state retention settings, returning
+// an error for invalid values and logging a warning for combinations which are
+// known to rebuild the snapshot after a crash or to be silently ignored.
+func (c *CacheConfig) Validate() error {
+	hashScheme := c.StateScheme != rawdb.PathScheme
+	if hashScheme && !c.TrieDirtyDisabled && c.TriesInMemory == 0 {
+		return errors.New("invalid cache config: TriesInMemory must be positive when trie write caching is enabled")
+	}
+	if c.TrieRetention < 0 {
+		return fmt.Errorf("invalid cache config: negative TrieRetention %v", c.TrieRetention)
+	}
+	for _, warning := range c.warnings() {
+		log.Warn("Questionable cache config", "reason", warning)
+	}
+	return nil
+}
+
+// warnings returns the reasons the settings, though valid, are questionable.
+func (c *CacheConfig) warnings() []string {
+	var (
+		warnings  []string
+		sparse    = c.MaxNumberOfBlocksToSkipStateSaving > 0 || c.MaxAmountOfGasToSkipStateSaving > 0
+		snapshots = c.SnapshotLimit > 0 && c.StateScheme != rawdb.PathScheme
+	)
+	switch {
+	case sparse && c.StateScheme == rawdb.PathScheme:
+		warnings = append(warnings, "state saving skip limits are ignored by the path scheme")
+	case sparse && !c.TrieDirtyDisabled:
+		warnings = append(warnings, "state saving skip limits are ignored unless trie write caching is disabled")
+	case sparse && snapshots && c.SnapshotRestoreMaxGas > 0:
+		// The disk state the snapshot is restored to may be as far back as
+		// the gas skipped since the last saved state
+		if c.MaxAmountOfGasToSkipStateSaving == 0 {
+			warnings = append(warnings, "gas skipped without saving state is unbounded, snapshot will be rebuilt after a crash when over SnapshotRestoreMaxGas")
+		} else if c.MaxAmountOfGasToSkipStateSaving > c.SnapshotRestoreMaxGas {
+			warnings = append(warnings, "MaxAmountOfGasToSkipStateSaving is over SnapshotRestoreMaxGas, snapshot may be rebuilt after a crash")
+		}
+	}
+	return warnings
+}
+
+// ArbitrumGenesisError is returned when the database doesn't hold the Nitro
+// genesis block of the configured Arbitrum chain, either because it is missing
+// or because the database was initialized for another chain.
+type ArbitrumGenesisError struct {
+	GenesisBlockNum uint64
+	ChainID         *big.Int // Chain ID of the configuration
+	StoredChainID   *big.Int // Chain ID stored with the genesis found in the database, nil if missing
+
+	// Head markers found in the database
+	HeadBlock     string
+	HeadHeader    string
+	HeadSnapBlock string
+}
+
+func (e *ArbitrumGenesisError) Error() string {
+	heads := fmt.Sprintf("head block %s, head header %s, head snap block %s", e.HeadBlock, e.HeadHeader, e.HeadSnapBlock)
+	if e.StoredChainID != nil {
+		return fmt.Sprintf("database was initialized for chain %v but chain %v is configured, check the data directory (genesis block %d, %s)", e.StoredChainID, e.ChainID, e.GenesisBlockNum, heads)
+	}
+	return fmt.Sprintf("genesis block %d of chain %v not found in database, it may be uninitialized or partially initialized (%s)", e.GenesisBlockNum, e.ChainID, heads)
+}
+
+func (e *ArbitrumGenesisError) Unwrap() error {
+	return ErrNoGenesis
+}
+
+// headerArbOSVersion returns the ArbOS version the given header was built with.
+// Malformed header extra information of an Arbitrum chain is logged loudly, as
+// it is read as ArbOS version 0 which turns the ArbOS gated forks off.
+func headerArbOSVersion(config *params.ChainConfig, header *types.Header) uint64 {
+	if config.IsArbitrum() {
+		if _, err := types.DeserializeHeaderExtraInformationChecked(header); err != nil {
+			log.Error("Malformed header extra information, assuming ArbOS version 0", "number", header.Number, "hash", header.Hash(), "err", err)
+		}
+	}
+	return types.DeserializeHeaderExtraInformation(header).ArbOSFormatVersion
+}
+
+// readArbitrumGenesisHash returns the hash of the Nitro genesis block of the
+// given chain, making sure the database was initialized for that very chain.
+func readArbitrumGenesisHash(db ethdb.Database, config *params.ChainConfig) (common.Hash, error) {
+	number := config.ArbitrumChainParams.GenesisBlockNum
+	hash := rawdb.ReadCanonicalHash(db, number)
+	if hash != (common.Hash{}) {
+		stored := rawdb.ReadChainConfig(db, hash)
+		if stored == nil || stored.ChainID == nil || config.ChainID == nil || stored.ChainID.Cmp(config.ChainID) == 0 {
+			return hash, nil
+		}
+	}
+	err := &ArbitrumGenesisError{
+		GenesisBlockNum: number,
+		ChainID:         config.ChainID,
+		HeadBlock:       describeHeadMarker(db, rawdb.ReadHeadBlockHash(db)),
+		HeadHeader:      describeHeadMarker(db, rawdb.ReadHeadHeaderHash(db)),
+		HeadSnapBlock:   describeHeadMarker(db, rawdb.ReadHeadFastBlockHash(db)),
+	}
+	if hash != (common.Hash{}) {
+		err.StoredChainID = rawdb.ReadChainConfig(db, hash).ChainID
+	}
+	return common.Hash{}, err
+}
+
+// checkMultiGasCompatible checks the multi-dimensional gas activation time of
+// the given config against the one stored in the database, returning a
+// compatibility error if the chain already went past either of them. The
+// stored config is updated otherwise, so that the activation time can't be
+// moved silently once the chain reaches it.
+func checkMultiGasCompatible(db ethdb.Database, genesisHash common.Hash, config *params.ChainConfig) error {
+	stored := rawdb.ReadChainConfig(db, genesisHash)
+	head := rawdb.ReadHeadHeader(db)
+	if stored == nil || head == nil {
+		return nil
+	}
+	newcfg := *stored
+	newcfg.ArbitrumChainParams.MultiGasActivationTime = config.ArbitrumChainParams.MultiGasActivationTime
+
+	compatErr := stored.CheckCompatible(&newcfg, head.Number.Uint64(), head.Time)
+	if compatErr != nil && head.Time != 0 && compatErr.RewindToTime != 0 {
+		return compatErr
+	}
+	storedTime, newTime := stored.ArbitrumChainParams.MultiGasActivationTime, newcfg.ArbitrumChainParams.MultiGasActivationTime
+	if (storedTime == nil) != (newTime == nil) || (storedTime != nil && *storedTime != *newTime) {
+		rawdb.WriteChainConfig(db, genesisHash, &newcfg)
+	}
+	return nil
+}
+
+// describeHeadMarker returns a printable description of a head marker.
+func describeHeadMarker(db ethdb.Reader, hash common.Hash) string {
+	if hash == (common.Hash{}) {
+		return "none"
+	}
+	if number := rawdb.ReadHeaderNumber(db, hash); number != nil {
+		return fmt.Sprintf("#%d [%s]", *number, hash.TerminalString())
+	}
+	return fmt.Sprintf("#? [%s]", hash.TerminalString())
+}
+
+// l2GasCacheLimit is the number of blocks whose L2 gas used is kept in memory,
+// enough to serve the widest fee history request.
+const l2GasCacheLimit = 1024
+
+// l2GasUsed sums the gas used by the receipts, excluding the part paying for
+// L1 data.
+func l2GasUsed(receipts types.Receipts) uint64 {
+	var gasUsed uint64
+	for _, receipt := range receipts {
+		if receipt.GasUsed > receipt.GasUsedForL1 {
+			gasUsed += receipt.GasUsed - receipt.GasUsedForL1
+		}
+	}
+	return gasUsed
+}
+
+// L2GasUsed returns the gas used by the transactions of the given block,
+// excluding the part paying for L1 data. The value is stored along the block
+// when it's written, for blocks written earlier it's derived from the receipts.
+func (bc *BlockChain) L2GasUsed(hash common.Hash, number uint64) uint64 {
+	if gasUsed, ok := bc.l2GasCache.Get(hash); ok {
+		return gasUsed
+	}
+	gasUsed, ok := rawdb.ReadL2GasUsed(bc.db, hash, number)
+	if !ok {
+		header := bc.GetHeader(hash, number)
+		if header == nil {
+			return 0
+		}
+		l1GasUsed, ok := bc.ReceiptsGasUsedForL1(hash, number)
+		if !ok {
+			return 0
+		}
+		gasUsed = deriveL2GasUsed(header.GasUsed, l1GasUsed)
+	}
+	bc.l2GasCache.Add(hash, gasUsed)
+	return gasUsed
+}
+
+// deriveL2GasUsed subtracts the gas paying for L1 data of every transaction
+// from the gas used by a block.
+func deriveL2GasUsed(gasUsed uint64, l1GasUsed []uint64) uint64 {
+	for _, l1 := range l1GasUsed {
+		if l1 > gasUsed {
+			l1 = gasUsed
+		}
+		gasUsed -= l1
+	}
+	return gasUsed
+}
+
+// receiptChainL2GasUsed returns the L2 gas used of a block inserted along its
+// receipts. Those may come without the gas used of every transaction, so it's
+// derived from the header instead.
+func receiptChainL2GasUsed(block *types.Block, receipts types.Receipts) uint64 {
+	l1GasUsed := make([]uint64, len(receipts))
+	for i, receipt := range receipts {
+		l1GasUsed[i] = receipt.GasUsedForL1
+	}
+	return deriveL2GasUsed(block.GasUsed(), l1GasUsed)
+}
+
+// HasReceipts checks if the receipts of the given block are present in the
+// cache or the database, without retrieving them.
+func (bc *BlockChain) HasReceipts(hash common.Hash, number uint64) bool {
+	if bc.receiptsCache.Contains(hash) {
+		return true
+	}
+	return rawdb.HasReceipts(bc.db, hash, number)
+}
+
+// ReceiptsGasUsedForL1 returns the gas paying for L1 data of every receipt of
+// the given block, without deriving the other receipt fields. It reports false
+// if the receipts are missing.
+func (bc *BlockChain) ReceiptsGasUsedForL1(hash common.Hash, number uint64) ([]uint64, bool) {
+	if receipts, ok := bc.receiptsCache.Get(hash); ok {
+		gasUsed := make([]uint64, len(receipts))
+		for i, receipt := range receipts {
+			gasUsed[i] = receipt.GasUsedForL1
+		}
+		return gasUsed, true
+	}
+	return rawdb.ReadReceiptsGasUsedForL1(bc.db, hash, number)
+}
+
+// ReceiptsPrunedError is returned for blocks whose receipts were dropped by
+// PruneReceiptHistory, while their headers and bodies are still available.
+type ReceiptsPrunedError struct {
+	Number uint64 // Number of the requested block
+	Tail   uint64 // Number of the first block with receipts
+}
+
+func (e *ReceiptsPrunedError) Error() string {
+	return fmt.Sprintf("receipts of block %d were pruned, receipt history starts at block %d", e.Number, e.Tail)
+}
+
+// PruneReceiptHistory drops the receipts of the ancient blocks, except for the
+// keepLast blocks up to the head, while keeping their headers and bodies. The
+// receipts of blocks not yet moved to the ancient store are never pruned.
+func (bc *BlockChain) PruneReceiptHistory(keepLast uint64) error {
+	head := bc.CurrentSnapBlock().Number.Uint64()
+	if head < keepLast {
+		return nil
+	}
+	frozen, err := bc.db.Ancients()
+	if err != nil {
+		return err
+	}
+	tail := min(head+1-keepLast, frozen)
+	old, err := rawdb.TruncateAncientReceiptsTail(bc.db, tail)
+	if err != nil {
+		return fmt.Errorf("failed to prune receipt history: %w", err)
+	}
+	if old < tail {
+		bc.receiptsCache.Purge()
+		log.Info("Pruned receipt history", "from", old, "to", tail)
+	}
+	return nil
+}
+
+// CheckReceiptsPruned returns a ReceiptsPrunedError if the receipts of the
+// given block were dropped by PruneReceiptHistory.
+func (bc *BlockChain) CheckReceiptsPruned(number uint64) error {
+	tail, err := rawdb.ReadAncientReceiptsTail(bc.db)
+	if err != nil || number >= tail {
+		return nil
+	}
+	// Below the tail of the ancient store the whole block is gone
+	if ancientTail, err := bc.db.Tail(); err == nil && number < ancientTail {
+		return nil
+	}
+	return &ReceiptsPrunedError{Number: number, Tail: tail}
+}
+
+// ancientReceiptsCheckSamples is the number of randomly picked ancient blocks
+// whose receipts are checked on top of the first and last ones, when starting
+// from an external ancient store.
+const ancientReceiptsCheckSamples = 8
+
+// AncientReceiptsError is returned when starting from an external ancient store
+// whose receipts don't decode or don't match their headers.
+type AncientReceiptsError struct {
+	Number uint64 // Number of the block with invalid receipts
+	Err    error
+}
+
+func (e *AncientReceiptsError) Error() string {
+	return fmt.Sprintf("invalid receipts of ancient block %d: %v (the ancient store may be corrupted or belong to another chain, the check can be skipped to start anyway)", e.Number, e.Err)
+}
+
+func (e *AncientReceiptsError) Unwrap() error { return e.Err }
+
+// checkAncientReceipts samples the receipts of the ancient store, checking they
+// decode and match the receipt root of their header, so that a corrupted or
+// foreign ancient store is rejected upfront rather than failing on RPC calls.
+func (bc *BlockChain) checkAncientReceipts() error {
+	frozen, err := bc.db.Ancients()
+	if err != nil || frozen == 0 {
+		return nil
+	}
+	tail, err := rawdb.ReadAncientReceiptsTail(bc.db)
+	if err != nil || tail >= frozen {
+		return nil
+	}
+	numbers := []uint64{tail, frozen - 1}
+	for i := 0; i < ancientReceiptsCheckSamples; i++ {
+		numbers = append(numbers, tail+uint64(rand.Int63n(int64(frozen-tail))))
+	}
+	for _, number := range numbers {
+		if err := bc.checkAncientBlockReceipts(number); err != nil {
+			return &AncientReceiptsError{Number: number, Err: err}
+		}
+	}
+	return nil
+}
+
+func (bc *BlockChain) checkAncientBlockReceipts(number uint64) error {
+	hash := rawdb.ReadCanonicalHash(bc.db, number)
+	header := rawdb.ReadHeader(bc.db, hash, number)
+	if header == nil {
+		return errors.New("header not found")
+	}
+	body := rawdb.ReadBody(bc.db, hash, number)
+	if body == nil {
+		return errors.New("body not found")
+	}
+	var stored []*types.ReceiptForStorage
+	if err := rlp.DecodeBytes(rawdb.ReadReceiptsRLP(bc.db, hash, number), &stored); err != nil {
+		return fmt.Errorf("failed to decode receipts: %w", err)
+	}
+	if len(stored) != len(body.Transactions) {
+		return fmt.Errorf("receipt count mismatch: have %d, want %d", len(stored), len(body.Transactions))
+	}
+	// The receipt type isn't stored, but is part of the consensus encoding
+	receipts := make(types.Receipts, len(stored))
+	for i, receipt := range stored {
+		receipts[i] = (*types.Receipt)(receipt)
+		receipts[i].Type = body.Transactions[i].Type()
+	}
+	if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != header.ReceiptHash {
+		return fmt.Errorf("receipt root mismatch: have %x, want %x", root, header.ReceiptHash)
+	}
+	return nil
+}
+
+// maxReceiptRepairGas is the maximum gas used by the blocks of a receipt repair.
+var maxReceiptRepairGas uint64 = 500_000_000
+
+// RepairReceiptRange re-executes the finalized blocks first to last (inclusive)
+// and overwrites their stored receipts with the regenerated ones. It restores
+// the Arbitrum specific receipt fields, which aren't covered by the receipt root
+// and are missing from receipts synced from peers running older versions.
+// Receipts of blocks in the ancient store, which is where snap synced receipts
+// end up, are overwritten in the key-value store, taking precedence over the
+// ancient ones. Pruned receipts can't be repaired.
+//
+// The state of the parent of the first block is recreated from the closest
+// ancestor with available state if needed, bounded by maxDepthInL2Gas with the
+// same semantics as the MaxRecreateStateDepth API config (-1 = infinite, other
+// non-positive values = don't recreate). All the blocks are run on one
+// in-memory state which isn't committed in between, so the gas used by the
+// range is capped at maxReceiptRepairGas. Processing is throttled to leave room
+// for block imports, and is aborted if the chain is stopped.
+func (bc *BlockChain) RepairReceiptRange(first, last uint64, maxDepthInL2Gas int64) error {
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	if first > last {
+		return fmt.Errorf("invalid receipt repair range %d-%d", first, last)
+	}
+	if genesis := bc.genesisBlock.NumberU64(); first <= genesis {
+		return fmt.Errorf("can't repair receipts of block %d at or before genesis %d", first, genesis)
+	}
+	finalized := bc.CurrentFinalBlock()
+	if finalized == nil || last > finalized.Number.Uint64() {
+		return fmt.Errorf("can't repair receipts of non-finalized block %d", last)
+	}
+	if err := bc.CheckReceiptsPruned(first); err != nil {
+		return err
+	}
+	var rangeGas uint64
+	for number := first; number <= last; number++ {
+		header := bc.GetHeaderByNumber(number)
+		if header == nil {
+			return fmt.Errorf("block %d not found", number)
+		}
+		if rangeGas += header.GasUsed; rangeGas > maxReceiptRepairGas {
+			return fmt.Errorf("receipt repair range %d-%d exceeds %d gas, split it up", first, last, maxReceiptRepairGas)
+		}
+	}
+	parent := bc.GetHeaderByNumber(first - 1)
+	if parent == nil {
+		return fmt.Errorf("missing parent of block %d", first)
+	}
+	statedb, err := bc.receiptRepairState(parent, maxDepthInL2Gas)
+	if err != nil {
+		return err
+	}
+	log.Info("Repairing receipts", "first", first, "last", last)
+	for number := first; number <= last; number++ {
+		if bc.insertStopped() {
+			return errInsertionInterrupted
+		}
+		start := time.Now()
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			return fmt.Errorf("block %d not found", number)
+		}
+		if block.ParentHash() != parent.Hash() {
+			return fmt.Errorf("reorg detected at block %d: expected parent %v, found %v", number, parent.Hash(), block.ParentHash())
+		}
+		receipts, _, _, err := bc.processor.Process(block, statedb, vm.Config{})
+		if err != nil {
+			return fmt.Errorf("failed to re-execute block %d: %w", number, err)
+		}
+		if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != block.ReceiptHash() {
+			return fmt.Errorf("receipt root mismatch for block %d: have %v, want %v", number, root, block.ReceiptHash())
+		}
+		if root := statedb.IntermediateRoot(bc.chainConfig.IsEIP158(block.Number())); root != block.Root() {
+			return fmt.Errorf("state root mismatch for block %d: have %v, want %v", number, root, block.Root())
+		}
+		batch := bc.db.NewBatch()
+		rawdb.WriteReceipts(batch, block.Hash(), number, receipts)
+		rawdb.WriteL2GasUsed(batch, block.Hash(), number, l2GasUsed(receipts))
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to write repaired receipts", "err", err)
+		}
+		bc.receiptsCache.Remove(block.Hash())
+		bc.l2GasCache.Remove(block.Hash())
+		parent = block.Header()
+
+		// Spend at most half of the time re-executing blocks
+		if number < last {
+			select {
+			case <-time.After(time.Since(start)):
+			case <-bc.quit:
+				return errInsertionInterrupted
+			}
+		}
+	}
+	log.Info("Repaired receipts", "first", first, "last", last)
+	return nil
+}
+
+// receiptRepairState returns the state after the given block, re-executing the
+// blocks since the closest ancestor with available state if it's missing, up
+// to maxDepthInL2Gas of L2 gas as described in RepairReceiptRange.
+func (bc *BlockChain) receiptRepairState(header *types.Header, maxDepthInL2Gas int64) (*state.StateDB, error) {
+	var (
+		headers []*types.Header
+		depth   uint64
+	)
+	for !bc.HasState(header.Root) {
+		if bc.insertStopped() {
+			return nil, errInsertionInterrupted
+		}
+		if header.Number.Uint64() <= bc.genesisBlock.NumberU64() {
+			return nil, fmt.Errorf("no state available to repair receipts from block %d", header.Number)
+		}
+		if maxDepthInL2Gas > 0 {
+			depth += bc.L2GasUsed(header.Hash(), header.Number.Uint64())
+			if depth > uint64(maxDepthInL2Gas) {
+				return nil, fmt.Errorf("no state available within %d L2 gas to repair receipts from block %d", maxDepthInL2Gas, header.Number)
+			}
+		} else if maxDepthInL2Gas != -1 {
+			return nil, fmt.Errorf("no state available to repair receipts from block %d", header.Number)
+		}
+		headers = append(headers, header)
+		header = bc.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+		if header == nil {
+			return nil, errors.New("missing ancestor while recreating state")
+		}
+	}
+	statedb, err := bc.StateAt(header.Root)
+	if err != nil {
+		return nil, err
+	}
+	if len(headers) > 0 {
+		log.Info("Recreating state to repair receipts", "from", header.Number, "to", headers[0].Number)
+	}
+	for i := len(headers) - 1; i >= 0; i-- {
+		if bc.insertStopped() {
+			return nil, errInsertionInterrupted
+		}
+		block := bc.GetBlock(headers[i].Hash(), headers[i].Number.Uint64())
+		if block == nil {
+			return nil, fmt.Errorf("block %d not found while recreating state", headers[i].Number)
+		}
+		if _, _, _, err := bc.processor.Process(block, statedb, vm.Config{}); err != nil {
+			return nil, fmt.Errorf("failed recreating state for block %d: %w", block.NumberU64(), err)
+		}
+	}
+	return statedb, nil
+}
+
+// errPrunedHistoryRewind is returned when rewinding the chain reaches the pruned
+// history without crossing any block with state.
+var errPrunedHistoryRewind = errors.New("rewind reached pruned history")
+
+// checkRewindAbovePrunedHistory checks that rewinding the chain to the given
+// block number, or timestamp if not zero, finds a block with state above the
+// pruned history.
+func (bc *BlockChain) checkRewindAbovePrunedHistory(head uint64, time uint64, root common.Hash, rewindLimit uint64) error {
+	if bc.triedb.Scheme() == rawdb.PathScheme {
+		return nil
+	}
+	if tail, _ := bc.db.Tail(); tail == 0 {
+		return nil
+	}
+	target := bc.CurrentBlock()
+	if time == 0 {
+		if head < target.Number.Uint64() {
+			target = bc.GetHeaderByNumber(head)
+		}
+	} else {
+		for target != nil && target.Number.Uint64() > 0 && target.Time > time {
+			target = bc.GetHeader(target.ParentHash, target.Number.Uint64()-1)
+		}
+	}
+	if target == nil {
+		return nil
+	}
+	_, _, _, err := bc.rewindHashHead(target, root, rewindLimit)
+	return err
+}
+
+// rewindGasUsed returns the gas used by the given block as counted towards the
+// rewind limit, excluding the gas paying for L1 data on Arbitrum chains. The
+// full gas used of the header is counted if the receipts are missing or not
+// consistent with it.
+func (bc *BlockChain) rewindGasUsed(header *types.Header) uint64 {
+	if !bc.chainConfig.IsArbitrum() {
+		return header.GasUsed
+	}
+	hash, number := header.Hash(), header.Number.Uint64()
+	if gasUsed, ok := rawdb.ReadL2GasUsed(bc.db, hash, number); ok && gasUsed <= header.GasUsed {
+		return gasUsed
+	}
+	receiptsL1GasUsed, ok := bc.ReceiptsGasUsedForL1(hash, number)
+	if !ok {
+		return header.GasUsed
+	}
+	var l1GasUsed uint64
+	for _, l1 := range receiptsL1GasUsed {
+		if l1 > header.GasUsed-l1GasUsed {
+			log.Warn("Ignoring receipts inconsistent with header gas used", "number", number, "hash", hash, "gasused", header.GasUsed)
+			return header.GasUsed
+		}
+		l1GasUsed += l1
+	}
+	return header.GasUsed - l1GasUsed
+}
+
+// StateSavingBacklog reports the state commits skipped by a sparse archive node
+// since the last one, in blocks and in gas used, along with the number of tries
+// kept in memory awaiting garbage collection and the oldest block among them.
+// Zeros are returned if the chain is stopped.
+func (bc *BlockChain) StateSavingBacklog() (blocksSkipped uint32, gasSkipped uint64, triegcEntries int, oldestTriegcBlock uint64) {
+	if !bc.chainmu.TryLock() {
+		return 0, 0, 0, 0
+	}
+	defer bc.chainmu.Unlock()
+
+	if limit := bc.cacheConfig.MaxNumberOfBlocksToSkipStateSaving; limit != 0 && bc.numberOfBlocksToSkipStateSaving <= limit {
+		blocksSkipped = limit - bc.numberOfBlocksToSkipStateSaving
+	}
+	if limit := bc.cacheConfig.MaxAmountOfGasToSkipStateSaving; limit != 0 && bc.amountOfGasInBlocksToSkipStateSaving <= limit {
+		gasSkipped = limit - bc.amountOfGasInBlocksToSkipStateSaving
+	}
+	triegcEntries = bc.triegc.Size()
+	if triegcEntries > 0 {
+		_, number := bc.triegc.Peek()
+		oldestTriegcBlock = uint64(-number)
+	}
+	return blocksSkipped, gasSkipped, triegcEntries, oldestTriegcBlock
+}
+
+// TrieDatabaseStats is a snapshot of the memory held by the trie database of a
+// chain, along with the cache limits it is configured with.
+type TrieDatabaseStats struct {
+	Scheme        string
+	Dirties       common.StorageSize // Dirty node cache (hash scheme) or node buffer (path scheme)
+	Diffs         common.StorageSize // In-memory diff layers, path scheme only
+	Cleans        common.StorageSize // Clean node cache
+	Preimages     common.StorageSize // Preimages not yet flushed to disk
+	TrieGCEntries int                // Tries kept in memory awaiting garbage collection
+	HistoryItems  uint64             // State histories kept on disk, path scheme only
+	HistorySize   common.StorageSize // Size of the state histories, path scheme only
+
+	CleanLimit    common.StorageSize
+	DirtyLimit    common.StorageSize
+	TriesInMemory uint64
+	StateHistory  uint64
+}
+
+// TrieDatabaseStats returns the memory held by the trie database. It only
+// reads counters maintained by the database and the chain, without iterating
+// over the cached nodes, but waits for the ongoing import if any.
+func (bc *BlockChain) TrieDatabaseStats() (*TrieDatabaseStats, error) {
+	diffs, dirties, preimages := bc.triedb.Size()
+	stats := &TrieDatabaseStats{
+		Scheme:        bc.triedb.Scheme(),
+		Dirties:       dirties,
+		Diffs:         diffs,
+		Cleans:        bc.triedb.CleanSize(),
+		Preimages:     preimages,
+		CleanLimit:    common.StorageSize(bc.cacheConfig.TrieCleanLimit) * 1024 * 1024,
+		DirtyLimit:    common.StorageSize(bc.cacheConfig.TrieDirtyLimit) * 1024 * 1024,
+		TriesInMemory: bc.cacheConfig.TriesInMemory,
+		StateHistory:  bc.cacheConfig.StateHistory,
+	}
+	if stats.Scheme == rawdb.PathScheme {
+		items, size, err := bc.triedb.HistorySize()
+		if err != nil {
+			return nil, err
+		}
+		stats.HistoryItems, stats.HistorySize = items, size
+	} else {
+		_, _, stats.TrieGCEntries, _ = bc.StateSavingBacklog()
+	}
+	return stats, nil
+}
+
+// pushTrieGC queues a state root for garbage collection, tracking it in the
+// root set read by TrieGCContains. The chain mutex must be held.
+func (bc *BlockChain) pushTrieGC(entry trieGcEntry, number int64) {
+	bc.triegc.Push(entry, number)
+
+	bc.triegcLock.Lock()
+	bc.triegcRoots[entry.Root]++
+	bc.triegcLock.Unlock()
+}
+
+// popTrieGC takes the oldest state root out of the garbage collection queue,
+// dropping it from the root set once no other entry holds it. The chain mutex
+// must be held.
+func (bc *BlockChain) popTrieGC() (trieGcEntry, int64) {
+	entry, number := bc.triegc.Pop()
+
+	bc.triegcLock.Lock()
+	if bc.triegcRoots[entry.Root]--; bc.triegcRoots[entry.Root] <= 0 {
+		delete(bc.triegcRoots, entry.Root)
+	}
+	bc.triegcLock.Unlock()
+	return entry, number
+}
+
+// TrieGCContains reports whether the given state root is kept in memory
+// awaiting garbage collection.
+func (bc *BlockChain) TrieGCContains(root common.Hash) bool {
+	bc.triegcLock.Lock()
+	defer bc.triegcLock.Unlock()
+
+	return bc.triegcRoots[root] > 0
+}
+
+// ForceTrieCommit persists the state of the current head block right away,
+// regardless of the commit cadence, and restarts the counters deciding when
+// the next commit happens. It does nothing in path scheme, where the state is
+// persisted by the state database itself.
+func (bc *BlockChain) ForceTrieCommit() error {
+	if !bc.chainmu.TryLock() {
+		return errChainStopped
+	}
+	defer bc.chainmu.Unlock()
+
+	if bc.triedb.Scheme() == rawdb.PathScheme {
+		return nil
+	}
+	head := bc.CurrentBlock()
+	if !bc.HasState(head.Root) {
+		return fmt.Errorf("missing state of head block %d", head.Number)
+	}
+	if err := bc.triedb.Commit(head.Root, true); err != nil {
+		return err
+	}
+	if bc.cacheConfig.TrieDirtyDisabled {
+		bc.numberOfBlocksToSkipStateSaving = bc.cacheConfig.MaxNumberOfBlocksToSkipStateSaving
+		bc.amountOfGasInBlocksToSkipStateSaving = bc.cacheConfig.MaxAmountOfGasToSkipStateSaving
+	} else {
+		bc.lastWrite.Store(head.Number.Uint64())
+		bc.gcproc.Store(0)
+	}
+	return nil
+}
+
+// StateSavingDecision describes whether a sparse archive node skipped the state
+// commit of a block, and the skip budgets left afterwards.
+type StateSavingDecision struct {
+	Number            uint64 // Block the decision was made for
+	Skipped           bool   // Whether the state commit was skipped
+	BlockLimitReached bool   // Whether the skipped blocks limit forced the commit
+	GasLimitReached   bool   // Whether the skipped gas limit forced the commit
+	BlocksLeft        uint32 // Block commits that may still be skipped
+	GasLeft           uint64 // Gas of the blocks whose commit may still be skipped
+}
+
+// recordStateSavingDecision logs whether the state commit of the block gets
+// skipped by a sparse archive node, and keeps the decision for inspection.
+// This function expects the chain mutex to be held.
+func (bc *BlockChain) recordStateSavingDecision(block *types.Block, blockLimitReached, gasLimitReached bool) {
+	decision := StateSavingDecision{
+		Number:            block.NumberU64(),
+		Skipped:           !blockLimitReached && !gasLimitReached,
+		BlockLimitReached: blockLimitReached,
+		GasLimitReached:   gasLimitReached,
+		BlocksLeft:        bc.numberOfBlocksToSkipStateSaving,
+		GasLeft:           bc.amountOfGasInBlocksToSkipStateSaving,
+	}
+	bc.lastStateSavingDecision = decision
+
+	var limits []string
+	if bc.cacheConfig.MaxNumberOfBlocksToSkipStateSaving != 0 {
+		limits = append(limits, "blocks")
+	}
+	if bc.cacheConfig.MaxAmountOfGasToSkipStateSaving != 0 {
+		limits = append(limits, "gas")
+	}
+	if decision.Skipped {
+		stateSavingSkippedCounter.Inc(1)
+		log.Debug("Skipping state commit", "number", decision.Number, "hash", block.Hash(), "limits", strings.Join(limits, ","),
+			"blocksleft", decision.BlocksLeft, "gasleft", decision.GasLeft,
+			"gcproc", time.Duration(bc.gcproc.Load()), "flushinterval", time.Duration(bc.flushInterval.Load()))
+		return
+	}
+	log.Info("State commit skip budget exhausted, committing", "number", decision.Number, "hash", block.Hash(),
+		"blocklimit", blockLimitReached, "gaslimit", gasLimitReached, "blocks", bc.cacheConfig.MaxNumberOfBlocksToSkipStateSaving, "gas", bc.cacheConfig.MaxAmountOfGasToSkipStateSaving)
+}
+
+// LastStateSavingDecision returns the latest decision of a sparse archive node
+// about skipping a state commit. The zero value is returned if no decision was
+// made yet or the chain is stopped.
+func (bc *BlockChain) LastStateSavingDecision() StateSavingDecision {
+	if !bc.chainmu.TryLock() {
+		return StateSavingDecision{}
+	}
+	defer bc.chainmu.Unlock()
+	return bc.lastStateSavingDecision
+}
@@ -0,0 +1,1679 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+var errInjectedWrite = errors.New("injected batch write failure")
+
+// failingBatchDB is a database whose batch writes can be made to fail on demand,
+// simulating e.g. a full disk.
+type failingBatchDB struct {
+	ethdb.Database
+	failures atomic.Int32 // Number of upcoming batch writes to reject
+}
+
+func (db *failingBatchDB) NewBatch() ethdb.Batch {
+	return &failingBatch{Batch: db.Database.NewBatch(), db: db}
+}
+
+func (db *failingBatchDB) NewBatchWithSize(size int) ethdb.Batch {
+	return &failingBatch{Batch: db.Database.NewBatchWithSize(size), db: db}
+}
+
+type failingBatch struct {
+	ethdb.Batch
+	db *failingBatchDB
+}
+
+func (b *failingBatch) Write() error {
+	if b.db.failures.Load() > 0 && b.db.failures.Add(-1) >= 0 {
+		return errInjectedWrite
+	}
+	return b.Batch.Write()
+}
+
+// Tests that a state commit failing during Stop doesn't lose track of the states
+// that were persisted, and that the next startup repairs the head to them.
+func TestStopCommitFailure(t *testing.T) {
+	var (
+		db      = &failingBatchDB{Database: rawdb.NewMemoryDatabase()}
+		engine  = ethash.NewFaker()
+		genesis = &Genesis{
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Config:  params.AllEthashProtocolChanges,
+		}
+		config = DefaultCacheConfigWithScheme(rawdb.HashScheme)
+	)
+	config.SnapshotLimit = 0
+	config.TriesInMemory = 4
+
+	chain, err := NewBlockChain(db, config, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	_, blocks := makeBlockChainWithGenesis(genesis, 8, engine, canonicalSeed)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Reject the head state commit, let the rest through
+	db.failures.Store(1)
+	chain.Stop()
+
+	if db.failures.Load() != 0 {
+		t.Fatalf("expected injected failure to be consumed")
+	}
+	want := blocks[len(blocks)-2]
+	if have := rawdb.ReadLastStopCommitHash(db); have != want.Hash() {
+		t.Fatalf("stop commit marker mismatch: have %x, want %x", have, want.Hash())
+	}
+	chain, err = NewBlockChain(db, config, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if have := chain.CurrentBlock().Hash(); have != want.Hash() {
+		t.Fatalf("head mismatch after repair: have #%d [%x], want #%d [%x]", chain.CurrentBlock().Number, have, want.NumberU64(), want.Hash())
+	}
+	if have := rawdb.ReadLastStopCommitHash(db); have != (common.Hash{}) {
+		t.Fatalf("stop commit marker not consumed: %x", have)
+	}
+}
+
+// Tests that the chain data cache sizes are taken from the cache config, that
+// unset sizes fall back to the defaults and that cache lookups are metered.
+func TestConfigurableChainCaches(t *testing.T) {
+	// The package level meters are no-ops unless metrics were enabled at init
+	// time, swap in live ones for the duration of the test.
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	oldHit, oldMiss := blockCacheHitMeter, blockCacheMissMeter
+	blockCacheHitMeter, blockCacheMissMeter = metrics.NewMeter(), metrics.NewMeter()
+	defer func() {
+		blockCacheHitMeter.Stop()
+		blockCacheMissMeter.Stop()
+		blockCacheHitMeter, blockCacheMissMeter = oldHit, oldMiss
+		metrics.Enabled = enabled
+	}()
+
+	var (
+		engine  = ethash.NewFaker()
+		genesis = &Genesis{
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Config:  params.AllEthashProtocolChanges,
+		}
+	)
+	_, blocks, _ := GenerateChainWithGenesis(genesis, engine, 4, func(i int, b *BlockGen) {})
+
+	cacheConfig := DefaultCacheConfigWithScheme(rawdb.HashScheme)
+	cacheConfig.BodyCacheLimit = 16
+	cacheConfig.BlockCacheLimit = 8
+	cacheConfig.ReceiptsCacheLimit = 0
+	cacheConfig.TxLookupCacheLimit = -1
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), cacheConfig, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if have := chain.bodyCache.Capacity(); have != 16 {
+		t.Errorf("body cache capacity mismatch: have %d, want %d", have, 16)
+	}
+	if have := chain.bodyRLPCache.Capacity(); have != 16 {
+		t.Errorf("body rlp cache capacity mismatch: have %d, want %d", have, 16)
+	}
+	if have := chain.blockCache.Capacity(); have != 8 {
+		t.Errorf("block cache capacity mismatch: have %d, want %d", have, 8)
+	}
+	if have := chain.receiptsCache.Capacity(); have != receiptsCacheLimit {
+		t.Errorf("receipts cache capacity mismatch: have %d, want %d", have, receiptsCacheLimit)
+	}
+	if have := chain.txLookupCache.Capacity(); have != txLookupCacheLimit {
+		t.Errorf("tx lookup cache capacity mismatch: have %d, want %d", have, txLookupCacheLimit)
+	}
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	chain.blockCache.Purge()
+
+	// Chain insertion itself touches the cache, only look at the deltas
+	var (
+		hash       = blocks[1].Hash()
+		baseHits   = blockCacheHitMeter.Snapshot().Count()
+		baseMisses = blockCacheMissMeter.Snapshot().Count()
+	)
+	if block := chain.GetBlockByHash(hash); block == nil || block.Hash() != hash {
+		t.Fatalf("failed to retrieve block %x", hash)
+	}
+	if hits, misses := blockCacheHitMeter.Snapshot().Count()-baseHits, blockCacheMissMeter.Snapshot().Count()-baseMisses; hits != 0 || misses != 1 {
+		t.Fatalf("cold lookup meters mismatch: have %d/%d hits/misses, want 0/1", hits, misses)
+	}
+	if block := chain.GetBlockByHash(hash); block == nil || block.Hash() != hash {
+		t.Fatalf("failed to retrieve cached block %x", hash)
+	}
+	if hits, misses := blockCacheHitMeter.Snapshot().Count()-baseHits, blockCacheMissMeter.Snapshot().Count()-baseMisses; hits != 1 || misses != 1 {
+		t.Fatalf("warm lookup meters mismatch: have %d/%d hits/misses, want 1/1", hits, misses)
+	}
+}
+
+// Tests that opening a database which lacks the Nitro genesis of the configured
+// Arbitrum chain, or holds another chain altogether, fails with a descriptive error.
+func TestArbitrumGenesisMismatch(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	(&Genesis{Config: params.TestChainConfig, BaseFee: big.NewInt(params.InitialBaseFee)}).MustCommit(db, triedb.NewDatabase(db, triedb.HashDefaults))
+
+	config := *params.TestChainConfig
+	config.ChainID = big.NewInt(412346)
+	config.ArbitrumChainParams.EnableArbOS = true
+
+	// The database holds a genesis at the expected height, but of another chain
+	_, err := NewBlockChain(db, nil, &config, nil, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	var genesisErr *ArbitrumGenesisError
+	if !errors.As(err, &genesisErr) || !errors.Is(err, ErrNoGenesis) {
+		t.Fatalf("expected genesis error, have %v", err)
+	}
+	if genesisErr.StoredChainID == nil || genesisErr.StoredChainID.Cmp(params.TestChainConfig.ChainID) != 0 {
+		t.Fatalf("stored chain id mismatch: have %v, want %v", genesisErr.StoredChainID, params.TestChainConfig.ChainID)
+	}
+	if !strings.Contains(err.Error(), "initialized for chain 1 but chain 412346") {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+
+	// The database has no block at the Nitro genesis height
+	config.ArbitrumChainParams.GenesisBlockNum = 7
+	_, err = NewBlockChain(db, nil, &config, nil, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if !errors.As(err, &genesisErr) || !errors.Is(err, ErrNoGenesis) {
+		t.Fatalf("expected genesis error, have %v", err)
+	}
+	if genesisErr.StoredChainID != nil || genesisErr.GenesisBlockNum != 7 || genesisErr.ChainID.Cmp(config.ChainID) != 0 {
+		t.Fatalf("unexpected error details: %+v", genesisErr)
+	}
+	if !strings.HasPrefix(genesisErr.HeadBlock, "#0 ") || !strings.HasPrefix(genesisErr.HeadHeader, "#0 ") {
+		t.Fatalf("head markers mismatch: block %s, header %s", genesisErr.HeadBlock, genesisErr.HeadHeader)
+	}
+
+	// An empty database reports no head markers at all
+	_, err = NewBlockChain(rawdb.NewMemoryDatabase(), nil, &config, nil, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if !errors.As(err, &genesisErr) || genesisErr.HeadBlock != "none" || genesisErr.HeadSnapBlock != "none" {
+		t.Fatalf("expected genesis error without heads, have %v", err)
+	}
+}
+
+// generateL2GasTestChain generates a chain of the given length with a few value
+// transfers in every block.
+func generateL2GasTestChain(n int) (*Genesis, []*types.Block, []types.Receipts) {
+	var (
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		engine  = ethash.NewFaker()
+		genesis = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		signer = types.LatestSigner(genesis.Config)
+	)
+	_, blocks, receipts := GenerateChainWithGenesis(genesis, engine, n, func(i int, b *BlockGen) {
+		for j := 0; j < i%4; j++ {
+			tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+				Nonce:    b.TxNonce(address),
+				To:       &common.Address{0x01},
+				Value:    big.NewInt(1),
+				Gas:      params.TxGas,
+				GasPrice: b.header.BaseFee,
+			})
+			b.AddTx(tx)
+		}
+	})
+	return genesis, blocks, receipts
+}
+
+// newL2GasTestChain creates a blockchain with the blocks of
+// generateL2GasTestChain imported.
+func newL2GasTestChain(t testing.TB, n int) (*BlockChain, []*types.Block) {
+	genesis, blocks, _ := generateL2GasTestChain(n)
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, nil, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	return chain, blocks
+}
+
+// Tests that the L2 gas used is stored when blocks are written, and derived
+// from the receipts for blocks written without it.
+func TestL2GasUsed(t *testing.T) {
+	chain, blocks := newL2GasTestChain(t, 16)
+	defer chain.Stop()
+
+	for _, block := range blocks {
+		want := uint64(len(block.Transactions())) * params.TxGas
+		if stored, ok := rawdb.ReadL2GasUsed(chain.db, block.Hash(), block.NumberU64()); !ok || stored != want {
+			t.Fatalf("block %d: stored L2 gas used mismatch: have %d (%v), want %d", block.NumberU64(), stored, ok, want)
+		}
+		if have := chain.L2GasUsed(block.Hash(), block.NumberU64()); have != want {
+			t.Fatalf("block %d: L2 gas used mismatch: have %d, want %d", block.NumberU64(), have, want)
+		}
+	}
+	// Drop the stored values, as if the blocks were written before they existed
+	chain.l2GasCache.Purge()
+	for _, block := range blocks {
+		rawdb.DeleteL2GasUsed(chain.db, block.Hash(), block.NumberU64())
+	}
+	for _, block := range blocks {
+		want := uint64(len(block.Transactions())) * params.TxGas
+		if have := chain.L2GasUsed(block.Hash(), block.NumberU64()); have != want {
+			t.Fatalf("block %d: derived L2 gas used mismatch: have %d, want %d", block.NumberU64(), have, want)
+		}
+	}
+	if have := chain.L2GasUsed(common.Hash{0x01}, 1); have != 0 {
+		t.Fatalf("unknown block L2 gas used mismatch: have %d, want 0", have)
+	}
+	if chain.HasReceipts(common.Hash{0x01}, 1) || !chain.HasReceipts(blocks[0].Hash(), 1) {
+		t.Fatal("receipts existence mismatch")
+	}
+}
+
+// Tests that the L2 gas used of blocks moved to the ancient store is kept.
+func TestL2GasUsedFrozen(t *testing.T) {
+	genesis, blocks, _ := generateL2GasTestChain(16)
+
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), "", "", false)
+	if err != nil {
+		t.Fatalf("failed to create freezer db: %v", err)
+	}
+	defer db.Close()
+	chain, err := NewBlockChain(db, nil, nil, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Freeze the blocks up to the finalized one
+	chain.SetFinalized(blocks[9].Header())
+	if err := db.(interface{ Freeze() error }).Freeze(); err != nil {
+		t.Fatalf("failed to freeze: %v", err)
+	}
+	if frozen, _ := db.Ancients(); frozen != 11 {
+		t.Fatalf("frozen blocks mismatch: have %d, want %d", frozen, 11)
+	}
+	for _, block := range blocks {
+		want := uint64(len(block.Transactions())) * params.TxGas
+		if stored, ok := rawdb.ReadL2GasUsed(db, block.Hash(), block.NumberU64()); !ok || stored != want {
+			t.Fatalf("block %d: stored L2 gas used mismatch: have %d (%v), want %d", block.NumberU64(), stored, ok, want)
+		}
+	}
+}
+
+// Tests that the L2 gas used is stored for blocks inserted along their receipts,
+// both into the ancient store and the key-value store.
+func TestL2GasUsedReceiptChain(t *testing.T) {
+	genesis, blocks, receipts := generateL2GasTestChain(16)
+
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), "", "", false)
+	if err != nil {
+		t.Fatalf("failed to create freezer db: %v", err)
+	}
+	defer db.Close()
+	chain, err := NewBlockChain(db, nil, nil, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if n, err := chain.InsertHeaderChain(headers); err != nil {
+		t.Fatalf("failed to insert header %d: %v", n, err)
+	}
+	if n, err := chain.InsertReceiptChain(blocks, receipts, uint64(len(blocks)/2)); err != nil {
+		t.Fatalf("failed to insert receipt %d: %v", n, err)
+	}
+	if frozen, _ := db.Ancients(); frozen != uint64(len(blocks)/2)+1 {
+		t.Fatalf("frozen blocks mismatch: have %d, want %d", frozen, len(blocks)/2+1)
+	}
+	for _, block := range blocks {
+		want := uint64(len(block.Transactions())) * params.TxGas
+		if stored, ok := rawdb.ReadL2GasUsed(db, block.Hash(), block.NumberU64()); !ok || stored != want {
+			t.Fatalf("block %d: stored L2 gas used mismatch: have %d (%v), want %d", block.NumberU64(), stored, ok, want)
+		}
+	}
+}
+
+// Tests that receipts with corrupted Arbitrum fields are restored by
+// re-executing their blocks, recreating the missing state if needed.
+func TestRepairReceiptRange(t *testing.T) {
+	chain, blocks := newL2GasTestChain(t, 16)
+	defer chain.Stop()
+
+	// Corrupt the receipts of a few blocks, as if synced from an older peer
+	for _, block := range blocks[4:8] {
+		receipts := rawdb.ReadRawReceipts(chain.db, block.Hash(), block.NumberU64())
+		for _, receipt := range receipts {
+			receipt.GasUsedForL1 = 12345
+		}
+		rawdb.WriteReceipts(chain.db, block.Hash(), block.NumberU64(), receipts)
+		rawdb.DeleteL2GasUsed(chain.db, block.Hash(), block.NumberU64())
+	}
+	chain.receiptsCache.Purge()
+	chain.l2GasCache.Purge()
+
+	if err := chain.RepairReceiptRange(5, 8, -1); err == nil {
+		t.Fatal("repaired receipts without finalized block")
+	}
+	chain.SetFinalized(blocks[11].Header())
+	if err := chain.RepairReceiptRange(9, 13, -1); err == nil {
+		t.Fatal("repaired receipts above finalized block")
+	}
+	if err := chain.RepairReceiptRange(0, 2, -1); err == nil {
+		t.Fatal("repaired receipts of genesis block")
+	}
+	// Drop the state of the parent of the range, it has to be recreated
+	chain.triedb.Dereference(blocks[3].Root())
+	if chain.HasState(blocks[3].Root()) {
+		t.Fatal("state of parent block still available")
+	}
+	depth := int64(len(blocks[3].Transactions())) * int64(params.TxGas)
+	for _, limit := range []int64{0, depth - 1} {
+		if err := chain.RepairReceiptRange(5, 8, limit); err == nil {
+			t.Fatalf("recreated state beyond depth limit %d", limit)
+		}
+	}
+	rangeLimit := maxReceiptRepairGas
+	maxReceiptRepairGas = blocks[4].GasUsed() + blocks[5].GasUsed()
+	if err := chain.RepairReceiptRange(5, 8, depth); err == nil {
+		t.Fatal("repaired receipts beyond range gas limit")
+	}
+	if err := chain.RepairReceiptRange(5, 6, depth); err != nil {
+		t.Fatalf("failed to repair receipts: %v", err)
+	}
+	maxReceiptRepairGas = rangeLimit
+	if err := chain.RepairReceiptRange(5, 8, -1); err != nil {
+		t.Fatalf("failed to repair receipts: %v", err)
+	}
+	for _, block := range blocks[:12] {
+		receipts := chain.GetReceiptsByHash(block.Hash())
+		if len(receipts) != len(block.Transactions()) {
+			t.Fatalf("block %d: receipt count mismatch: have %d, want %d", block.NumberU64(), len(receipts), len(block.Transactions()))
+		}
+		for i, receipt := range receipts {
+			if receipt.GasUsedForL1 != 0 || receipt.GasUsed != params.TxGas {
+				t.Fatalf("block %d receipt %d: gas used mismatch: have %d (L1 %d), want %d", block.NumberU64(), i, receipt.GasUsed, receipt.GasUsedForL1, params.TxGas)
+			}
+		}
+		want := uint64(len(block.Transactions())) * params.TxGas
+		if stored, ok := rawdb.ReadL2GasUsed(chain.db, block.Hash(), block.NumberU64()); !ok || stored != want {
+			t.Fatalf("block %d: stored L2 gas used mismatch: have %d (%v), want %d", block.NumberU64(), stored, ok, want)
+		}
+	}
+	chain.StopInsert()
+	if err := chain.RepairReceiptRange(5, 8, -1); !errors.Is(err, errInsertionInterrupted) {
+		t.Fatalf("repair error mismatch: have %v, want %v", err, errInsertionInterrupted)
+	}
+}
+
+// Tests that receipts snap synced into the ancient store with corrupted Arbitrum
+// fields can be repaired, unless they were pruned.
+func TestRepairReceiptRangeAncient(t *testing.T) {
+	genesis, blocks, receipts := generateL2GasTestChain(16)
+
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+	chain, err := NewBlockChain(db, nil, nil, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Snap sync the chain from a peer serving receipts with corrupted fields,
+	// moving the first 12 blocks to the ancient store
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+		for _, receipt := range receipts[i] {
+			receipt.GasUsedForL1 = 12345
+		}
+	}
+	if _, err := chain.InsertHeaderChain(headers); err != nil {
+		t.Fatalf("failed to insert headers: %v", err)
+	}
+	if _, err := chain.InsertReceiptChain(blocks, receipts, 12); err != nil {
+		t.Fatalf("failed to insert receipts: %v", err)
+	}
+	if frozen, _ := db.Ancients(); frozen != 13 {
+		t.Fatalf("ancient items mismatch: have %d, want 13", frozen)
+	}
+	chain.SetFinalized(blocks[11].Header())
+
+	// Only the genesis state is available, recreate from there
+	if err := chain.RepairReceiptRange(5, 8, -1); err != nil {
+		t.Fatalf("failed to repair receipts: %v", err)
+	}
+	for _, block := range blocks[:12] {
+		want := uint64(12345)
+		if number := block.NumberU64(); number >= 5 && number <= 8 {
+			want = 0
+		}
+		for i, receipt := range chain.GetReceiptsByHash(block.Hash()) {
+			if receipt.GasUsedForL1 != want {
+				t.Fatalf("block %d receipt %d: L1 gas used mismatch: have %d, want %d", block.NumberU64(), i, receipt.GasUsedForL1, want)
+			}
+		}
+	}
+	// Pruned receipts stay pruned and can't be repaired
+	if err := chain.PruneReceiptHistory(10); err != nil {
+		t.Fatalf("failed to prune receipt history: %v", err)
+	}
+	if receipts := chain.GetReceiptsByHash(blocks[4].Hash()); receipts != nil {
+		t.Fatal("repaired receipts of pruned block still present")
+	}
+	var pruned *ReceiptsPrunedError
+	if err := chain.RepairReceiptRange(5, 8, -1); !errors.As(err, &pruned) {
+		t.Fatalf("repair error mismatch: have %v, want receipts pruned", err)
+	}
+}
+
+// prunedHistoryDB is a database reporting history below the given block as pruned.
+type prunedHistoryDB struct {
+	ethdb.Database
+	tail uint64
+}
+
+func (db *prunedHistoryDB) Tail() (uint64, error) {
+	return db.tail, nil
+}
+
+// Tests that the gas rolled back while rewinding an Arbitrum chain is counted
+// correctly when receipts are missing or corrupted, and that the rewind stops
+// at the pruned history.
+func TestRewindGasUsedArbitrum(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		engine  = ethash.NewFaker()
+		config  = *params.TestChainConfig
+		genesis = &Genesis{
+			Config:  &config,
+			Alloc:   types.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		signer = types.LatestSigner(genesis.Config)
+	)
+	config.ArbitrumChainParams.EnableArbOS = true
+
+	_, blocks, _ := GenerateChainWithGenesis(genesis, engine, 8, func(i int, b *BlockGen) {
+		for j := 0; j < 2; j++ {
+			tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+				Nonce:    b.TxNonce(address),
+				To:       &common.Address{0x01},
+				Value:    big.NewInt(1),
+				Gas:      params.TxGas,
+				GasPrice: b.header.BaseFee,
+			})
+			b.AddTx(tx)
+		}
+	})
+	db := &prunedHistoryDB{Database: rawdb.NewMemoryDatabase()}
+	genesis.MustCommit(db, triedb.NewDatabase(db, triedb.HashDefaults))
+	chain, err := NewBlockChain(db, nil, &config, nil, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Pay half of the gas of every block for L1 data
+	for _, block := range blocks {
+		receipts := rawdb.ReadRawReceipts(db, block.Hash(), block.NumberU64())
+		for _, receipt := range receipts {
+			receipt.GasUsedForL1 = params.TxGas / 2
+		}
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts)
+		rawdb.DeleteL2GasUsed(db, block.Hash(), block.NumberU64())
+	}
+	var (
+		head  = blocks[7].Header()
+		root  = common.Hash{0xff} // Unreachable, rewind as far as the limit allows
+		l2Gas = params.TxGas      // L2 gas used by each block
+	)
+	rewind := func(limit uint64) uint64 {
+		t.Helper()
+		newHead, _, _, err := chain.rewindHashHead(head, root, limit)
+		if err != nil {
+			t.Fatalf("failed to rewind: %v", err)
+		}
+		return newHead.Number.Uint64()
+	}
+	if have := rewind(7*l2Gas + 1); have != 0 {
+		t.Fatalf("rewind with receipts: have head %d, want 0", have)
+	}
+	// Missing receipts count the full gas used of their block
+	for _, block := range blocks[5:7] {
+		rawdb.DeleteReceipts(db, block.Hash(), block.NumberU64())
+	}
+	if have := rewind(7*l2Gas + 1); have != 8 {
+		t.Fatalf("rewind with missing receipts: have head %d, want 8", have)
+	}
+	if have := rewind(9*l2Gas + 1); have != 0 {
+		t.Fatalf("rewind with missing receipts: have head %d, want 0", have)
+	}
+	// Corrupted receipts count the full gas used of their block as well
+	receipts := rawdb.ReadRawReceipts(db, blocks[4].Hash(), 5)
+	receipts[0].GasUsedForL1 = ^uint64(0)
+	rawdb.WriteReceipts(db, blocks[4].Hash(), 5, receipts)
+	receiptsKey := append(binary.BigEndian.AppendUint64([]byte("r"), 4), blocks[3].Hash().Bytes()...)
+	db.Put(receiptsKey, []byte{0xde, 0xad})
+
+	if have := rewind(11*l2Gas + 1); have != 0 {
+		t.Fatalf("rewind with corrupted receipts: have head %d, want 0", have)
+	}
+	if have := rewind(11 * l2Gas); have != 8 {
+		t.Fatalf("rewind with corrupted receipts: have head %d, want 8", have)
+	}
+	// Rewinding stops at the pruned history, there's no state below it
+	db.tail = 5
+	if have := rewind(0); have != 8 {
+		t.Fatalf("rewind beyond pruned history: have head %d, want 8", have)
+	}
+	// Without any state above the pruned history, the rewind fails instead of
+	// resetting the chain to genesis
+	db.tail = 9
+	if _, _, _, err := chain.rewindHashHead(head, root, 0); !errors.Is(err, errPrunedHistoryRewind) {
+		t.Fatalf("rewind without state: have %v, want %v", err, errPrunedHistoryRewind)
+	}
+	if _, _, err := chain.setHeadBeyondRoot(8, 0, root, true, 0); !errors.Is(err, errPrunedHistoryRewind) {
+		t.Fatalf("repair without state: have %v, want %v", err, errPrunedHistoryRewind)
+	}
+	if err := chain.SetHead(6); !errors.Is(err, errPrunedHistoryRewind) {
+		t.Fatalf("set head without state: have %v, want %v", err, errPrunedHistoryRewind)
+	}
+	if have := chain.CurrentBlock().Number.Uint64(); have != 8 {
+		t.Fatalf("head moved by failed rewinds: have %d, want 8", have)
+	}
+	if have := chain.CurrentHeader().Number.Uint64(); have != 8 {
+		t.Fatalf("header moved by failed rewinds: have %d, want 8", have)
+	}
+}
+
+// Tests that the state saving backlog of a sparse archive node is reported,
+// and that forcing a trie commit persists the head state and restarts it.
+func TestForceTrieCommit(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		engine  = ethash.NewFaker()
+		genesis = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		signer = types.LatestSigner(genesis.Config)
+	)
+	_, blocks, _ := GenerateChainWithGenesis(genesis, engine, 10, func(i int, b *BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+			Nonce:    b.TxNonce(address),
+			To:       &common.Address{0x01},
+			Value:    big.NewInt(1),
+			Gas:      params.TxGas,
+			GasPrice: b.header.BaseFee,
+		})
+		b.AddTx(tx)
+	})
+	cacheConfig := DefaultCacheConfigWithScheme(rawdb.HashScheme)
+	cacheConfig.TrieDirtyDisabled = true
+	cacheConfig.MaxNumberOfBlocksToSkipStateSaving = 100
+	cacheConfig.MaxAmountOfGasToSkipStateSaving = 100 * params.TxGas
+
+	db := rawdb.NewMemoryDatabase()
+	chain, err := NewBlockChain(db, cacheConfig, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// The state of the first block is committed, the others are skipped
+	blocksSkipped, gasSkipped, entries, oldest := chain.StateSavingBacklog()
+	if blocksSkipped != 9 || gasSkipped != 9*params.TxGas || entries != 9 || oldest != 2 {
+		t.Fatalf("backlog mismatch: have (%d, %d, %d, %d), want (9, %d, 9, 2)", blocksSkipped, gasSkipped, entries, oldest, 9*params.TxGas)
+	}
+	head := blocks[len(blocks)-1]
+	if rawdb.HasLegacyTrieNode(db, head.Root()) {
+		t.Fatal("head state persisted before commit")
+	}
+	if err := chain.ForceTrieCommit(); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	if !rawdb.HasLegacyTrieNode(db, head.Root()) || !chain.HasState(head.Root()) {
+		t.Fatal("head state not persisted by commit")
+	}
+	blocksSkipped, gasSkipped, _, _ = chain.StateSavingBacklog()
+	if blocksSkipped != 0 || gasSkipped != 0 {
+		t.Fatalf("backlog not reset: have (%d, %d)", blocksSkipped, gasSkipped)
+	}
+}
+
+// Tests that the state commit decisions of a sparse archive node are recorded
+// and that skipped commits are counted.
+func TestStateSavingDecisions(t *testing.T) {
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	oldCounter := stateSavingSkippedCounter
+	stateSavingSkippedCounter = metrics.NewCounter()
+	defer func() {
+		stateSavingSkippedCounter = oldCounter
+		metrics.Enabled = enabled
+	}()
+
+	var (
+		engine  = ethash.NewFaker()
+		genesis = &Genesis{
+			Config:  params.TestChainConfig,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+	)
+	_, blocks, _ := GenerateChainWithGenesis(genesis, engine, 6, nil)
+
+	cacheConfig := DefaultCacheConfigWithScheme(rawdb.HashScheme)
+	cacheConfig.TrieDirtyDisabled = true
+	cacheConfig.MaxNumberOfBlocksToSkipStateSaving = 3
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), cacheConfig, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	want := []StateSavingDecision{
+		{Number: 1, BlockLimitReached: true},
+		{Number: 2, Skipped: true, BlocksLeft: 2},
+		{Number: 3, Skipped: true, BlocksLeft: 1},
+		{Number: 4, Skipped: true, BlocksLeft: 0},
+		{Number: 5, BlockLimitReached: true},
+		{Number: 6, Skipped: true, BlocksLeft: 2},
+	}
+	for i, block := range blocks {
+		if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("failed to insert block %d: %v", block.NumberU64(), err)
+		}
+		if have := chain.LastStateSavingDecision(); have != want[i] {
+			t.Fatalf("block %d: decision mismatch: have %+v, want %+v", block.NumberU64(), have, want[i])
+		}
+	}
+	if have := stateSavingSkippedCounter.Snapshot().Count(); have != 4 {
+		t.Fatalf("skipped commits mismatch: have %d, want 4", have)
+	}
+}
+
+// countingProcessor is a Processor counting the blocks it processes.
+type countingProcessor struct {
+	*StateProcessor
+	blocks []uint64
+}
+
+func (p *countingProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	p.blocks = append(p.blocks, block.NumberU64())
+	return p.StateProcessor.Process(block, statedb, cfg)
+}
+
+// Tests that the processor and validator created by the factories are used
+// from the very first block.
+func TestBlockChainWithProcessors(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		genesis = &Genesis{
+			Config:  params.TestChainConfig,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		processor *countingProcessor
+		validator Validator
+	)
+	_, blocks, _ := GenerateChainWithGenesis(genesis, engine, 3, nil)
+
+	newProcessor := func(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine) Processor {
+		processor = &countingProcessor{StateProcessor: NewStateProcessor(config, bc, engine)}
+		return processor
+	}
+	newValidator := func(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine) Validator {
+		validator = NewBlockValidator(config, bc, engine)
+		return validator
+	}
+	chain, err := NewBlockChainWithProcessors(rawdb.NewMemoryDatabase(), nil, nil, genesis, nil, engine, vm.Config{}, nil, nil, newValidator, newProcessor)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if chain.Processor() != processor || chain.Validator() != validator {
+		t.Fatal("factory created processor or validator not installed")
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	if len(processor.blocks) != 3 || processor.blocks[0] != 1 {
+		t.Fatalf("processed blocks mismatch: have %v, want [1 2 3]", processor.blocks)
+	}
+}
+
+// newReceiptHistoryTestChain creates a chain of 16 blocks with one transaction
+// each, snap synced with the first 12 blocks moved to the ancient store.
+func newReceiptHistoryTestChain(t *testing.T) (*BlockChain, ethdb.Database, []*types.Block, []types.Receipts) {
+	var (
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		engine  = ethash.NewFaker()
+		genesis = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		signer = types.LatestSigner(genesis.Config)
+	)
+	_, blocks, receipts := GenerateChainWithGenesis(genesis, engine, 16, func(i int, b *BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+			Nonce:    b.TxNonce(address),
+			To:       &common.Address{0x01},
+			Value:    big.NewInt(1),
+			Gas:      params.TxGas,
+			GasPrice: b.header.BaseFee,
+		})
+		b.AddTx(tx)
+	})
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	chain, err := NewBlockChain(db, nil, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	t.Cleanup(chain.Stop)
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if _, err := chain.InsertHeaderChain(headers); err != nil {
+		t.Fatalf("failed to insert headers: %v", err)
+	}
+	if _, err := chain.InsertReceiptChain(blocks, receipts, 12); err != nil {
+		t.Fatalf("failed to insert receipts: %v", err)
+	}
+	if frozen, _ := db.Ancients(); frozen != 13 {
+		t.Fatalf("ancient items mismatch: have %d, want 13", frozen)
+	}
+	return chain, db, blocks, receipts
+}
+
+// Tests that pruning the receipt history drops the receipts of the ancient
+// blocks only, keeping their headers and bodies.
+func TestPruneReceiptHistory(t *testing.T) {
+	chain, db, blocks, _ := newReceiptHistoryTestChain(t)
+
+	// Keeping the last 10 blocks prunes the receipts of blocks 0-6
+	if err := chain.PruneReceiptHistory(10); err != nil {
+		t.Fatalf("failed to prune receipt history: %v", err)
+	}
+	for _, block := range blocks {
+		number := block.NumberU64()
+		if chain.GetBlockByNumber(number) == nil || chain.GetHeaderByNumber(number) == nil {
+			t.Fatalf("block %d: missing block after pruning receipts", number)
+		}
+		err := chain.CheckReceiptsPruned(number)
+		if number < 7 {
+			var pruned *ReceiptsPrunedError
+			if !errors.As(err, &pruned) || pruned.Tail != 7 {
+				t.Errorf("block %d: expected receipts pruned error, have %v", number, err)
+			}
+			if chain.GetReceiptsByHash(block.Hash()) != nil {
+				t.Errorf("block %d: receipts still present", number)
+			}
+		} else {
+			if err != nil {
+				t.Errorf("block %d: unexpected error: %v", number, err)
+			}
+			if len(chain.GetReceiptsByHash(block.Hash())) != 1 {
+				t.Errorf("block %d: receipts missing", number)
+			}
+		}
+	}
+	// Receipts of blocks which aren't ancient yet are never pruned
+	if err := chain.PruneReceiptHistory(0); err != nil {
+		t.Fatalf("failed to prune receipt history: %v", err)
+	}
+	if tail, _ := rawdb.ReadAncientReceiptsTail(db); tail != 13 {
+		t.Fatalf("receipts tail mismatch: have %d, want 13", tail)
+	}
+	if len(chain.GetReceiptsByHash(blocks[12].Hash())) != 1 {
+		t.Fatal("receipts of non-ancient block missing")
+	}
+}
+
+// Tests that the chain can be rewound below the tail of a pruned receipt history,
+// and that the receipts of the blocks imported again are stored.
+func TestPruneReceiptHistoryRewind(t *testing.T) {
+	chain, db, blocks, receipts := newReceiptHistoryTestChain(t)
+
+	if err := chain.PruneReceiptHistory(10); err != nil {
+		t.Fatalf("failed to prune receipt history: %v", err)
+	}
+	if err := chain.SetHead(3); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	frozen, _ := db.Ancients()
+	if frozen > 4 {
+		t.Fatalf("ancient items mismatch: have %d, want at most 4", frozen)
+	}
+	if tail, _ := rawdb.ReadAncientReceiptsTail(db); tail != frozen {
+		t.Fatalf("receipts tail mismatch: have %d, want %d", tail, frozen)
+	}
+	for _, block := range blocks[:frozen-1] {
+		if chain.GetHeaderByNumber(block.NumberU64()) == nil {
+			t.Fatalf("block %d: header missing after rewind", block.NumberU64())
+		}
+	}
+	// Import the blocks again, the receipts are stored in the ancient store
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if _, err := chain.InsertHeaderChain(headers[frozen-1:]); err != nil {
+		t.Fatalf("failed to insert headers: %v", err)
+	}
+	if _, err := chain.InsertReceiptChain(blocks[frozen-1:], receipts[frozen-1:], 12); err != nil {
+		t.Fatalf("failed to insert receipts: %v", err)
+	}
+	if have, _ := db.Ancients(); have != 13 {
+		t.Fatalf("ancient items mismatch: have %d, want 13", have)
+	}
+	for _, block := range blocks[frozen-1:] {
+		if err := chain.CheckReceiptsPruned(block.NumberU64()); err != nil {
+			t.Errorf("block %d: unexpected error: %v", block.NumberU64(), err)
+		}
+		if len(chain.GetReceiptsByHash(block.Hash())) != 1 {
+			t.Errorf("block %d: receipts missing", block.NumberU64())
+		}
+	}
+}
+
+// chainEventRecorder collects the events sent by a chain for comparison.
+type chainEventRecorder struct {
+	chainCh  chan ChainEvent
+	sideCh   chan ChainSideEvent
+	headCh   chan ChainHeadEvent
+	logsCh   chan []*types.Log
+	rmLogsCh chan RemovedLogsEvent
+}
+
+func newChainEventRecorder(chain *BlockChain) *chainEventRecorder {
+	r := &chainEventRecorder{
+		chainCh:  make(chan ChainEvent, 64),
+		sideCh:   make(chan ChainSideEvent, 64),
+		headCh:   make(chan ChainHeadEvent, 64),
+		logsCh:   make(chan []*types.Log, 64),
+		rmLogsCh: make(chan RemovedLogsEvent, 64),
+	}
+	chain.SubscribeChainEvent(r.chainCh)
+	chain.SubscribeChainSideEvent(r.sideCh)
+	chain.SubscribeChainHeadEvent(r.headCh)
+	chain.SubscribeLogsEvent(r.logsCh)
+	chain.SubscribeRemovedLogsEvent(r.rmLogsCh)
+	return r
+}
+
+// drain returns a description of every event received so far, per feed.
+func (r *chainEventRecorder) drain() map[string][]string {
+	var (
+		events   = make(map[string][]string)
+		describe = func(logs []*types.Log) string {
+			var desc string
+			for _, l := range logs {
+				desc += fmt.Sprintf("%x:%d:%v ", l.BlockHash[:4], l.Index, l.Removed)
+			}
+			return desc
+		}
+	)
+	for {
+		select {
+		case ev := <-r.chainCh:
+			events["chain"] = append(events["chain"], fmt.Sprintf("%x %s", ev.Hash[:4], describe(ev.Logs)))
+		case ev := <-r.sideCh:
+			events["side"] = append(events["side"], fmt.Sprintf("%x", ev.Block.Hash().Bytes()[:4]))
+		case ev := <-r.headCh:
+			events["head"] = append(events["head"], fmt.Sprintf("%x", ev.Block.Hash().Bytes()[:4]))
+		case logs := <-r.logsCh:
+			events["logs"] = append(events["logs"], describe(logs))
+		case ev := <-r.rmLogsCh:
+			events["removed"] = append(events["removed"], describe(ev.Logs))
+		default:
+			return events
+		}
+	}
+}
+
+// Tests that a reorg onto a known chain sends the same events whether it's
+// driven by SetCanonical or by InsertChain, including for the known blocks.
+func TestReorgEventsConsistent(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		engine  = ethash.NewFaker()
+		genesis = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  types.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(genesis.Config)
+	)
+	// Every block emits logs, the fork ones being heavier for the reorgs not
+	// to depend on the random tie-breaking of the fork choice
+	generate := func(n int, offset int64) []*types.Block {
+		_, blocks, _ := GenerateChainWithGenesis(genesis, engine, n, func(i int, b *BlockGen) {
+			b.OffsetTime(offset)
+			tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+				Nonce:    b.TxNonce(address),
+				GasPrice: b.header.BaseFee,
+				Gas:      1000000,
+				Data:     logCode,
+			})
+			b.AddTx(tx)
+		})
+		return blocks
+	}
+	var (
+		canon = generate(3, 0)
+		fork  = generate(4, -5)
+	)
+	reorg := func(setHead func(*BlockChain) error) map[string][]string {
+		// Without snapshots, known blocks following a re-executed one are
+		// written as they are
+		config := DefaultCacheConfigWithScheme(rawdb.HashScheme)
+		config.SnapshotLimit = 0
+
+		chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), config, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		defer chain.Stop()
+		if _, err := chain.InsertChain(canon); err != nil {
+			t.Fatalf("failed to insert chain: %v", err)
+		}
+		for _, block := range fork {
+			if err := chain.InsertBlockWithoutSetHead(block); err != nil {
+				t.Fatalf("failed to insert fork block: %v", err)
+			}
+		}
+		recorder := newChainEventRecorder(chain)
+		if err := setHead(chain); err != nil {
+			t.Fatalf("failed to set head: %v", err)
+		}
+		if head := chain.CurrentBlock().Hash(); head != fork[3].Hash() {
+			t.Fatalf("head mismatch: have %x, want %x", head, fork[3].Hash())
+		}
+		return recorder.drain()
+	}
+	viaSetCanonical := reorg(func(chain *BlockChain) error {
+		for _, block := range fork[2:] {
+			if _, err := chain.SetCanonical(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	// The first block reorgs the chain, the second one is already known
+	viaInsertChain := reorg(func(chain *BlockChain) error {
+		_, err := chain.InsertChain(fork[2:])
+		return err
+	})
+	// InsertChain only fires a single head event for the batch
+	if heads := viaInsertChain["head"]; len(heads) != 1 || heads[0] != fmt.Sprintf("%x", fork[3].Hash().Bytes()[:4]) {
+		t.Errorf("head events mismatch: %v", heads)
+	}
+	for _, feed := range []string{"chain", "side", "logs", "removed"} {
+		if len(viaSetCanonical[feed]) == 0 {
+			t.Errorf("%s feed: no events sent", feed)
+		}
+		if !slices.Equal(viaSetCanonical[feed], viaInsertChain[feed]) {
+			t.Errorf("%s feed mismatch:\nSetCanonical: %v\nInsertChain:  %v", feed, viaSetCanonical[feed], viaInsertChain[feed])
+		}
+	}
+}
+
+// Tests that starting from an external ancient store with receipts not matching
+// their headers fails, unless the check is skipped.
+func TestAncientReceiptsCheck(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		genesis = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  types.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(genesis.Config)
+	)
+	_, blocks, receipts := GenerateChainWithGenesis(genesis, ethash.NewFaker(), 32, func(i int, b *BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+			Nonce:    b.TxNonce(address),
+			To:       &common.Address{0xaa},
+			GasPrice: b.header.BaseFee,
+			Gas:      params.TxGas,
+		})
+		b.AddTx(tx)
+	})
+	// freeze writes the chain to a new ancient store, replacing the receipts
+	// of the last block with the given ones if any
+	freeze := func(last types.Receipts) string {
+		dir := t.TempDir()
+		db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), dir, "", false)
+		if err != nil {
+			t.Fatalf("failed to create freezer db: %v", err)
+		}
+		defer db.Close()
+		chain, err := NewBlockChain(db, nil, nil, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		defer chain.Stop()
+
+		headers := make([]*types.Header, len(blocks))
+		for i, block := range blocks {
+			headers[i] = block.Header()
+		}
+		if n, err := chain.InsertHeaderChain(headers); err != nil {
+			t.Fatalf("failed to insert header %d: %v", n, err)
+		}
+		frozenReceipts := slices.Clone(receipts)
+		if last != nil {
+			frozenReceipts[len(frozenReceipts)-1] = last
+		}
+		if n, err := chain.InsertReceiptChain(blocks, frozenReceipts, uint64(len(blocks))); err != nil {
+			t.Fatalf("failed to insert receipt %d: %v", n, err)
+		}
+		if frozen, _ := db.Ancients(); frozen != uint64(len(blocks))+1 {
+			t.Fatalf("frozen blocks mismatch: have %d, want %d", frozen, len(blocks)+1)
+		}
+		return dir
+	}
+	// start creates a chain from the ancient store only, as if copied over
+	start := func(dir string, skipCheck bool) error {
+		db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), dir, "", false)
+		if err != nil {
+			t.Fatalf("failed to open freezer db: %v", err)
+		}
+		defer db.Close()
+
+		config := DefaultCacheConfigWithScheme(rawdb.HashScheme)
+		config.SkipAncientReceiptsCheck = skipCheck
+		chain, err := NewBlockChain(db, config, nil, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+		if err != nil {
+			return err
+		}
+		defer chain.Stop()
+		if head := chain.CurrentSnapBlock().Number.Uint64(); head != uint64(len(blocks)) {
+			t.Errorf("head snap block mismatch: have %d, want %d", head, len(blocks))
+		}
+		return nil
+	}
+	if err := start(freeze(nil), false); err != nil {
+		t.Fatalf("failed to start from valid ancient store: %v", err)
+	}
+	// Tamper with the status of the last receipt
+	corrupted := *receipts[len(receipts)-1][0]
+	corrupted.Status = types.ReceiptStatusFailed
+	dir := freeze(types.Receipts{&corrupted})
+
+	var ancientErr *AncientReceiptsError
+	if err := start(dir, false); !errors.As(err, &ancientErr) || ancientErr.Number != uint64(len(blocks)) {
+		t.Fatalf("error mismatch: have %v, want invalid receipts of block %d", err, len(blocks))
+	}
+	if err := start(dir, true); err != nil {
+		t.Fatalf("failed to start with the check skipped: %v", err)
+	}
+}
+
+// Tests that receipt imports are reported as block processing, with nested
+// imports only notifying subscribers once.
+func TestBlockProcessingReceiptImport(t *testing.T) {
+	genesis := &Genesis{Config: params.TestChainConfig, BaseFee: big.NewInt(params.InitialBaseFee)}
+	_, blocks, receipts := GenerateChainWithGenesis(genesis, ethash.NewFaker(), 4, nil)
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, nil, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	events := make(chan bool, 8)
+	sub := chain.SubscribeBlockProcessingEvent(events)
+	defer sub.Unsubscribe()
+
+	checkEvents := func(want ...bool) {
+		t.Helper()
+		var have []bool
+		for len(events) > 0 {
+			have = append(have, <-events)
+		}
+		if !slices.Equal(have, want) {
+			t.Fatalf("block processing events mismatch: have %v, want %v", have, want)
+		}
+	}
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if n, err := chain.InsertHeaderChain(headers); err != nil {
+		t.Fatalf("failed to insert header %d: %v", n, err)
+	}
+	// An import nested in another one doesn't toggle the state
+	chain.startBlockProcessing()
+	checkEvents(true)
+	if n, err := chain.InsertReceiptChain(blocks[:2], receipts[:2], 0); err != nil {
+		t.Fatalf("failed to insert receipt %d: %v", n, err)
+	}
+	checkEvents()
+	if !chain.BlockProcessing() {
+		t.Fatal("block processing ended with the nested import")
+	}
+	chain.stopBlockProcessing()
+	checkEvents(false)
+	if chain.BlockProcessing() {
+		t.Fatal("block processing not ended")
+	}
+	// A standalone import is reported on its own
+	if n, err := chain.InsertReceiptChain(blocks[2:], receipts[2:], 0); err != nil {
+		t.Fatalf("failed to insert receipt %d: %v", n, err)
+	}
+	checkEvents(true, false)
+	if head := chain.CurrentSnapBlock().Number.Uint64(); head != uint64(len(blocks)) {
+		t.Errorf("head snap block mismatch: have %d, want %d", head, len(blocks))
+	}
+}
+
+// Tests that concurrent imports notify block processing subscribers with
+// alternating events, ending with the processing stopped.
+func TestBlockProcessingConcurrent(t *testing.T) {
+	genesis := &Genesis{Config: params.TestChainConfig, BaseFee: big.NewInt(params.InitialBaseFee)}
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, nil, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	events := make(chan bool)
+	sub := chain.SubscribeBlockProcessingEvent(events)
+	defer sub.Unsubscribe()
+
+	var (
+		have []bool
+		done = make(chan struct{})
+		quit = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case ev := <-events:
+				have = append(have, ev)
+			case <-quit:
+				return
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				chain.startBlockProcessing()
+				chain.stopBlockProcessing()
+			}
+		}()
+	}
+	wg.Wait()
+	close(quit)
+	<-done
+
+	if len(have) == 0 || len(have)%2 != 0 {
+		t.Fatalf("unbalanced block processing events: %d sent", len(have))
+	}
+	for i, ev := range have {
+		if ev != (i%2 == 0) {
+			t.Fatalf("block processing event %d out of order: have %v", i, ev)
+		}
+	}
+	if chain.BlockProcessing() {
+		t.Fatal("block processing not ended")
+	}
+}
+
+func TestCacheConfigValidate(t *testing.T) {
+	archive := func(blocks uint32, gas, restoreGas uint64) *CacheConfig {
+		config := DefaultCacheConfigWithScheme(rawdb.HashScheme)
+		config.TrieDirtyDisabled = true
+		config.MaxNumberOfBlocksToSkipStateSaving = blocks
+		config.MaxAmountOfGasToSkipStateSaving = gas
+		config.SnapshotRestoreMaxGas = restoreGas
+		return config
+	}
+	tests := []struct {
+		name     string
+		config   *CacheConfig
+		err      bool
+		warnings int
+	}{
+		{name: "hash defaults", config: DefaultCacheConfigWithScheme(rawdb.HashScheme)},
+		{name: "path defaults", config: DefaultCacheConfigWithScheme(rawdb.PathScheme)},
+		{name: "archive", config: archive(0, 0, 300_000_000)},
+		{name: "sparse archive within restore gas", config: archive(100, 100_000_000, 300_000_000)},
+		{name: "sparse archive without restore limit", config: archive(100, 0, 0)},
+		{
+			name: "no tries in memory",
+			config: func() *CacheConfig {
+				config := DefaultCacheConfigWithScheme(rawdb.HashScheme)
+				config.TriesInMemory = 0
+				return config
+			}(),
+			err: true,
+		},
+		{
+			name: "no tries in memory with path scheme",
+			config: func() *CacheConfig {
+				config := DefaultCacheConfigWithScheme(rawdb.PathScheme)
+				config.TriesInMemory = 0
+				return config
+			}(),
+		},
+		{
+			name: "no tries in memory in archive",
+			config: func() *CacheConfig {
+				config := archive(0, 0, 0)
+				config.TriesInMemory = 0
+				return config
+			}(),
+		},
+		{
+			name: "negative retention",
+			config: func() *CacheConfig {
+				config := DefaultCacheConfigWithScheme(rawdb.HashScheme)
+				config.TrieRetention = -time.Minute
+				return config
+			}(),
+			err: true,
+		},
+		{name: "sparse archive over restore gas", config: archive(0, 500_000_000, 300_000_000), warnings: 1},
+		{name: "sparse archive with unbounded gas", config: archive(100, 0, 300_000_000), warnings: 1},
+		{
+			name: "sparse archive over restore gas without snapshots",
+			config: func() *CacheConfig {
+				config := archive(0, 500_000_000, 300_000_000)
+				config.SnapshotLimit = 0
+				return config
+			}(),
+		},
+		{
+			name: "skip limits on full node",
+			config: func() *CacheConfig {
+				config := archive(100, 0, 0)
+				config.TrieDirtyDisabled = false
+				return config
+			}(),
+			warnings: 1,
+		},
+		{
+			name: "skip limits with path scheme",
+			config: func() *CacheConfig {
+				config := archive(0, 100_000_000, 0)
+				config.StateScheme = rawdb.PathScheme
+				return config
+			}(),
+			warnings: 1,
+		},
+	}
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err != nil) != tt.err {
+			t.Errorf("%s: error mismatch: have %v, want error %v", tt.name, err, tt.err)
+		}
+		if !tt.err {
+			if warnings := tt.config.warnings(); len(warnings) != tt.warnings {
+				t.Errorf("%s: warnings mismatch: have %v, want %d", tt.name, warnings, tt.warnings)
+			}
+		}
+	}
+}
+
+// Tests that re-importing known blocks after a rollback of the head, as snap
+// sync does, doesn't rewind the chain over the canonical ones below the head.
+func TestWriteKnownCanonicalBlock(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		engine  = ethash.NewFaker()
+		genesis = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  types.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(genesis.Config)
+	)
+	_, blocks, _ := GenerateChainWithGenesis(genesis, engine, 10, func(i int, b *BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+			Nonce:    b.TxNonce(address),
+			GasPrice: b.header.BaseFee,
+			Gas:      1000000,
+			Data:     logCode,
+		})
+		b.AddTx(tx)
+	})
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Roll the head back, leaving the blocks above it known and canonical
+	chain.writeHeadBlock(blocks[4])
+
+	recorder := newChainEventRecorder(chain)
+	for _, block := range blocks[2:] {
+		if err := chain.writeKnownBlock(block); err != nil {
+			t.Fatalf("failed to write known block %d: %v", block.NumberU64(), err)
+		}
+	}
+	if head := chain.CurrentBlock().Number.Uint64(); head != 10 {
+		t.Fatalf("head mismatch: have %d, want %d", head, 10)
+	}
+	events := recorder.drain()
+	if removed := events["removed"]; len(removed) != 0 {
+		t.Errorf("logs removed: %v", removed)
+	}
+	// Only the blocks above the rolled back head become canonical again
+	if have := len(events["chain"]); have != 5 {
+		t.Errorf("chain events mismatch: have %d, want %d", have, 5)
+	}
+	for _, block := range blocks {
+		if hash := chain.GetCanonicalHash(block.NumberU64()); hash != block.Hash() {
+			t.Fatalf("block %d: canonical hash mismatch", block.NumberU64())
+		}
+	}
+}
+
+func TestMultiGasActivationOverride(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		config  = *params.TestChainConfig
+		genesis = &Genesis{
+			Config:  &config,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		cacheConfig = *DefaultCacheConfigWithScheme(rawdb.HashScheme)
+		override    = func(time uint64) *ChainOverrides {
+			return &ChainOverrides{OverrideMultiGas: &time}
+		}
+	)
+	config.ArbitrumChainParams.EnableArbOS = true
+	cacheConfig.TrieDirtyDisabled = true
+	// Blocks are 10 seconds apart, the head is at time 100
+	_, blocks, _ := GenerateChainWithGenesis(genesis, engine, 10, nil)
+
+	db := rawdb.NewMemoryDatabase()
+	genesis.MustCommit(db, triedb.NewDatabase(db, triedb.HashDefaults))
+	chain, err := NewBlockChain(db, &cacheConfig, &config, nil, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	chain.Stop()
+
+	// reopen reopens the chain with the given overrides, checking the head and
+	// the activation time it ends up with
+	reopen := func(overrides *ChainOverrides, head uint64, activation *uint64) {
+		t.Helper()
+		chain, err := NewBlockChain(db, &cacheConfig, &config, nil, overrides, engine, vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to reopen chain: %v", err)
+		}
+		defer chain.Stop()
+		if have := chain.CurrentBlock().Number.Uint64(); have != head {
+			t.Errorf("head mismatch: have %d, want %d", have, head)
+		}
+		if have := chain.Config().ArbitrumChainParams.MultiGasActivationTime; !reflect.DeepEqual(have, activation) {
+			t.Errorf("activation time mismatch: have %v, want %v", have, activation)
+		}
+		stored := rawdb.ReadChainConfig(db, chain.Genesis().Hash())
+		if have := stored.ArbitrumChainParams.MultiGasActivationTime; !reflect.DeepEqual(have, activation) {
+			t.Errorf("stored activation time mismatch: have %v, want %v", have, activation)
+		}
+	}
+	// Scheduling the activation ahead of the head keeps the chain
+	reopen(override(200), 10, override(200).OverrideMultiGas)
+	// Activating it within the processed history rewinds the chain before it
+	reopen(override(45), 4, override(45).OverrideMultiGas)
+	if config.ArbitrumChainParams.MultiGasActivationTime != nil {
+		t.Error("override leaked into the given config")
+	}
+}
+
+func TestChainInsertEvents(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		genesis = &Genesis{
+			Config:  params.TestChainConfig,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		// heavier makes blocks of a higher difficulty than the default ones
+		heavier = func(i int, b *BlockGen) { b.OffsetTime(-9) }
+	)
+	genDb, blocks, _ := GenerateChainWithGenesis(genesis, engine, 5, nil)
+	oneBlockFork, _ := GenerateChain(genesis.Config, blocks[3], engine, genDb, 1, heavier)
+	multiBlockFork, _ := GenerateChain(genesis.Config, blocks[1], engine, genDb, 4, heavier)
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	events := make(chan ChainInsertEvent, 16)
+	sub := chain.SubscribeChainInsertEvent(events)
+	defer sub.Unsubscribe()
+
+	// insert inserts the given blocks, returning the insertion events
+	insert := func(blocks types.Blocks) []ChainInsertEvent {
+		t.Helper()
+		if _, err := chain.InsertChain(blocks); err != nil {
+			t.Fatalf("failed to insert chain: %v", err)
+		}
+		var received []ChainInsertEvent
+		for {
+			select {
+			case ev := <-events:
+				received = append(received, ev)
+			default:
+				return received
+			}
+		}
+	}
+	// check checks an event against the expected block and outcome
+	check := func(ev ChainInsertEvent, block *types.Block, status WriteStatus, added, dropped int) {
+		t.Helper()
+		if ev.Block.Hash() != block.Hash() {
+			t.Fatalf("block mismatch: have %d, want %d", ev.Block.NumberU64(), block.NumberU64())
+		}
+		if ev.Status != status || ev.Added != added || ev.Dropped != dropped || ev.Reorg != (dropped > 0) {
+			t.Errorf("block %d: outcome mismatch: have status %d added %d dropped %d reorg %v, want status %d added %d dropped %d",
+				block.NumberU64(), ev.Status, ev.Added, ev.Dropped, ev.Reorg, status, added, dropped)
+		}
+		if ev.ProcTime <= 0 {
+			t.Errorf("block %d: missing processing time", block.NumberU64())
+		}
+	}
+	// Plain extension
+	received := insert(blocks)
+	if len(received) != len(blocks) {
+		t.Fatalf("extension: event count mismatch: have %d, want %d", len(received), len(blocks))
+	}
+	for i, ev := range received {
+		check(ev, blocks[i], CanonStatTy, 1, 0)
+	}
+	// One-block reorg, replacing the head
+	received = insert(oneBlockFork)
+	if len(received) != 1 {
+		t.Fatalf("one-block reorg: event count mismatch: have %d, want %d", len(received), 1)
+	}
+	check(received[0], oneBlockFork[0], CanonStatTy, 1, 1)
+
+	// Multi-block reorg, the fork blocks are side blocks until the fork gets
+	// heavier than the canonical chain, which reorgs blocks 3 to 5 at once
+	received = insert(multiBlockFork)
+	if len(received) != len(multiBlockFork) {
+		t.Fatalf("multi-block reorg: event count mismatch: have %d, want %d", len(received), len(multiBlockFork))
+	}
+	reorged := slices.IndexFunc(received, func(ev ChainInsertEvent) bool { return ev.Status == CanonStatTy })
+	if reorged < 1 {
+		t.Fatalf("multi-block reorg: expected side blocks before the reorg, reorg at %d", reorged)
+	}
+	for i, ev := range received {
+		switch {
+		case i < reorged:
+			check(ev, multiBlockFork[i], SideStatTy, 0, 0)
+		case i == reorged:
+			check(ev, multiBlockFork[i], CanonStatTy, i+1, 3)
+		default:
+			check(ev, multiBlockFork[i], CanonStatTy, 1, 0)
+		}
+	}
+	if head := chain.CurrentBlock().Hash(); head != multiBlockFork[3].Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head, multiBlockFork[3].Hash())
+	}
+}
+
+// Tests that malformed header extra information is only reported, the ArbOS
+// version read from the headers being unchanged.
+func TestHeaderArbOSVersion(t *testing.T) {
+	config := *params.TestChainConfig
+	config.ArbitrumChainParams.EnableArbOS = true
+
+	header := &types.Header{
+		Number:     big.NewInt(1000),
+		Difficulty: common.Big1,
+		BaseFee:    big.NewInt(params.InitialBaseFee),
+	}
+	types.HeaderInfo{ArbOSFormatVersion: params.ArbosVersion_20}.UpdateHeaderWithInfo(header)
+	classic := types.CopyHeader(header)
+	classic.BaseFee = nil
+	truncated := types.CopyHeader(header)
+	truncated.Extra = truncated.Extra[:16]
+	garbage := types.CopyHeader(header)
+	garbage.MixDigest[31] = 0xff
+
+	for _, test := range []struct {
+		name   string
+		header *types.Header
+		want   uint64
+	}{
+		{"nitro", header, params.ArbosVersion_20},
+		{"classic", classic, 0},
+		{"truncated extra", truncated, 0},
+		{"garbage mix digest", garbage, params.ArbosVersion_20},
+	} {
+		if have := headerArbOSVersion(&config, test.header); have != test.want {
+			t.Errorf("%s: version mismatch: have %d, want %d", test.name, have, test.want)
+		}
+		if have := headerArbOSVersion(params.TestChainConfig, test.header); have != test.want {
+			t.Errorf("%s: non-arbitrum version mismatch: have %d, want %d", test.name, have, test.want)
+		}
+	}
+}
+
+// Tests that the roots awaiting garbage collection are tracked as they are
+// queued and collected.
+func TestTrieGCContains(t *testing.T) {
+	genesis := &Genesis{Config: params.TestChainConfig, BaseFee: big.NewInt(params.InitialBaseFee)}
+	_, blocks, _ := GenerateChainWithGenesis(genesis, ethash.NewFaker(), 8, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{byte(i + 1)})
+	})
+	cacheConfig := *DefaultCacheConfigWithScheme(rawdb.HashScheme)
+	cacheConfig.TriesInMemory = 4
+	cacheConfig.TrieRetention = 0
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), &cacheConfig, nil, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Only the states of the last TriesInMemory blocks are still queued
+	for _, block := range blocks {
+		want := block.NumberU64() > uint64(len(blocks))-cacheConfig.TriesInMemory
+		if have := chain.TrieGCContains(block.Root()); have != want {
+			t.Errorf("block %d: queued state mismatch: have %v, want %v", block.NumberU64(), have, want)
+		}
+	}
+	if chain.TrieGCContains(common.Hash{0x01}) {
+		t.Error("unknown root reported as queued")
+	}
+	// Stopping the chain releases all of them
+	chain.Stop()
+	for _, block := range blocks {
+		if chain.TrieGCContains(block.Root()) {
+			t.Errorf("block %d: state still queued after stop", block.NumberU64())
+		}
+	}
+}
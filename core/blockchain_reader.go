@@ -98,8 +98,10 @@ func (bc *BlockChain) GetHeadersFrom(number, count uint64) []rlp.RawValue {
 func (bc *BlockChain) GetBody(hash common.Hash) *types.Body {
 	// Short circuit if the body's already in the cache, retrieve otherwise
 	if cached, ok := bc.bodyCache.Get(hash); ok {
+		bodyCacheHitMeter.Mark(1)
 		return cached
 	}
+	bodyCacheMissMeter.Mark(1)
 	number := bc.hc.GetBlockNumber(hash)
 	if number == nil {
 		return nil
@@ -118,8 +120,10 @@ func (bc *BlockChain) GetBody(hash common.Hash) *types.Body {
 func (bc *BlockChain) GetBodyRLP(hash common.Hash) rlp.RawValue {
 	// Short circuit if the body's already in the cache, retrieve otherwise
 	if cached, ok := bc.bodyRLPCache.Get(hash); ok {
+		bodyCacheHitMeter.Mark(1)
 		return cached
 	}
+	bodyCacheMissMeter.Mark(1)
 	number := bc.hc.GetBlockNumber(hash)
 	if number == nil {
 		return nil
@@ -160,8 +164,10 @@ func (bc *BlockChain) HasFastBlock(hash common.Hash, number uint64) bool {
 func (bc *BlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
 	// Short circuit if the block's already in the cache, retrieve otherwise
 	if block, ok := bc.blockCache.Get(hash); ok {
+		blockCacheHitMeter.Mark(1)
 		return block
 	}
+	blockCacheMissMeter.Mark(1)
 	block := rawdb.ReadBlock(bc.db, hash, number)
 	if block == nil {
 		return nil
@@ -212,8 +218,10 @@ func (bc *BlockChain) GetBlocksFromHash(hash common.Hash, n int) (blocks []*type
 // GetReceiptsByHash retrieves the receipts for all transactions in a given block.
 func (bc *BlockChain) GetReceiptsByHash(hash common.Hash) types.Receipts {
 	if receipts, ok := bc.receiptsCache.Get(hash); ok {
+		receiptsCacheHitMeter.Mark(1)
 		return receipts
 	}
+	receiptsCacheMissMeter.Mark(1)
 	number := rawdb.ReadHeaderNumber(bc.db, hash)
 	if number == nil {
 		return nil
@@ -271,8 +279,10 @@ func (bc *BlockChain) GetTransactionLookup(hash common.Hash) (*rawdb.LegacyTxLoo
 
 	// Short circuit if the txlookup already in the cache, retrieve otherwise
 	if item, exist := bc.txLookupCache.Get(hash); exist {
+		txLookupCacheHitMeter.Mark(1)
 		return item.lookup, item.transaction, nil
 	}
+	txLookupCacheMissMeter.Mark(1)
 	tx, blockHash, blockNumber, txIndex := rawdb.ReadTransaction(bc.db, hash)
 	if tx == nil {
 		progress, err := bc.TxIndexProgress()
@@ -408,6 +418,13 @@ func (bc *BlockChain) TxIndexProgress() (TxIndexProgress, error) {
 	return bc.txIndexer.txIndexProgress()
 }
 
+// TxIndexDone reports whether the transaction indexer has finished indexing
+// the configured range of the chain.
+func (bc *BlockChain) TxIndexDone() bool {
+	progress, err := bc.TxIndexProgress()
+	return err == nil && progress.Done()
+}
+
 // TrieDB retrieves the low level trie database used for data storage.
 func (bc *BlockChain) TrieDB() *triedb.Database {
 	return bc.triedb
@@ -420,7 +437,7 @@ func (bc *BlockChain) HeaderChain() *HeaderChain {
 
 // SubscribeRemovedLogsEvent registers a subscription of RemovedLogsEvent.
 func (bc *BlockChain) SubscribeRemovedLogsEvent(ch chan<- RemovedLogsEvent) event.Subscription {
-	return bc.scope.Track(bc.rmLogsFeed.Subscribe(ch))
+	return bc.scope.Track(bc.rmLogsScope.Track(bc.rmLogsFeed.Subscribe(ch)))
 }
 
 // SubscribeChainEvent registers a subscription of ChainEvent.
@@ -438,9 +455,24 @@ func (bc *BlockChain) SubscribeChainSideEvent(ch chan<- ChainSideEvent) event.Su
 	return bc.scope.Track(bc.chainSideFeed.Subscribe(ch))
 }
 
+// SubscribeChainInsertEvent registers a subscription of ChainInsertEvent.
+func (bc *BlockChain) SubscribeChainInsertEvent(ch chan<- ChainInsertEvent) event.Subscription {
+	return bc.scope.Track(bc.insertFeed.Subscribe(ch))
+}
+
+// SubscribeFinalizedHeaderEvent registers a subscription of FinalizedHeaderEvent.
+func (bc *BlockChain) SubscribeFinalizedHeaderEvent(ch chan<- FinalizedHeaderEvent) event.Subscription {
+	return bc.scope.Track(bc.finalizedFeed.Subscribe(ch))
+}
+
 // SubscribeLogsEvent registers a subscription of []*types.Log.
 func (bc *BlockChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
-	return bc.scope.Track(bc.logsFeed.Subscribe(ch))
+	return bc.scope.Track(bc.logsScope.Track(bc.logsFeed.Subscribe(ch)))
+}
+
+// BlockProcessing reports whether blocks or receipts are being imported.
+func (bc *BlockChain) BlockProcessing() bool {
+	return bc.procCounter.Load() > 0
 }
 
 // SubscribeBlockProcessingEvent registers a subscription of bool where true means
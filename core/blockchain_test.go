@@ -22,6 +22,7 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"slices"
 	"sync"
 	"testing"
 	"time"
@@ -1331,6 +1332,93 @@ func checkLogEvents(t *testing.T, logsCh <-chan []*types.Log, rmLogsCh <-chan Re
 	}
 }
 
+// Tests that a deep reorg announces the removed and reborn logs of every block
+// in order, and that the reorg still goes through without any log subscribers.
+func TestDeepReorgLogEvents(t *testing.T) {
+	var (
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: types.GenesisAlloc{addr1: {Balance: big.NewInt(10000000000000000)}}}
+		signer  = types.LatestSigner(gspec.Config)
+		engine  = ethash.NewFaker()
+	)
+	makeChain := func(n int, gas uint64) (types.Blocks, []types.Receipts) {
+		_, blocks, receipts := GenerateChainWithGenesis(gspec, engine, n, func(i int, gen *BlockGen) {
+			tx, err := types.SignNewTx(key1, signer, &types.LegacyTx{
+				Nonce:    gen.TxNonce(addr1),
+				GasPrice: gen.header.BaseFee,
+				Gas:      gas,
+				Data:     logCode,
+			})
+			if err != nil {
+				t.Fatalf("failed to create tx: %v", err)
+			}
+			gen.AddTx(tx)
+		})
+		return blocks, receipts
+	}
+	chain, chainReceipts := makeChain(100, 1000001)
+	fork, forkReceipts := makeChain(101, 1000000)
+
+	// The announced logs are identified by the transactions emitting them
+	logTxs := func(receipts []types.Receipts) (hashes []common.Hash) {
+		for _, blockReceipts := range receipts {
+			for _, receipt := range blockReceipts {
+				for range receipt.Logs {
+					hashes = append(hashes, receipt.TxHash)
+				}
+			}
+		}
+		return hashes
+	}
+	for _, subscribe := range []bool{true, false} {
+		blockchain, _ := NewBlockChain(rawdb.NewMemoryDatabase(), nil, nil, gspec, nil, engine, vm.Config{}, nil, nil)
+
+		newLogCh := make(chan []*types.Log, 1024)
+		rmLogsCh := make(chan RemovedLogsEvent, 1024)
+		if subscribe {
+			blockchain.SubscribeLogsEvent(newLogCh)
+			blockchain.SubscribeRemovedLogsEvent(rmLogsCh)
+		}
+		if _, err := blockchain.InsertChain(chain); err != nil {
+			t.Fatalf("failed to insert chain: %v", err)
+		}
+		for len(newLogCh) > 0 {
+			<-newLogCh
+		}
+		if _, err := blockchain.InsertChain(fork); err != nil {
+			t.Fatalf("failed to insert forked chain: %v", err)
+		}
+		if head := blockchain.CurrentBlock(); head.Hash() != fork[len(fork)-1].Hash() {
+			t.Fatalf("head mismatch: have #%d %x, want #%d %x", head.Number, head.Hash(), fork[len(fork)-1].NumberU64(), fork[len(fork)-1].Hash())
+		}
+		blockchain.Stop()
+		if !subscribe {
+			continue
+		}
+		var removed, reborn []common.Hash
+		for len(rmLogsCh) > 0 {
+			for _, log := range (<-rmLogsCh).Logs {
+				if !log.Removed {
+					t.Fatalf("removed log not marked as removed: %v", log)
+				}
+				removed = append(removed, log.TxHash)
+			}
+		}
+		for len(newLogCh) > 0 {
+			for _, log := range <-newLogCh {
+				reborn = append(reborn, log.TxHash)
+			}
+		}
+		if want := logTxs(chainReceipts); !slices.Equal(removed, want) {
+			t.Fatalf("removed logs mismatch: have %d logs, want %d", len(removed), len(want))
+		}
+		if want := logTxs(forkReceipts); !slices.Equal(reborn, want) {
+			t.Fatalf("reborn logs mismatch: have %d logs, want %d", len(reborn), len(want))
+		}
+	}
+}
+
 func TestReorgSideEvent(t *testing.T) {
 	testReorgSideEvent(t, rawdb.HashScheme)
 	testReorgSideEvent(t, rawdb.PathScheme)
@@ -4221,3 +4309,78 @@ func TestEIP3651(t *testing.T) {
 		t.Fatalf("sender balance incorrect: expected %d, got %d", expected, actual)
 	}
 }
+
+// Tests that the checked finalized and safe setters reject side chain headers,
+// refuse to move the finalized block backwards and announce finalized headers.
+func TestSetFinalizedChecked(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		genesis = &Genesis{
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Config:  params.AllEthashProtocolChanges,
+		}
+	)
+	genDb, blocks, _ := GenerateChainWithGenesis(genesis, engine, 8, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{1}) })
+	fork, _ := GenerateChain(genesis.Config, blocks[2], engine, genDb, 1, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{2}) })
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	if _, err := chain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	events := make(chan FinalizedHeaderEvent, 4)
+	sub := chain.SubscribeFinalizedHeaderEvent(events)
+	defer sub.Unsubscribe()
+
+	// Side chain headers must be rejected
+	if err := chain.SetFinalizedChecked(fork[0].Header(), false); !errors.Is(err, errNonCanonicalHeader) {
+		t.Fatalf("finalized side chain header: have %v, want %v", err, errNonCanonicalHeader)
+	}
+	if err := chain.SetSafeChecked(fork[0].Header()); !errors.Is(err, errNonCanonicalHeader) {
+		t.Fatalf("safe side chain header: have %v, want %v", err, errNonCanonicalHeader)
+	}
+	if chain.CurrentFinalBlock() != nil || chain.CurrentSafeBlock() != nil {
+		t.Fatalf("rejected headers were stored")
+	}
+	// Canonical headers are accepted and announced
+	if err := chain.SetFinalizedChecked(blocks[5].Header(), false); err != nil {
+		t.Fatalf("failed to set finalized block: %v", err)
+	}
+	if err := chain.SetSafeChecked(blocks[6].Header()); err != nil {
+		t.Fatalf("failed to set safe block: %v", err)
+	}
+	if have, want := chain.CurrentFinalBlock().Hash(), blocks[5].Hash(); have != want {
+		t.Fatalf("finalized block mismatch: have %x, want %x", have, want)
+	}
+	if have, want := chain.CurrentSafeBlock().Hash(), blocks[6].Hash(); have != want {
+		t.Fatalf("safe block mismatch: have %x, want %x", have, want)
+	}
+	select {
+	case ev := <-events:
+		if ev.Header.Hash() != blocks[5].Hash() {
+			t.Fatalf("finalized event mismatch: have %x, want %x", ev.Header.Hash(), blocks[5].Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no finalized event received")
+	}
+	// The finalized block may only move backwards if forced
+	if err := chain.SetFinalizedChecked(blocks[4].Header(), false); !errors.Is(err, errFinalizedRewind) {
+		t.Fatalf("finalized rewind: have %v, want %v", err, errFinalizedRewind)
+	}
+	if err := chain.SetFinalizedChecked(nil, false); !errors.Is(err, errFinalizedRewind) {
+		t.Fatalf("finalized reset: have %v, want %v", err, errFinalizedRewind)
+	}
+	if err := chain.SetFinalizedChecked(blocks[4].Header(), true); err != nil {
+		t.Fatalf("failed to force finalized block: %v", err)
+	}
+	if have, want := rawdb.ReadFinalizedBlockHash(chain.db), blocks[4].Hash(); have != want {
+		t.Fatalf("persisted finalized block mismatch: have %x, want %x", have, want)
+	}
+}
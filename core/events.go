@@ -17,6 +17,8 @@
 package core
 
 import (
+	"time"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 )
@@ -41,3 +43,17 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// ChainInsertEvent is posted when a block is written to the chain, either as
+// the new head or as a side block, describing the outcome of the write.
+type ChainInsertEvent struct {
+	Block    *types.Block
+	Status   WriteStatus
+	Reorg    bool          // Whether canonical blocks were dropped for this one
+	Added    int           // Number of blocks made canonical, the block included
+	Dropped  int           // Number of canonical blocks dropped
+	ProcTime time.Duration // Time spent processing the block, if it was processed
+}
+
+// FinalizedHeaderEvent is posted when the finalized block is updated.
+type FinalizedHeaderEvent struct{ Header *types.Header }
@@ -0,0 +1,297 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// summaryExportBatch is the number of headers read at once by ExportSummaries.
+const summaryExportBatch = 256
+
+var (
+	errSummaryChecksum  = errors.New("block summary stream checksum mismatch")
+	errSummaryTruncated = errors.New("block summary stream truncated")
+)
+
+// BlockSummary is the compact description of a block written by
+// ExportSummaries, for consumers only interested in the shape of the chain.
+type BlockSummary struct {
+	Number       uint64
+	Hash         common.Hash
+	ParentHash   common.Hash
+	Time         uint64
+	GasUsed      uint64
+	GasUsedForL1 uint64 // Total over the receipts of the block
+	TxHashes     []common.Hash
+}
+
+// ExportSummaries writes the summaries of the canonical blocks first to last
+// (inclusive) to the given writer, as a stream of RLP lists followed by the
+// keccak256 checksum of the summaries as an RLP string, so that a truncated
+// stream can be detected.
+func (bc *BlockChain) ExportSummaries(w io.Writer, first, last uint64) error {
+	if first > last {
+		return fmt.Errorf("export failed: first (%d) is greater than last (%d)", first, last)
+	}
+	log.Info("Exporting block summaries", "count", last-first+1)
+
+	var (
+		hasher     = crypto.NewKeccakState()
+		out        = io.MultiWriter(w, hasher)
+		parentHash common.Hash
+		start      = time.Now()
+		reported   = time.Now()
+	)
+	for nr := first; nr <= last; {
+		count := min(last-nr+1, summaryExportBatch)
+		headers := rawdb.ReadHeaderRange(bc.db, nr+count-1, count)
+		if uint64(len(headers)) != count {
+			return fmt.Errorf("export failed on #%d: headers not found", nr)
+		}
+		// Headers are returned from the highest one down
+		for i := len(headers) - 1; i >= 0; i-- {
+			header := new(types.Header)
+			if err := rlp.DecodeBytes(headers[i], header); err != nil {
+				return fmt.Errorf("export failed on #%d: %w", nr, err)
+			}
+			hash := header.Hash()
+			if nr > first && header.ParentHash != parentHash {
+				return errors.New("export failed: chain reorg during export")
+			}
+			parentHash = hash
+
+			body := rawdb.ReadBody(bc.db, hash, nr)
+			if body == nil {
+				return fmt.Errorf("export failed on #%d: body not found", nr)
+			}
+			summary := &BlockSummary{
+				Number:     nr,
+				Hash:       hash,
+				ParentHash: header.ParentHash,
+				Time:       header.Time,
+				GasUsed:    header.GasUsed,
+				TxHashes:   make([]common.Hash, len(body.Transactions)),
+			}
+			for j, tx := range body.Transactions {
+				summary.TxHashes[j] = tx.Hash()
+			}
+			if len(body.Transactions) > 0 {
+				gasUsedForL1, ok := bc.ReceiptsGasUsedForL1(hash, nr)
+				if !ok {
+					return fmt.Errorf("export failed on #%d: receipts not found", nr)
+				}
+				for _, gas := range gasUsedForL1 {
+					summary.GasUsedForL1 += gas
+				}
+			}
+			if err := rlp.Encode(out, summary); err != nil {
+				return err
+			}
+			if time.Since(reported) >= statsReportLimit {
+				log.Info("Exporting block summaries", "exported", nr-first, "elapsed", common.PrettyDuration(time.Since(start)))
+				reported = time.Now()
+			}
+			nr++
+		}
+	}
+	return rlp.Encode(w, hasher.Sum(nil))
+}
+
+// BlockSummaryReader reads a stream of block summaries written by
+// ExportSummaries, verifying its checksum once the end is reached.
+type BlockSummaryReader struct {
+	stream *rlp.Stream
+	hasher crypto.KeccakState
+	done   bool
+}
+
+// NewBlockSummaryReader creates a reader of the block summaries in r.
+func NewBlockSummaryReader(r io.Reader) *BlockSummaryReader {
+	return &BlockSummaryReader{
+		stream: rlp.NewStream(r, 0),
+		hasher: crypto.NewKeccakState(),
+	}
+}
+
+// Next returns the next block summary of the stream. It returns io.EOF once
+// all summaries were read and the checksum matched, or an error if the stream
+// is truncated or corrupted.
+func (r *BlockSummaryReader) Next() (*BlockSummary, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	kind, _, err := r.stream.Kind()
+	if err == io.EOF {
+		return nil, errSummaryTruncated
+	} else if err != nil {
+		return nil, err
+	}
+	if kind != rlp.List {
+		// Reached the trailer, check it against the summaries read so far
+		var checksum []byte
+		if err := r.stream.Decode(&checksum); err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(checksum, r.hasher.Sum(nil)) {
+			return nil, errSummaryChecksum
+		}
+		r.done = true
+		return nil, io.EOF
+	}
+	raw, err := r.stream.Raw()
+	if err != nil {
+		if err == io.EOF {
+			err = errSummaryTruncated
+		}
+		return nil, err
+	}
+	r.hasher.Write(raw)
+	summary := new(BlockSummary)
+	if err := rlp.DecodeBytes(raw, summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// ChainSegmentError reports the first invalid block of a chain segment.
+type ChainSegmentError struct {
+	Number uint64 // Number of the invalid block
+	Err    error
+}
+
+func (e *ChainSegmentError) Error() string {
+	return fmt.Sprintf("invalid block #%d in chain segment: %v", e.Number, e.Err)
+}
+
+func (e *ChainSegmentError) Unwrap() error { return e.Err }
+
+// segmentHeaderReader serves the headers of the chain, as well as the last
+// validated header of a chain segment, so that the consensus engine can verify
+// the next one against it.
+type segmentHeaderReader struct {
+	*BlockChain
+	parent *types.Header
+}
+
+func (r *segmentHeaderReader) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if number == r.parent.Number.Uint64() && hash == r.parent.Hash() {
+		return r.parent
+	}
+	return r.BlockChain.GetHeader(hash, number)
+}
+
+func (r *segmentHeaderReader) GetHeaderByHash(hash common.Hash) *types.Header {
+	if hash == r.parent.Hash() {
+		return r.parent
+	}
+	return r.BlockChain.GetHeaderByHash(hash)
+}
+
+// ValidateChainSegment verifies a stream of RLP encoded blocks, as written by
+// ExportN, extending the given parent header, without writing anything to the
+// database. The blocks must be contiguous, their headers valid according to the
+// consensus engine and their bodies match their headers. If statedb, holding
+// the state of parent, is given, the blocks are also executed on top of it and
+// their post state, receipts and gas checked against their headers. Note that
+// the BLOCKHASH of the blocks of the segment only resolves on chains taking it
+// from the state, like Arbitrum ones.
+//
+// The stream is read one block at a time, a compressed one must be wrapped by
+// the caller. It returns the number of valid blocks and a ChainSegmentError for
+// the first invalid one, if any.
+func (bc *BlockChain) ValidateChainSegment(r io.Reader, parent *types.Header, statedb *state.StateDB) (int, error) {
+	var (
+		stream   = rlp.NewStream(r, 0)
+		reader   = &segmentHeaderReader{BlockChain: bc, parent: parent}
+		start    = time.Now()
+		reported = time.Now()
+	)
+	for n := 0; ; n++ {
+		number := parent.Number.Uint64() + 1
+		block := new(types.Block)
+		if err := stream.Decode(block); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, &ChainSegmentError{Number: number, Err: fmt.Errorf("failed to decode block: %w", err)}
+		}
+		if err := bc.validateSegmentBlock(reader, block, statedb); err != nil {
+			return n, &ChainSegmentError{Number: number, Err: err}
+		}
+		parent = block.Header()
+		reader.parent = parent
+
+		if time.Since(reported) >= statsReportLimit {
+			log.Info("Validating chain segment", "validated", n+1, "number", number, "elapsed", common.PrettyDuration(time.Since(start)))
+			reported = time.Now()
+		}
+	}
+}
+
+// validateSegmentBlock verifies the next block of a chain segment, the parent
+// of which is the last header of the reader.
+func (bc *BlockChain) validateSegmentBlock(reader *segmentHeaderReader, block *types.Block, statedb *state.StateDB) error {
+	header, parent := block.Header(), reader.parent
+	if header.Number.Uint64() != parent.Number.Uint64()+1 {
+		return fmt.Errorf("non contiguous block: number %d after %d", header.Number, parent.Number)
+	}
+	if header.ParentHash != parent.Hash() {
+		return fmt.Errorf("parent hash mismatch: have %x, want %x", header.ParentHash, parent.Hash())
+	}
+	if err := bc.engine.VerifyHeader(reader, header); err != nil {
+		return fmt.Errorf("invalid header: %w", err)
+	}
+	// Check the body against the header, as the block validator does
+	if hash := types.CalcUncleHash(block.Uncles()); hash != header.UncleHash {
+		return fmt.Errorf("uncle root hash mismatch (header value %x, calculated %x)", header.UncleHash, hash)
+	}
+	if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != header.TxHash {
+		return fmt.Errorf("transaction root hash mismatch (header value %x, calculated %x)", header.TxHash, hash)
+	}
+	if header.WithdrawalsHash != nil {
+		if block.Withdrawals() == nil {
+			return errors.New("missing withdrawals in block body")
+		}
+		if hash := types.DeriveSha(block.Withdrawals(), trie.NewStackTrie(nil)); hash != *header.WithdrawalsHash {
+			return fmt.Errorf("withdrawals root hash mismatch (header value %x, calculated %x)", *header.WithdrawalsHash, hash)
+		}
+	} else if block.Withdrawals() != nil {
+		return errors.New("withdrawals present in block body")
+	}
+	if statedb == nil {
+		return nil
+	}
+	receipts, _, usedGas, err := bc.processor.Process(block, statedb, vm.Config{})
+	if err != nil {
+		return err
+	}
+	return bc.validator.ValidateState(block, statedb, receipts, usedGas)
+}
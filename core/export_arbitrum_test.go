@@ -0,0 +1,226 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func readBlockSummaries(data []byte) ([]*BlockSummary, error) {
+	var (
+		reader    = NewBlockSummaryReader(bytes.NewReader(data))
+		summaries []*BlockSummary
+	)
+	for {
+		summary, err := reader.Next()
+		if err == io.EOF {
+			return summaries, nil
+		}
+		if err != nil {
+			return summaries, err
+		}
+		summaries = append(summaries, summary)
+	}
+}
+
+// Tests that block summaries are exported and read back intact, and that
+// truncated or corrupted streams are detected.
+func TestExportSummaries(t *testing.T) {
+	chain, blocks := newL2GasTestChain(t, 100)
+	defer chain.Stop()
+
+	// Pay some of the gas of every transaction for L1 data
+	for _, block := range blocks {
+		receipts := rawdb.ReadRawReceipts(chain.db, block.Hash(), block.NumberU64())
+		for i, receipt := range receipts {
+			receipt.GasUsedForL1 = uint64(1000 * (i + 1))
+		}
+		rawdb.WriteReceipts(chain.db, block.Hash(), block.NumberU64(), receipts)
+	}
+	chain.receiptsCache.Purge()
+
+	var buf bytes.Buffer
+	if err := chain.ExportSummaries(&buf, 1, 100); err != nil {
+		t.Fatalf("failed to export summaries: %v", err)
+	}
+	summaries, err := readBlockSummaries(buf.Bytes())
+	if err != nil {
+		t.Fatalf("failed to read summaries: %v", err)
+	}
+	if len(summaries) != len(blocks) {
+		t.Fatalf("summary count mismatch: have %d, want %d", len(summaries), len(blocks))
+	}
+	for i, block := range blocks {
+		summary := summaries[i]
+		if summary.Number != block.NumberU64() || summary.Hash != block.Hash() || summary.ParentHash != block.ParentHash() {
+			t.Fatalf("block %d: identity mismatch: have %d %x %x", block.NumberU64(), summary.Number, summary.Hash, summary.ParentHash)
+		}
+		if summary.Time != block.Time() || summary.GasUsed != block.GasUsed() {
+			t.Fatalf("block %d: header fields mismatch: have time %d gas %d", block.NumberU64(), summary.Time, summary.GasUsed)
+		}
+		var wantL1 uint64
+		for j := range block.Transactions() {
+			wantL1 += uint64(1000 * (j + 1))
+		}
+		if summary.GasUsedForL1 != wantL1 {
+			t.Fatalf("block %d: L1 gas mismatch: have %d, want %d", block.NumberU64(), summary.GasUsedForL1, wantL1)
+		}
+		if len(summary.TxHashes) != len(block.Transactions()) {
+			t.Fatalf("block %d: tx count mismatch: have %d, want %d", block.NumberU64(), len(summary.TxHashes), len(block.Transactions()))
+		}
+		for j, tx := range block.Transactions() {
+			if summary.TxHashes[j] != tx.Hash() {
+				t.Fatalf("block %d: tx %d hash mismatch", block.NumberU64(), j)
+			}
+		}
+	}
+	// Streams cut at any point, or with altered content, must be rejected
+	data := buf.Bytes()
+	for _, cut := range []int{1, 10, 33, len(data) / 2} {
+		if _, err := readBlockSummaries(data[:len(data)-cut]); err == nil {
+			t.Errorf("truncated stream (%d bytes cut) accepted", cut)
+		}
+	}
+	corrupted := bytes.Clone(data)
+	corrupted[len(corrupted)/2] ^= 0x01
+	if _, err := readBlockSummaries(corrupted); err == nil {
+		t.Error("corrupted stream accepted")
+	}
+	// The summary of the last block ends right before the 33 byte trailer
+	if _, err := readBlockSummaries(data[:len(data)-33]); !errors.Is(err, errSummaryTruncated) {
+		t.Errorf("stream without trailer: have %v, want %v", err, errSummaryTruncated)
+	}
+	if err := chain.ExportSummaries(io.Discard, 1, 101); err == nil {
+		t.Error("export beyond the head succeeded")
+	}
+}
+
+// Tests that exported chain segments are validated without being imported,
+// and that the first invalid block of corrupted segments is reported.
+func TestValidateChainSegment(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		engine  = ethash.NewFaker()
+		genesis = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		signer = types.LatestSigner(genesis.Config)
+	)
+	_, blocks, _ := GenerateChainWithGenesis(genesis, engine, 20, func(i int, b *BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+			Nonce:    b.TxNonce(address),
+			To:       &common.Address{0x01},
+			Value:    big.NewInt(1),
+			Gas:      params.TxGas,
+			GasPrice: b.header.BaseFee,
+		})
+		b.AddTx(tx)
+	})
+	source, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer source.Stop()
+	if _, err := source.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	var segment bytes.Buffer
+	if err := source.ExportN(&segment, 11, 20); err != nil {
+		t.Fatalf("failed to export chain: %v", err)
+	}
+	// The chain validating the segment only has its parent
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, nil, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks[:10]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	parent := blocks[9].Header()
+
+	validate := func(data []byte, execute bool) (int, error) {
+		var statedb *state.StateDB
+		if execute {
+			var err error
+			if statedb, err = chain.StateAt(parent.Root); err != nil {
+				t.Fatalf("failed to open parent state: %v", err)
+			}
+		}
+		return chain.ValidateChainSegment(bytes.NewReader(data), parent, statedb)
+	}
+	check := func(name string, data []byte, execute bool, wantValid int, wantInvalid uint64) {
+		t.Helper()
+		n, err := validate(data, execute)
+		if n != wantValid {
+			t.Errorf("%s: valid blocks mismatch: have %d, want %d", name, n, wantValid)
+		}
+		var segmentErr *ChainSegmentError
+		if wantInvalid == 0 && err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		} else if wantInvalid != 0 && (!errors.As(err, &segmentErr) || segmentErr.Number != wantInvalid) {
+			t.Errorf("%s: error mismatch: have %v, want invalid block #%d", name, err, wantInvalid)
+		}
+	}
+	check("valid", segment.Bytes(), false, 10, 0)
+	check("valid executed", segment.Bytes(), true, 10, 0)
+	if head := chain.CurrentBlock().Number.Uint64(); head != 10 || chain.HasBlock(blocks[10].Hash(), 11) {
+		t.Fatalf("segment imported: head %d", head)
+	}
+	// Flip a bit of the signature of the transaction of block 15
+	enc, _ := blocks[14].Transactions()[0].MarshalBinary()
+	corrupted := bytes.Clone(segment.Bytes())
+	corrupted[bytes.Index(corrupted, enc)+len(enc)-1] ^= 0x01
+	check("bit flip in body", corrupted, false, 4, 15)
+
+	// Leave block 14 out
+	var gap bytes.Buffer
+	for _, block := range append(slices.Clone(blocks[10:13]), blocks[14:]...) {
+		block.EncodeRLP(&gap)
+	}
+	check("gap in numbers", gap.Bytes(), false, 3, 14)
+
+	// A wrong state root is only caught by executing the blocks
+	var badRoot bytes.Buffer
+	for _, block := range blocks[10:19] {
+		block.EncodeRLP(&badRoot)
+	}
+	header := blocks[19].Header()
+	header.Root = common.Hash{0x01}
+	types.NewBlockWithHeader(header).WithBody(*blocks[19].Body()).EncodeRLP(&badRoot)
+	check("bad state root", badRoot.Bytes(), false, 10, 0)
+	check("bad state root executed", badRoot.Bytes(), true, 9, 20)
+
+	check("truncated", segment.Bytes()[:segment.Len()-1], false, 9, 20)
+}
@@ -246,6 +246,9 @@ func (e *GenesisMismatchError) Error() string {
 type ChainOverrides struct {
 	OverrideCancun *uint64
 	OverrideVerkle *uint64
+
+	// Arbitrum
+	OverrideMultiGas *uint64
 }
 
 // SetupGenesisBlock writes or updates the genesis block in db.
@@ -277,6 +280,9 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, triedb *triedb.Database, g
 			if overrides != nil && overrides.OverrideVerkle != nil {
 				config.VerkleTime = overrides.OverrideVerkle
 			}
+			if overrides != nil && overrides.OverrideMultiGas != nil {
+				config.ArbitrumChainParams.MultiGasActivationTime = overrides.OverrideMultiGas
+			}
 		}
 	}
 	// Just commit the new block if there is no stored genesis block.
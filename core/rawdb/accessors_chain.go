@@ -576,6 +576,13 @@ func ReadReceiptsRLP(db ethdb.Reader, hash common.Hash, number uint64) rlp.RawVa
 		// Check if the data is in ancients
 		if isCanon(reader, number, hash) {
 			data, _ = reader.Ancient(ChainFreezerReceiptTable, number)
+			// Arbitrum: receipts repaired after the block was moved to the
+			// ancient store are written to leveldb, unless they were pruned
+			if len(data) > 0 {
+				if repaired, _ := db.Get(blockReceiptsKey(number, hash)); len(repaired) > 0 {
+					data = repaired
+				}
+			}
 			return nil
 		}
 		// If not, try reading from leveldb
@@ -846,10 +853,14 @@ func DeleteBlock(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
 	DeleteHeader(db, hash, number)
 	DeleteBody(db, hash, number)
 	DeleteTd(db, hash, number)
+	DeleteL2GasUsed(db, hash, number)
 }
 
 // DeleteBlockWithoutNumber removes all block data associated with a hash, except
 // the hash to number mapping.
+//
+// Arbitrum: the L2 gas used isn't moved to the ancient store, so it's kept for
+// the blocks being frozen.
 func DeleteBlockWithoutNumber(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
 	DeleteReceipts(db, hash, number)
 	deleteHeaderWithoutNumber(db, hash, number)
@@ -952,6 +963,9 @@ func DeleteBadBlocks(db ethdb.KeyValueWriter) {
 	if err := db.Delete(badBlockKey); err != nil {
 		log.Crit("Failed to delete bad blocks", "err", err)
 	}
+	if err := db.Delete(badBlockDetailKey); err != nil {
+		log.Crit("Failed to delete bad block details", "err", err)
+	}
 }
 
 // FindCommonAncestor returns the last common ancestor of two block headers
@@ -0,0 +1,222 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ReadLastStopCommitHash retrieves the hash of the most recent block whose
+// state was successfully committed to disk during the last shutdown.
+func ReadLastStopCommitHash(db ethdb.KeyValueReader) common.Hash {
+	data, _ := db.Get(lastStopCommitKey)
+	if len(data) != common.HashLength {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteLastStopCommitHash stores the hash of the most recent block whose state
+// was successfully committed to disk during shutdown.
+func WriteLastStopCommitHash(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Put(lastStopCommitKey, hash.Bytes()); err != nil {
+		log.Crit("Failed to store last stop commit hash", "err", err)
+	}
+}
+
+// DeleteLastStopCommitHash removes the shutdown state commit marker.
+func DeleteLastStopCommitHash(db ethdb.KeyValueWriter) {
+	if err := db.Delete(lastStopCommitKey); err != nil {
+		log.Crit("Failed to remove last stop commit hash", "err", err)
+	}
+}
+
+// BadBlockDetail is the context recorded alongside a bad block: the error which
+// made it invalid and the receipts generated before the failure was detected.
+type BadBlockDetail struct {
+	Hash     common.Hash
+	Error    string
+	Receipts []*types.ReceiptForStorage
+}
+
+func readBadBlockDetails(db ethdb.KeyValueReader) []*BadBlockDetail {
+	blob, err := db.Get(badBlockDetailKey)
+	if err != nil {
+		return nil
+	}
+	var details []*BadBlockDetail
+	if err := rlp.DecodeBytes(blob, &details); err != nil {
+		return nil
+	}
+	return details
+}
+
+// ReadBadBlockDetail retrieves the context recorded for the bad block with the
+// given hash. Nil is returned if the block was stored without any context.
+func ReadBadBlockDetail(db ethdb.KeyValueReader, hash common.Hash) *BadBlockDetail {
+	for _, detail := range readBadBlockDetails(db) {
+		if detail.Hash == hash {
+			return detail
+		}
+	}
+	return nil
+}
+
+// WriteBadBlockDetail stores the context of a bad block, which has to be written
+// with WriteBadBlock first. Only the context of the blocks retained in the bad
+// block list is kept, so that both are evicted alike.
+func WriteBadBlockDetail(db ethdb.KeyValueStore, hash common.Hash, receipts types.Receipts, reason error) {
+	var badBlocks []*badBlock
+	if blob, err := db.Get(badBlockKey); err == nil {
+		if err := rlp.DecodeBytes(blob, &badBlocks); err != nil {
+			log.Crit("Failed to decode bad blocks", "err", err)
+		}
+	}
+	retained := make(map[common.Hash]bool)
+	for _, bad := range badBlocks {
+		retained[bad.Header.Hash()] = true
+	}
+	if !retained[hash] {
+		return
+	}
+	var details []*BadBlockDetail
+	for _, detail := range readBadBlockDetails(db) {
+		if detail.Hash == hash {
+			return
+		}
+		if retained[detail.Hash] {
+			details = append(details, detail)
+		}
+	}
+	detail := &BadBlockDetail{
+		Hash:     hash,
+		Receipts: make([]*types.ReceiptForStorage, len(receipts)),
+	}
+	if reason != nil {
+		detail.Error = reason.Error()
+	}
+	for i, receipt := range receipts {
+		detail.Receipts[i] = (*types.ReceiptForStorage)(receipt)
+	}
+	details = append(details, detail)
+	data, err := rlp.EncodeToBytes(details)
+	if err != nil {
+		log.Crit("Failed to encode bad block details", "err", err)
+	}
+	if err := db.Put(badBlockDetailKey, data); err != nil {
+		log.Crit("Failed to write bad block details", "err", err)
+	}
+}
+
+// ReadL2GasUsed retrieves the L2 gas used by the given block, that is the gas
+// used by its transactions minus the part paying for L1 data. It reports false
+// if the value wasn't stored along the block.
+func ReadL2GasUsed(db ethdb.KeyValueReader, hash common.Hash, number uint64) (uint64, bool) {
+	data, _ := db.Get(l2GasUsedKey(number, hash))
+	if len(data) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data), true
+}
+
+// WriteL2GasUsed stores the L2 gas used by the given block.
+func WriteL2GasUsed(db ethdb.KeyValueWriter, hash common.Hash, number uint64, gasUsed uint64) {
+	if err := db.Put(l2GasUsedKey(number, hash), binary.BigEndian.AppendUint64(nil, gasUsed)); err != nil {
+		log.Crit("Failed to store block L2 gas used", "err", err)
+	}
+}
+
+// DeleteL2GasUsed removes the L2 gas used stored for the given block.
+func DeleteL2GasUsed(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
+	if err := db.Delete(l2GasUsedKey(number, hash)); err != nil {
+		log.Crit("Failed to delete block L2 gas used", "err", err)
+	}
+}
+
+// ReadReceiptsGasUsedForL1 retrieves the gas paying for L1 data of every receipt
+// of the given block. The value is picked from the storage encoding without
+// decoding the logs, falling back to a full decode if the encoding isn't
+// recognized. It reports false if the receipts are missing or invalid.
+func ReadReceiptsGasUsedForL1(db ethdb.Reader, hash common.Hash, number uint64) ([]uint64, bool) {
+	data := ReadReceiptsRLP(db, hash, number)
+	if len(data) == 0 {
+		return nil, false
+	}
+	if gasUsed, err := splitReceiptsGasUsedForL1(data); err == nil {
+		return gasUsed, true
+	}
+	var receipts []*types.ReceiptForStorage
+	if err := rlp.DecodeBytes(data, &receipts); err != nil {
+		log.Error("Invalid receipt array RLP", "hash", hash, "err", err)
+		return nil, false
+	}
+	gasUsed := make([]uint64, len(receipts))
+	for i, receipt := range receipts {
+		gasUsed[i] = receipt.GasUsedForL1
+	}
+	return gasUsed, true
+}
+
+// splitReceiptsGasUsedForL1 extracts the gas paying for L1 data from a list of
+// receipts in storage encoding, which is either
+//
+//	[status, cumulativeGasUsed, gasUsedForL1, logs, contractAddress?]
+//
+// or, for receipts of Arbitrum legacy transactions marked by a 0x00 status,
+//
+//	[0x00, cumulativeGasUsed, gasUsed, gasUsedForL1, status, contractAddress, logs]
+func splitReceiptsGasUsedForL1(data []byte) ([]uint64, error) {
+	list, rest, err := rlp.SplitList(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("trailing data after receipt list")
+	}
+	var gasUsed []uint64
+	for len(list) > 0 {
+		var receipt []byte
+		if receipt, list, err = rlp.SplitList(list); err != nil {
+			return nil, err
+		}
+		status, fields, err := rlp.SplitString(receipt)
+		if err != nil {
+			return nil, err
+		}
+		if _, fields, err = rlp.SplitUint64(fields); err != nil { // cumulativeGasUsed
+			return nil, err
+		}
+		if len(status) == 1 && status[0] == 0x00 {
+			if _, fields, err = rlp.SplitUint64(fields); err != nil { // gasUsed
+				return nil, err
+			}
+		}
+		l1GasUsed, _, err := rlp.SplitUint64(fields)
+		if err != nil {
+			return nil, err
+		}
+		gasUsed = append(gasUsed, l1GasUsed)
+	}
+	return gasUsed, nil
+}
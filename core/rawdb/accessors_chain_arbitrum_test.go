@@ -0,0 +1,199 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestBadBlockDetailStorage(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	newBadBlock := func(number int64) *types.Block {
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number)})
+		WriteBadBlock(db, block)
+		return block
+	}
+	hash := common.Hash{0x01}
+	if detail := ReadBadBlockDetail(db, hash); detail != nil {
+		t.Fatalf("Non existent detail returned: %v", detail)
+	}
+	// Details of blocks missing from the bad block list are not stored
+	WriteBadBlockDetail(db, hash, nil, errors.New("invalid merkle root"))
+	if detail := ReadBadBlockDetail(db, hash); detail != nil {
+		t.Fatalf("Detail stored without bad block: %v", detail)
+	}
+	hash = newBadBlock(100).Hash()
+	receipts := types.Receipts{
+		{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 21000, GasUsedForL1: 100, Logs: []*types.Log{}},
+		{Status: types.ReceiptStatusFailed, CumulativeGasUsed: 42000, Logs: []*types.Log{}},
+	}
+	WriteBadBlockDetail(db, hash, receipts, errors.New("invalid merkle root"))
+
+	detail := ReadBadBlockDetail(db, hash)
+	if detail == nil {
+		t.Fatalf("Stored detail not found")
+	}
+	if detail.Error != "invalid merkle root" {
+		t.Fatalf("Error mismatch: have %q, want %q", detail.Error, "invalid merkle root")
+	}
+	if len(detail.Receipts) != len(receipts) {
+		t.Fatalf("Receipt count mismatch: have %d, want %d", len(detail.Receipts), len(receipts))
+	}
+	for i, receipt := range detail.Receipts {
+		if receipt.Status != receipts[i].Status || receipt.CumulativeGasUsed != receipts[i].CumulativeGasUsed || receipt.GasUsedForL1 != receipts[i].GasUsedForL1 {
+			t.Fatalf("Receipt %d mismatch: have %+v, want %+v", i, receipt, receipts[i])
+		}
+	}
+	// Writing the same block again should not replace the original context
+	WriteBadBlockDetail(db, hash, nil, errors.New("other error"))
+	if detail := ReadBadBlockDetail(db, hash); detail.Error != "invalid merkle root" {
+		t.Fatalf("Duplicate detail overwrote original: %q", detail.Error)
+	}
+	// Details are evicted along with their blocks, the lowest numbered first
+	var higher []common.Hash
+	for i := 0; i < badBlockToKeep; i++ {
+		higher = append(higher, newBadBlock(int64(200+i)).Hash())
+		WriteBadBlockDetail(db, higher[i], nil, nil)
+	}
+	if detail := ReadBadBlockDetail(db, hash); detail != nil {
+		t.Fatalf("Detail of evicted bad block not dropped")
+	}
+	lower := newBadBlock(50).Hash()
+	WriteBadBlockDetail(db, lower, nil, nil)
+	if detail := ReadBadBlockDetail(db, lower); detail != nil {
+		t.Fatalf("Detail stored for bad block below the retained ones")
+	}
+	for _, hash := range higher {
+		if detail := ReadBadBlockDetail(db, hash); detail == nil {
+			t.Fatalf("Retained detail dropped")
+		}
+	}
+	if details := readBadBlockDetails(db); len(details) != badBlockToKeep {
+		t.Fatalf("Retained detail count mismatch: have %d, want %d", len(details), badBlockToKeep)
+	}
+	DeleteBadBlocks(db)
+	if details := readBadBlockDetails(db); len(details) != 0 {
+		t.Fatalf("Details not deleted with bad blocks")
+	}
+}
+
+// Tests that the L2 gas used of a block can be stored, retrieved and deleted
+// along with the block.
+func TestL2GasUsedStorage(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	block := types.NewBlockWithHeader(&types.Header{Number: common.Big1, Extra: []byte("l2 gas")})
+	if _, ok := ReadL2GasUsed(db, block.Hash(), block.NumberU64()); ok {
+		t.Fatal("non-existent L2 gas used returned")
+	}
+	WriteBlock(db, block)
+	WriteL2GasUsed(db, block.Hash(), block.NumberU64(), 123456)
+	if gasUsed, ok := ReadL2GasUsed(db, block.Hash(), block.NumberU64()); !ok || gasUsed != 123456 {
+		t.Fatalf("L2 gas used mismatch: have %d (%v), want %d", gasUsed, ok, 123456)
+	}
+	if _, ok := ReadL2GasUsed(db, block.Hash(), block.NumberU64()+1); ok {
+		t.Fatal("L2 gas used returned for wrong block number")
+	}
+	DeleteBlock(db, block.Hash(), block.NumberU64())
+	if _, ok := ReadL2GasUsed(db, block.Hash(), block.NumberU64()); ok {
+		t.Fatal("deleted L2 gas used returned")
+	}
+}
+
+// Tests that the L1 gas used picked from the storage encoding of receipts
+// matches the fully decoded receipts, for all the receipt formats.
+func TestReadReceiptsGasUsedForL1(t *testing.T) {
+	db := NewMemoryDatabase()
+	hash := common.Hash{0x01}
+
+	if _, ok := ReadReceiptsGasUsedForL1(db, hash, 1); ok {
+		t.Fatal("non-existent receipts returned")
+	}
+	receipts := types.Receipts{
+		{
+			Type:              types.LegacyTxType,
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: 50000,
+			GasUsedForL1:      1000,
+			Logs:              []*types.Log{{Address: common.Address{0x11}, Topics: []common.Hash{{0x22}}, Data: []byte{0x33}}},
+		},
+		{
+			Type:              types.DynamicFeeTxType,
+			Status:            types.ReceiptStatusFailed,
+			CumulativeGasUsed: 80000,
+			GasUsedForL1:      0,
+			Logs:              []*types.Log{},
+		},
+		{
+			Type:              types.LegacyTxType,
+			PostState:         common.Hash{0x44}.Bytes(),
+			CumulativeGasUsed: 90000,
+			GasUsedForL1:      2000,
+			Logs:              []*types.Log{},
+		},
+		{
+			Type:              types.ArbitrumLegacyTxType,
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: 120000,
+			GasUsed:           30000,
+			GasUsedForL1:      3000,
+			ContractAddress:   common.Address{0x55},
+			Logs:              []*types.Log{{Address: common.Address{0x66}}},
+		},
+		{
+			Type:              types.ArbitrumDepositTxType,
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: 120000,
+			GasUsedForL1:      4000,
+			ContractAddress:   common.Address{0x77},
+			Logs:              []*types.Log{},
+		},
+	}
+	WriteReceipts(db, hash, 1, receipts)
+
+	data := ReadReceiptsRLP(db, hash, 1)
+	fast, err := splitReceiptsGasUsedForL1(data)
+	if err != nil {
+		t.Fatalf("failed to split receipts: %v", err)
+	}
+	gasUsed, ok := ReadReceiptsGasUsedForL1(db, hash, 1)
+	if !ok {
+		t.Fatal("receipts not found")
+	}
+	decoded := ReadRawReceipts(db, hash, 1)
+	if len(fast) != len(decoded) || len(gasUsed) != len(decoded) {
+		t.Fatalf("receipt count mismatch: have %d/%d, want %d", len(fast), len(gasUsed), len(decoded))
+	}
+	for i, receipt := range decoded {
+		if fast[i] != receipt.GasUsedForL1 || gasUsed[i] != receipt.GasUsedForL1 || receipt.GasUsedForL1 != receipts[i].GasUsedForL1 {
+			t.Errorf("receipt %d: L1 gas used mismatch: have %d/%d, decoded %d, want %d", i, fast[i], gasUsed[i], receipt.GasUsedForL1, receipts[i].GasUsedForL1)
+		}
+	}
+	// Receipts that can't be decoded at all are reported missing
+	if err := db.Put(blockReceiptsKey(2, hash), []byte{0xc2, 0xc1, 0x80}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ReadReceiptsGasUsedForL1(db, hash, 2); ok {
+		t.Fatal("invalid receipts returned")
+	}
+}
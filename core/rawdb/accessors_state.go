@@ -160,6 +160,20 @@ func DeleteTrieJournal(db ethdb.KeyValueWriter) {
 	}
 }
 
+// ReadStateHistorySize returns the total size of the state histories kept in
+// the freezer, over all of its tables.
+func ReadStateHistorySize(db ethdb.AncientReaderOp) (uint64, error) {
+	var total uint64
+	for kind := range stateFreezerNoSnappy {
+		size, err := db.AncientSize(kind)
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return total, nil
+}
+
 // ReadStateHistoryMeta retrieves the metadata corresponding to the specified
 // state history. Compute the position of state history in freezer by minus
 // one since the id of first state history starts from one(zero for initial
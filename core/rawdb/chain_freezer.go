@@ -62,9 +62,11 @@ func newChainFreezer(datadir string, namespace string, readonly bool) (*chainFre
 		freezer ethdb.AncientStore
 	)
 	if datadir == "" {
-		freezer = NewMemoryFreezer(readonly, chainFreezerNoSnappy)
+		memory := NewMemoryFreezer(readonly, chainFreezerNoSnappy)
+		memory.prunable = chainFreezerPrunable
+		freezer = memory
 	} else {
-		freezer, err = NewFreezer(datadir, namespace, readonly, freezerTableSize, chainFreezerNoSnappy)
+		freezer, err = newFreezer(datadir, namespace, readonly, freezerTableSize, chainFreezerNoSnappy, chainFreezerPrunable)
 	}
 	if err != nil {
 		return nil, err
@@ -71,6 +71,7 @@ type Freezer struct {
 
 	readonly     bool
 	tables       map[string]*freezerTable // Data tables for storing everything
+	prunable     map[string]bool          // Tables whose tail may run ahead of the freezer tail
 	instanceLock FileLock                 // File-system lock to prevent double opens
 	closeOnce    sync.Once
 }
@@ -81,6 +82,12 @@ type Freezer struct {
 // The 'tables' argument defines the data tables. If the value of a map
 // entry is true, snappy compression is disabled for the table.
 func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool) (*Freezer, error) {
+	return newFreezer(datadir, namespace, readonly, maxTableSize, tables, nil)
+}
+
+// newFreezer creates a freezer instance, the tables listed in 'prunable' may
+// have their tail truncated independently of the other ones.
+func newFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool, prunable map[string]bool) (*Freezer, error) {
 	// Create the initial freezer object
 	var (
 		readMeter  = metrics.NewRegisteredMeter(namespace+"ancient/read", nil)
@@ -114,6 +121,7 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 	freezer := &Freezer{
 		readonly:     readonly,
 		tables:       make(map[string]*freezerTable),
+		prunable:     prunable,
 		instanceLock: lock,
 	}
 
@@ -284,8 +292,13 @@ func (f *Freezer) TruncateHead(items uint64) (uint64, error) {
 	if oitems <= items {
 		return oitems, nil
 	}
-	for _, table := range f.tables {
-		if err := table.truncateHead(items); err != nil {
+	// Only the tables which can't be pruned on their own have to keep their
+	// tail, check them all before truncating any.
+	if tail := f.tail.Load(); items < tail {
+		return 0, fmt.Errorf("truncation below tail: %d < %d", items, tail)
+	}
+	for kind := range f.tables {
+		if err := f.truncateTableHead(kind, items); err != nil {
 			return 0, err
 		}
 	}
@@ -328,7 +341,8 @@ func (f *Freezer) Sync() error {
 	return nil
 }
 
-// validate checks that every table has the same boundary.
+// validate checks that every table has the same boundary. Prunable tables may
+// have their tail ahead of the others.
 // Used instead of `repair` in readonly mode.
 func (f *Freezer) validate() error {
 	if len(f.tables) == 0 {
@@ -342,17 +356,22 @@ func (f *Freezer) validate() error {
 	// Hack to get boundary of any table
 	for kind, table := range f.tables {
 		head = table.items.Load()
-		tail = table.itemHidden.Load()
 		name = kind
 		break
 	}
+	// Take the tail of the tables which can't be pruned on their own
+	for kind, table := range f.tables {
+		if hidden := table.itemHidden.Load(); !f.prunable[kind] && hidden > tail {
+			tail = hidden
+		}
+	}
 	// Now check every table against those boundaries.
 	for kind, table := range f.tables {
 		if head != table.items.Load() {
 			return fmt.Errorf("freezer tables %s and %s have differing head: %d != %d", kind, name, table.items.Load(), head)
 		}
-		if tail != table.itemHidden.Load() {
-			return fmt.Errorf("freezer tables %s and %s have differing tail: %d != %d", kind, name, table.itemHidden.Load(), tail)
+		if hidden := table.itemHidden.Load(); hidden != tail && (!f.prunable[kind] || hidden < tail) {
+			return fmt.Errorf("freezer table %s has differing tail: %d != %d", kind, hidden, tail)
 		}
 	}
 	f.frozen.Store(head)
@@ -366,18 +385,18 @@ func (f *Freezer) repair() error {
 		head = uint64(math.MaxUint64)
 		tail = uint64(0)
 	)
-	for _, table := range f.tables {
+	for kind, table := range f.tables {
 		items := table.items.Load()
 		if head > items {
 			head = items
 		}
 		hidden := table.itemHidden.Load()
-		if hidden > tail {
+		if hidden > tail && !f.prunable[kind] {
 			tail = hidden
 		}
 	}
-	for _, table := range f.tables {
-		if err := table.truncateHead(head); err != nil {
+	for kind, table := range f.tables {
+		if err := f.truncateTableHead(kind, head); err != nil {
 			return err
 		}
 		if err := table.truncateTail(tail); err != nil {
@@ -0,0 +1,234 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// chainFreezerPrunable lists the ancient tables which may be pruned on their
+// own, keeping the headers and bodies of the pruned range.
+var chainFreezerPrunable = map[string]bool{
+	ChainFreezerReceiptTable: true,
+}
+
+var errTableNotPrunable = errors.New("table can't be pruned on its own")
+
+// resetTo discards all the items of the table and moves both its head and tail
+// to the given number, which may be below the current tail. It's used to
+// truncate the head of a prunable table below its tail.
+func (t *freezerTable) resetTo(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	oldSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	// Lower the virtual tail first, the repair on startup raises it back to the
+	// actual tail if the index isn't rewritten.
+	if err := writeMetadata(t.meta, newMetadata(items)); err != nil {
+		return err
+	}
+	if err := t.meta.Sync(); err != nil {
+		return err
+	}
+	// Replace the index with a lone tail entry pointing at the head file, whose
+	// content is dropped on repair if the data files aren't truncated below.
+	tailIndex := indexEntry{filenum: t.headId, offset: uint32(items)}
+	if err := truncateFreezerFile(t.index, 0); err != nil {
+		return err
+	}
+	if _, err := t.index.Write(tailIndex.append(nil)); err != nil {
+		return err
+	}
+	if err := t.index.Sync(); err != nil {
+		return err
+	}
+	t.releaseFilesBefore(t.headId, true)
+	if err := truncateFreezerFile(t.head, 0); err != nil {
+		return err
+	}
+	if err := t.head.Sync(); err != nil {
+		return err
+	}
+	t.tailId = t.headId
+	t.headBytes = 0
+	t.itemOffset.Store(items)
+	t.itemHidden.Store(items)
+	t.items.Store(items)
+
+	newSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	t.sizeGauge.Dec(int64(oldSize - newSize))
+	return nil
+}
+
+// truncateTableHead discards the items of the table above the provided threshold
+// number. Prunable tables whose tail is above the threshold are emptied, with
+// their tail moved down to it.
+func (f *Freezer) truncateTableHead(kind string, items uint64) error {
+	table := f.tables[kind]
+	if f.prunable[kind] && items < table.itemHidden.Load() {
+		return table.resetTo(items)
+	}
+	return table.truncateHead(items)
+}
+
+// resetTo discards all the items of the table and moves both its head and tail
+// to the given number, which may be below the current tail.
+func (t *memoryTable) resetTo(items uint64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.data = nil
+	t.size = 0
+	t.items = items
+	t.offset = items
+}
+
+// truncateTableHead discards the items of the table above the provided threshold
+// number. Prunable tables whose tail is above the threshold are emptied, with
+// their tail moved down to it. The caller must hold the lock.
+func (f *MemoryFreezer) truncateTableHead(kind string, items uint64) error {
+	table := f.tables[kind]
+	if f.prunable[kind] && items < table.offset {
+		table.resetTo(items)
+		return nil
+	}
+	return table.truncateHead(items)
+}
+
+// AncientTableTail returns the number of the first item stored in the table.
+func (f *Freezer) AncientTableTail(kind string) (uint64, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.itemHidden.Load(), nil
+	}
+	return 0, errUnknownTable
+}
+
+// TruncateTableTail discards the items of a prunable table below the provided
+// threshold number, leaving the other tables intact.
+func (f *Freezer) TruncateTableTail(kind string, tail uint64) (uint64, error) {
+	if f.readonly {
+		return 0, errReadOnly
+	}
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	table := f.tables[kind]
+	if table == nil {
+		return 0, errUnknownTable
+	}
+	if !f.prunable[kind] {
+		return 0, errTableNotPrunable
+	}
+	old := table.itemHidden.Load()
+	if old >= tail {
+		return old, nil
+	}
+	if err := table.truncateTail(tail); err != nil {
+		return 0, err
+	}
+	return old, nil
+}
+
+// AncientTableTail returns the number of the first item stored in the table.
+func (f *MemoryFreezer) AncientTableTail(kind string) (uint64, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	if table := f.tables[kind]; table != nil {
+		table.lock.RLock()
+		defer table.lock.RUnlock()
+		return table.offset, nil
+	}
+	return 0, errUnknownTable
+}
+
+// TruncateTableTail discards the items of a prunable table below the provided
+// threshold number, leaving the other tables intact.
+func (f *MemoryFreezer) TruncateTableTail(kind string, tail uint64) (uint64, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.readonly {
+		return 0, errReadOnly
+	}
+	table := f.tables[kind]
+	if table == nil {
+		return 0, errUnknownTable
+	}
+	if !f.prunable[kind] {
+		return 0, errTableNotPrunable
+	}
+	old := table.offset
+	if old >= tail {
+		return old, nil
+	}
+	if err := table.truncateTail(tail); err != nil {
+		return 0, err
+	}
+	return old, nil
+}
+
+// AncientTableTail returns the number of the first item stored in the table,
+// if the underlying ancient store supports pruning tables on their own.
+func (f *chainFreezer) AncientTableTail(kind string) (uint64, error) {
+	pruner, ok := f.AncientStore.(ethdb.AncientTablePruner)
+	if !ok {
+		return 0, errNotSupported
+	}
+	return pruner.AncientTableTail(kind)
+}
+
+// TruncateTableTail discards the items of a prunable table below the provided
+// threshold number, if the underlying ancient store supports it.
+func (f *chainFreezer) TruncateTableTail(kind string, tail uint64) (uint64, error) {
+	pruner, ok := f.AncientStore.(ethdb.AncientTablePruner)
+	if !ok {
+		return 0, errNotSupported
+	}
+	return pruner.TruncateTableTail(kind, tail)
+}
+
+// ReadAncientReceiptsTail returns the number of the first block whose receipts
+// are kept in the ancient store, which is above the ancient tail if the receipt
+// history was pruned on its own.
+func ReadAncientReceiptsTail(db ethdb.AncientReader) (uint64, error) {
+	if pruner, ok := db.(ethdb.AncientTablePruner); ok {
+		if tail, err := pruner.AncientTableTail(ChainFreezerReceiptTable); !errors.Is(err, errNotSupported) {
+			return tail, err
+		}
+	}
+	return db.Tail()
+}
+
+// TruncateAncientReceiptsTail drops the receipts of the ancient blocks below the
+// given number, keeping their headers and bodies. It returns the previous tail
+// of the receipts.
+func TruncateAncientReceiptsTail(db ethdb.AncientWriter, tail uint64) (uint64, error) {
+	pruner, ok := db.(ethdb.AncientTablePruner)
+	if !ok {
+		return 0, errNotSupported
+	}
+	return pruner.TruncateTableTail(ChainFreezerReceiptTable, tail)
+}
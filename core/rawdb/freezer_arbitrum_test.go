@@ -0,0 +1,234 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that pruning a table on its own leaves the other tables intact, also
+// across a restart of the freezer.
+func TestFreezerTruncateTableTail(t *testing.T) {
+	var (
+		tables   = map[string]bool{"kept": true, "pruned": true}
+		prunable = map[string]bool{"pruned": true}
+		dir      = t.TempDir()
+	)
+	f, err := newFreezer(dir, "", false, 2049, tables, prunable)
+	if err != nil {
+		t.Fatal("can't open freezer", err)
+	}
+	_, err = f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i := uint64(0); i < 10; i++ {
+			if err := op.AppendRaw("kept", i, []byte{byte(i)}); err != nil {
+				return err
+			}
+			if err := op.AppendRaw("pruned", i, []byte{byte(i)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal("failed to write items", err)
+	}
+	if _, err := f.TruncateTableTail("kept", 6); !errors.Is(err, errTableNotPrunable) {
+		t.Fatalf("unexpected error truncating non-prunable table: %v", err)
+	}
+	if _, err := f.TruncateTableTail("pruned", 6); err != nil {
+		t.Fatal("failed to truncate table", err)
+	}
+	check := func(f *Freezer) {
+		t.Helper()
+		if tail, _ := f.Tail(); tail != 0 {
+			t.Errorf("freezer tail mismatch: have %d, want 0", tail)
+		}
+		if tail, _ := f.AncientTableTail("pruned"); tail != 6 {
+			t.Errorf("table tail mismatch: have %d, want 6", tail)
+		}
+		if frozen, _ := f.Ancients(); frozen != 10 {
+			t.Errorf("frozen items mismatch: have %d, want 10", frozen)
+		}
+		if ok, _ := f.HasAncient("kept", 0); !ok {
+			t.Error("item 0 of the kept table is missing")
+		}
+		if ok, _ := f.HasAncient("pruned", 5); ok {
+			t.Error("item 5 of the pruned table is still present")
+		}
+		if ok, _ := f.HasAncient("pruned", 6); !ok {
+			t.Error("item 6 of the pruned table is missing")
+		}
+	}
+	check(f)
+	f.Close()
+
+	// Reopen the freezer, which repairs the tables, and then in readonly mode,
+	// which validates them.
+	for _, readonly := range []bool{false, true} {
+		f, err = newFreezer(dir, "", readonly, 2049, tables, prunable)
+		if err != nil {
+			t.Fatalf("can't reopen freezer (readonly %v): %v", readonly, err)
+		}
+		check(f)
+		f.Close()
+	}
+}
+
+// Tests that the head of the freezer can be truncated below the tail of a pruned
+// table, which is emptied and accepts new items from there on.
+func TestFreezerTruncateHeadBelowTableTail(t *testing.T) {
+	var (
+		tables   = map[string]bool{"kept": true, "pruned": true}
+		prunable = map[string]bool{"pruned": true}
+		dir      = t.TempDir()
+	)
+	// Use tiny data files, so that pruning drops whole files
+	f, err := newFreezer(dir, "", false, 4, tables, prunable)
+	if err != nil {
+		t.Fatal("can't open freezer", err)
+	}
+	write := func(f *Freezer, from, to uint64) {
+		t.Helper()
+		_, err := f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+			for i := from; i < to; i++ {
+				if err := op.AppendRaw("kept", i, []byte{byte(i)}); err != nil {
+					return err
+				}
+				if err := op.AppendRaw("pruned", i, []byte{byte(i)}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal("failed to write items", err)
+		}
+	}
+	write(f, 0, 10)
+	if _, err := f.TruncateTableTail("pruned", 6); err != nil {
+		t.Fatal("failed to truncate table", err)
+	}
+	if _, err := f.TruncateHead(3); err != nil {
+		t.Fatal("failed to truncate head", err)
+	}
+	check := func(f *Freezer, head uint64) {
+		t.Helper()
+		if frozen, _ := f.Ancients(); frozen != head {
+			t.Errorf("frozen items mismatch: have %d, want %d", frozen, head)
+		}
+		if tail, _ := f.AncientTableTail("pruned"); tail != 3 {
+			t.Errorf("table tail mismatch: have %d, want 3", tail)
+		}
+		for i := uint64(0); i < head; i++ {
+			if blob, err := f.Ancient("kept", i); err != nil || blob[0] != byte(i) {
+				t.Errorf("item %d of the kept table mismatch: %x %v", i, blob, err)
+			}
+			if blob, err := f.Ancient("pruned", i); i < 3 && err == nil {
+				t.Errorf("item %d of the pruned table is present", i)
+			} else if i >= 3 && (err != nil || blob[0] != byte(i)) {
+				t.Errorf("item %d of the pruned table mismatch: %x %v", i, blob, err)
+			}
+		}
+	}
+	check(f, 3)
+	write(f, 3, 8)
+	check(f, 8)
+	f.Close()
+
+	for _, readonly := range []bool{false, true} {
+		f, err = newFreezer(dir, "", readonly, 4, tables, prunable)
+		if err != nil {
+			t.Fatalf("can't reopen freezer (readonly %v): %v", readonly, err)
+		}
+		check(f, 8)
+		f.Close()
+	}
+}
+
+// Tests that truncating the head below the tail of the tables which can't be
+// pruned on their own fails without modifying any table.
+func TestFreezerTruncateHeadBelowTail(t *testing.T) {
+	var (
+		tables   = map[string]bool{"kept": true, "pruned": true}
+		prunable = map[string]bool{"pruned": true}
+	)
+	for _, f := range []interface {
+		ethdb.AncientStore
+		ethdb.AncientTablePruner
+	}{
+		mustNewFreezer(t, tables, prunable),
+		mustNewMemoryFreezer(tables, prunable),
+	} {
+		_, err := f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+			for i := uint64(0); i < 10; i++ {
+				if err := op.AppendRaw("kept", i, []byte{byte(i)}); err != nil {
+					return err
+				}
+				if err := op.AppendRaw("pruned", i, []byte{byte(i)}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal("failed to write items", err)
+		}
+		if _, err := f.TruncateTableTail("pruned", 6); err != nil {
+			t.Fatal("failed to truncate table", err)
+		}
+		if _, err := f.TruncateTail(4); err != nil {
+			t.Fatal("failed to truncate tail", err)
+		}
+		if _, err := f.TruncateHead(2); err == nil {
+			t.Fatal("truncated head below tail")
+		}
+		if frozen, _ := f.Ancients(); frozen != 10 {
+			t.Fatalf("frozen items mismatch: have %d, want 10", frozen)
+		}
+		if ok, _ := f.HasAncient("pruned", 9); !ok {
+			t.Fatal("item 9 of the pruned table is missing")
+		}
+		// Truncating above the freezer tail empties the pruned table
+		if _, err := f.TruncateHead(5); err != nil {
+			t.Fatal("failed to truncate head", err)
+		}
+		if tail, _ := f.AncientTableTail("pruned"); tail != 5 {
+			t.Fatalf("table tail mismatch: have %d, want 5", tail)
+		}
+		if ok, _ := f.HasAncient("kept", 4); !ok {
+			t.Fatal("item 4 of the kept table is missing")
+		}
+		f.Close()
+	}
+}
+
+func mustNewFreezer(t *testing.T, tables, prunable map[string]bool) *Freezer {
+	f, err := newFreezer(t.TempDir(), "", false, 4, tables, prunable)
+	if err != nil {
+		t.Fatal("can't open freezer", err)
+	}
+	return f
+}
+
+func mustNewMemoryFreezer(tables, prunable map[string]bool) *MemoryFreezer {
+	f := NewMemoryFreezer(false, tables)
+	f.prunable = prunable
+	return f
+}
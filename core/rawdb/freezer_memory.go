@@ -214,6 +214,7 @@ type MemoryFreezer struct {
 	readonly   bool                    // Flag if the freezer is only for reading
 	lock       sync.RWMutex            // Lock to protect fields
 	tables     map[string]*memoryTable // Tables for storing everything
+	prunable   map[string]bool         // Tables whose tail may run ahead of the freezer tail
 	writeBatch *memoryBatch            // Pre-allocated write batch
 }
 
@@ -359,8 +360,13 @@ func (f *MemoryFreezer) TruncateHead(items uint64) (uint64, error) {
 	if old <= items {
 		return old, nil
 	}
-	for _, table := range f.tables {
-		if err := table.truncateHead(items); err != nil {
+	// Only the tables which can't be pruned on their own have to keep their
+	// tail, check them all before truncating any.
+	if items < f.tail {
+		return 0, fmt.Errorf("truncation below tail: %d < %d", items, f.tail)
+	}
+	for kind := range f.tables {
+		if err := f.truncateTableHead(kind, items); err != nil {
 			return 0, err
 		}
 	}
@@ -35,6 +35,16 @@ type WasmKey = [WasmKeyLen]byte
 var (
 	wasmSchemaVersionKey = []byte("WasmSchemaVersion")
 
+	// lastStopCommitKey tracks the most recent block whose state was persisted
+	// by the last clean shutdown.
+	lastStopCommitKey = []byte("LastStopCommit")
+
+	// badBlockDetailKey tracks the context recorded alongside the bad blocks
+	// stored under badBlockKey.
+	badBlockDetailKey = []byte("InvalidBlockDetail")
+
+	l2GasUsedPrefix = []byte("gasL2-") // l2GasUsedPrefix + num (uint64 big endian) + hash -> L2 gas used by the block
+
 	// 0x00 prefix to avoid conflicts when wasmdb is not separate database
 	activatedAsmWavmPrefix = WasmPrefix{0x00, 'w', 'w'} // (prefix, moduleHash) -> stylus module (wavm)
 	activatedAsmArmPrefix  = WasmPrefix{0x00, 'w', 'r'} // (prefix, moduleHash) -> stylus asm for ARM system
@@ -57,3 +67,8 @@ func activatedKey(prefix WasmPrefix, moduleHash common.Hash) WasmKey {
 	copy(key[WasmPrefixLen:], moduleHash[:])
 	return key
 }
+
+// l2GasUsedKey = l2GasUsedPrefix + num (uint64 big endian) + hash
+func l2GasUsedKey(number uint64, hash common.Hash) []byte {
+	return append(append(l2GasUsedPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
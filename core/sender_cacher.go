@@ -20,6 +20,7 @@ import (
 	"runtime"
 
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 // SenderCacher is a concurrent transaction sender recoverer and cacher.
@@ -103,3 +104,19 @@ func (cacher *txSenderCacher) RecoverFromBlocks(signer types.Signer, blocks []*t
 	}
 	cacher.Recover(signer, txs)
 }
+
+// RecoverFromChain recovers the senders from a batch of consecutive blocks,
+// using the signer of each block's own rules. Runs of blocks sharing a signer
+// are recovered together, so a batch straddling a signer-affecting upgrade is
+// still cached on both sides of the transition.
+func (cacher *txSenderCacher) RecoverFromChain(config *params.ChainConfig, blocks []*types.Block) {
+	for start := 0; start < len(blocks); {
+		signer := types.MakeSigner(config, blocks[start].Number(), blocks[start].Time())
+		end := start + 1
+		for end < len(blocks) && signer.Equal(types.MakeSigner(config, blocks[end].Number(), blocks[end].Time())) {
+			end++
+		}
+		cacher.RecoverFromBlocks(signer, blocks[start:end])
+		start = end
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var errSenderNotCached = errors.New("sender not cached")
+
+// cacheOnlySigner wraps an Arbitrum signer and refuses to recover senders itself,
+// so only cached senders can be retrieved. Wrapped into an Arbitrum signer, it is
+// considered equal to the signer it wraps.
+type cacheOnlySigner struct{ types.Signer }
+
+func (s cacheOnlySigner) Sender(tx *types.Transaction) (common.Address, error) {
+	return common.Address{}, errSenderNotCached
+}
+
+func (s cacheOnlySigner) Equal(s2 types.Signer) bool {
+	// The Arbitrum signer compares the signers it wraps, so rewrap the other one
+	return s.Signer.Equal(types.NewArbitrumSigner(s2))
+}
+
+// Tests that recovering the senders of a batch straddling a signer change uses
+// the right signer for each block, caching the senders of all of them.
+func TestRecoverFromChainAcrossFork(t *testing.T) {
+	// Blocks before the Nitro genesis use the pre-London signer
+	config := *params.TestChainConfig
+	config.ArbitrumChainParams.EnableArbOS = true
+	config.ArbitrumChainParams.GenesisBlockNum = 2
+
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	var (
+		classic = types.MakeSigner(&config, big.NewInt(1), 0)
+		nitro   = types.MakeSigner(&config, big.NewInt(2), 0)
+	)
+	if classic.Equal(nitro) {
+		t.Fatalf("signers are expected to differ across the fork")
+	}
+	blocks := []*types.Block{
+		types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)}).WithBody(types.Body{
+			Transactions: []*types.Transaction{types.MustSignNewTx(key, classic, &types.AccessListTx{
+				ChainID:  config.ChainID,
+				Nonce:    0,
+				Gas:      params.TxGas,
+				GasPrice: big.NewInt(params.InitialBaseFee),
+			})},
+		}),
+		types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2)}).WithBody(types.Body{
+			Transactions: []*types.Transaction{types.MustSignNewTx(key, nitro, &types.DynamicFeeTx{
+				ChainID:   config.ChainID,
+				Nonce:     1,
+				Gas:       params.TxGas,
+				GasFeeCap: big.NewInt(params.InitialBaseFee),
+				GasTipCap: big.NewInt(1),
+			})},
+		}),
+	}
+	SenderCacher.RecoverFromChain(&config, blocks)
+
+	for _, block := range blocks {
+		var (
+			tx     = block.Transactions()[0]
+			signer = types.NewArbitrumSigner(cacheOnlySigner{types.MakeSigner(&config, block.Number(), block.Time())})
+			from   common.Address
+			err    error
+		)
+		// Recovery happens in the background, give it some time to finish
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if from, err = types.Sender(signer, tx); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			t.Fatalf("block %d: sender not cached: %v", block.NumberU64(), err)
+		}
+		if from != addr {
+			t.Fatalf("block %d: sender mismatch: have %x, want %x", block.NumberU64(), from, addr)
+		}
+	}
+}
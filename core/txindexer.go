@@ -19,12 +19,18 @@ package core
 import (
 	"errors"
 	"fmt"
+	"math/rand"
+	"slices"
 
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 )
 
+// txIndexCheckSamples is the number of indexed blocks whose lookup entries are
+// verified against the canonical chain when the indexer starts up.
+const txIndexCheckSamples = 16
+
 // TxIndexProgress is the struct describing the progress for transaction indexing.
 type TxIndexProgress struct {
 	Indexed   uint64 // number of blocks whose transactions are indexed
@@ -44,7 +50,13 @@ type txIndexer struct {
 	//  * 0: means the entire chain should be indexed
 	//  * N: means the latest N blocks [HEAD-N+1, HEAD] should be indexed
 	//       and all others shouldn't.
-	limit    uint64
+	limit uint64
+
+	// genesis is the number of the oldest block in the chain. It is zero
+	// except for Arbitrum chains migrated from a classic chain, where the
+	// blocks preceding the Nitro genesis are not present.
+	genesis uint64
+
 	db       ethdb.Database
 	progress chan chan TxIndexProgress
 	term     chan chan struct{}
@@ -55,6 +67,7 @@ type txIndexer struct {
 func newTxIndexer(limit uint64, chain *BlockChain) *txIndexer {
 	indexer := &txIndexer{
 		limit:    limit,
+		genesis:  chain.genesisBlock.NumberU64(),
 		db:       chain.db,
 		progress: make(chan chan TxIndexProgress),
 		term:     make(chan chan struct{}),
@@ -80,15 +93,15 @@ func (indexer *txIndexer) run(tail *uint64, head uint64, stop chan struct{}, don
 	defer func() { close(done) }()
 
 	// Short circuit if chain is empty and nothing to index.
-	if head == 0 {
+	if head <= indexer.genesis {
 		return
 	}
 	// The tail flag is not existent, it means the node is just initialized
 	// and all blocks in the chain (part of them may from ancient store) are
 	// not indexed yet, index the chain according to the configured limit.
 	if tail == nil {
-		from := uint64(0)
-		if indexer.limit != 0 && head >= indexer.limit {
+		from := indexer.genesis
+		if indexer.limit != 0 && head-indexer.genesis >= indexer.limit {
 			from = head - indexer.limit + 1
 		}
 		rawdb.IndexTransactions(indexer.db, from, head+1, stop, true)
@@ -96,8 +109,8 @@ func (indexer *txIndexer) run(tail *uint64, head uint64, stop chan struct{}, don
 	}
 	// The tail flag is existent (which means indexes in [tail, head] should be
 	// present), while the whole chain are requested for indexing.
-	if indexer.limit == 0 || head < indexer.limit {
-		if *tail > 0 {
+	if indexer.limit == 0 || head-indexer.genesis < indexer.limit {
+		if *tail > indexer.genesis {
 			// It can happen when chain is rewound to a historical point which
 			// is even lower than the indexes tail, recap the indexing target
 			// to new head to avoid reading non-existent block bodies.
@@ -105,7 +118,7 @@ func (indexer *txIndexer) run(tail *uint64, head uint64, stop chan struct{}, don
 			if end > head+1 {
 				end = head + 1
 			}
-			rawdb.IndexTransactions(indexer.db, 0, end, stop, true)
+			rawdb.IndexTransactions(indexer.db, indexer.genesis, end, stop, true)
 		}
 		return
 	}
@@ -143,7 +156,12 @@ func (indexer *txIndexer) loop(chain *BlockChain) {
 		stop = make(chan struct{})
 		done = make(chan struct{})
 		lastHead = head.Number().Uint64()
-		go indexer.run(rawdb.ReadTxIndexTail(indexer.db), head.NumberU64(), stop, done)
+		go func(stop, done chan struct{}) {
+			if tail := rawdb.ReadTxIndexTail(indexer.db); tail != nil {
+				indexer.repair(*tail, head.NumberU64(), stop)
+			}
+			indexer.run(rawdb.ReadTxIndexTail(indexer.db), head.NumberU64(), stop, done)
+		}(stop, done)
 	}
 	for {
 		select {
@@ -177,8 +195,11 @@ func (indexer *txIndexer) loop(chain *BlockChain) {
 // report returns the tx indexing progress.
 func (indexer *txIndexer) report(head uint64, tail *uint64) TxIndexProgress {
 	total := indexer.limit
-	if indexer.limit == 0 || total > head {
-		total = head + 1 // genesis included
+	if head < indexer.genesis {
+		head = indexer.genesis
+	}
+	if indexer.limit == 0 || total > head-indexer.genesis {
+		total = head - indexer.genesis + 1 // genesis included
 	}
 	var indexed uint64
 	if tail != nil {
@@ -196,6 +217,92 @@ func (indexer *txIndexer) report(head uint64, tail *uint64) TxIndexProgress {
 	}
 }
 
+// repair verifies the lookup entries of a random sample of blocks in the indexed
+// range [tail, head] and reindexes the ranges around the samples whose entries
+// are missing or point elsewhere, bounded by the nearest intact samples.
+func (indexer *txIndexer) repair(tail, head uint64, stop chan struct{}) {
+	if tail < indexer.genesis {
+		tail = indexer.genesis
+	}
+	if head < tail {
+		return
+	}
+	samples := make([]uint64, 0, txIndexCheckSamples)
+	if span := head - tail + 1; span <= txIndexCheckSamples {
+		for n := tail; n <= head; n++ {
+			samples = append(samples, n)
+		}
+	} else {
+		for i := 0; i < txIndexCheckSamples; i++ {
+			samples = append(samples, tail+uint64(rand.Int63n(int64(span))))
+		}
+		slices.Sort(samples)
+		samples = slices.Compact(samples)
+	}
+	from := tail // Lower bound of the range containing the current corrupted run
+	corrupted := false
+	for i, n := range samples {
+		if indexer.verify(n) {
+			if corrupted {
+				indexer.reindex(from, n, stop)
+				corrupted = false
+			}
+			from = n + 1
+			continue
+		}
+		if !corrupted {
+			log.Warn("Corrupted transaction index detected", "number", n)
+			corrupted = true
+		}
+		if i == len(samples)-1 {
+			indexer.reindex(from, head+1, stop)
+		}
+	}
+}
+
+// verify reports whether the lookup entries of the transactions in the given
+// canonical block resolve back to that block.
+func (indexer *txIndexer) verify(number uint64) bool {
+	hash := rawdb.ReadCanonicalHash(indexer.db, number)
+	body := rawdb.ReadBody(indexer.db, hash, number)
+	if body == nil {
+		return true // Nothing to check against
+	}
+	for _, tx := range body.Transactions {
+		if entry := rawdb.ReadTxLookupEntry(indexer.db, tx.Hash()); entry == nil || *entry != number {
+			return false
+		}
+	}
+	return true
+}
+
+// reindex rewrites the lookup entries of the canonical blocks in [from, to)
+// without touching the index tail.
+func (indexer *txIndexer) reindex(from, to uint64, stop chan struct{}) {
+	log.Info("Reindexing transactions", "from", from, "to", to)
+	batch := indexer.db.NewBatch()
+	for n := from; n < to; n++ {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		hash := rawdb.ReadCanonicalHash(indexer.db, n)
+		if block := rawdb.ReadBlock(indexer.db, hash, n); block != nil {
+			rawdb.WriteTxLookupEntriesByBlock(batch, block)
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed writing batch to db", "error", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed writing batch to db", "error", err)
+	}
+}
+
 // txIndexProgress retrieves the tx indexing progress, or an error if the
 // background tx indexer is already stopped.
 func (indexer *txIndexer) txIndexProgress() (TxIndexProgress, error) {
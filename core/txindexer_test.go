@@ -238,3 +238,133 @@ func TestTxIndexer(t *testing.T) {
 		db.Close()
 	}
 }
+
+// newTestArbitrumIndexerChain generates a chain and stores the blocks from the
+// given genesis number on into a fresh database, mimicking an Arbitrum chain
+// whose blocks preceding the Nitro genesis are not present.
+func newTestArbitrumIndexerChain(genesis uint64, n int) (ethdb.Database, []*types.Block) {
+	var (
+		testBankKey, _  = crypto.GenerateKey()
+		testBankAddress = crypto.PubkeyToAddress(testBankKey.PublicKey)
+		testBankFunds   = big.NewInt(1000000000000000000)
+
+		gspec = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{testBankAddress: {Balance: testBankFunds}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		nonce = uint64(0)
+	)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), int(genesis)+n, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, common.HexToAddress("0xdeadbeef"), big.NewInt(1000), params.TxGas, big.NewInt(10*params.InitialBaseFee), nil), types.HomesteadSigner{}, testBankKey)
+		gen.AddTx(tx)
+		nonce += 1
+	})
+	blocks = blocks[genesis-1:]
+
+	db := rawdb.NewMemoryDatabase()
+	for _, block := range blocks {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+	}
+	return db, blocks
+}
+
+// checkTxLookups verifies that the transactions of the given blocks are indexed.
+func checkTxLookups(t *testing.T, db ethdb.Database, blocks []*types.Block) {
+	t.Helper()
+	for _, block := range blocks {
+		for _, tx := range block.Transactions() {
+			if lookup := rawdb.ReadTxLookupEntry(db, tx.Hash()); lookup == nil || *lookup != block.NumberU64() {
+				t.Fatalf("missing lookup of tx %x in block %d", tx.Hash(), block.NumberU64())
+			}
+		}
+	}
+}
+
+// TestTxIndexerArbitrumGenesis tests that the indexer doesn't try to index the
+// blocks preceding a non-zero Arbitrum genesis and reports completion once the
+// chain down to that genesis is indexed.
+func TestTxIndexerArbitrumGenesis(t *testing.T) {
+	config := *params.TestChainConfig
+	config.ArbitrumChainParams.GenesisBlockNum = 100
+
+	genesis := config.ArbitrumChainParams.GenesisBlockNum
+	db, blocks := newTestArbitrumIndexerChain(genesis, 32)
+	head := blocks[len(blocks)-1].NumberU64()
+
+	indexer := &txIndexer{
+		genesis:  genesis,
+		db:       db,
+		progress: make(chan chan TxIndexProgress),
+	}
+	if indexer.report(head, nil).Done() {
+		t.Fatal("unindexed chain reported as done")
+	}
+	verify := func(expTail uint64) {
+		t.Helper()
+		tail := rawdb.ReadTxIndexTail(db)
+		if tail == nil || *tail != expTail {
+			t.Fatalf("tx index tail mismatch: have %v, want %d", tail, expTail)
+		}
+		checkTxLookups(t, db, blocks[expTail-genesis:])
+		if progress := indexer.report(head, tail); !progress.Done() {
+			t.Fatalf("expected fully indexed, have %+v", progress)
+		}
+	}
+	indexer.run(nil, head, make(chan struct{}), make(chan struct{}))
+	verify(genesis)
+
+	// A present tail at the genesis must not trigger indexing below it
+	indexer.run(rawdb.ReadTxIndexTail(db), head, make(chan struct{}), make(chan struct{}))
+	verify(genesis)
+
+	indexer.limit = 16
+	indexer.run(rawdb.ReadTxIndexTail(db), head, make(chan struct{}), make(chan struct{}))
+	verify(head - 15)
+
+	indexer.limit = 64
+	indexer.run(rawdb.ReadTxIndexTail(db), head, make(chan struct{}), make(chan struct{}))
+	verify(genesis)
+}
+
+// TestTxIndexerRepair tests that corrupted lookup entries found by the startup
+// integrity check are rewritten, without moving the index tail.
+func TestTxIndexerRepair(t *testing.T) {
+	db, blocks := newTestArbitrumIndexerChain(100, 64)
+	head := blocks[len(blocks)-1].NumberU64()
+
+	indexer := &txIndexer{
+		genesis:  100,
+		db:       db,
+		progress: make(chan chan TxIndexProgress),
+	}
+	indexer.run(nil, head, make(chan struct{}), make(chan struct{}))
+	checkTxLookups(t, db, blocks)
+
+	verifyTail := func() {
+		t.Helper()
+		if tail := rawdb.ReadTxIndexTail(db); tail == nil || *tail != 100 {
+			t.Fatalf("tx index tail mismatch: have %v, want %d", tail, 100)
+		}
+	}
+	// Drop every lookup entry so that all samples are bound to be corrupted
+	for _, block := range blocks {
+		for _, tx := range block.Transactions() {
+			rawdb.DeleteTxLookupEntry(db, tx.Hash())
+		}
+	}
+	indexer.repair(100, head, make(chan struct{}))
+	checkTxLookups(t, db, blocks)
+	verifyTail()
+
+	// Point a few entries to a wrong block, a short range gets checked entirely
+	for _, block := range blocks[10:13] {
+		for _, tx := range block.Transactions() {
+			rawdb.WriteTxLookupEntries(db, 1, []common.Hash{tx.Hash()})
+		}
+	}
+	indexer.repair(blocks[8].NumberU64(), blocks[16].NumberU64(), make(chan struct{}))
+	checkTxLookups(t, db, blocks)
+	verifyTail()
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -526,6 +528,29 @@ func (info HeaderInfo) UpdateHeaderWithInfo(header *Header) {
 	header.Extra = info.extra()
 }
 
+// ErrInvalidHeaderExtra is returned by DeserializeHeaderExtraInformationChecked
+// for headers carrying malformed Arbitrum information.
+var ErrInvalidHeaderExtra = errors.New("invalid Arbitrum header extra information")
+
+// DeserializeHeaderExtraInformationChecked is the strict variant of
+// DeserializeHeaderExtraInformation. Headers without Arbitrum information, like
+// the ones of imported classic blocks which have no base fee, still yield a
+// zero HeaderInfo. An error is returned instead for a Nitro header whose extra
+// data isn't a 32 byte send root, or whose mix digest has bytes set past the
+// ArbOS version, rather than silently reading it as ArbOS version 0.
+func DeserializeHeaderExtraInformationChecked(header *Header) (HeaderInfo, error) {
+	if header == nil || header.BaseFee == nil || header.BaseFee.Sign() == 0 || len(header.Extra) == 0 || header.Difficulty.Cmp(common.Big1) != 0 {
+		return HeaderInfo{}, nil
+	}
+	if len(header.Extra) != 32 {
+		return HeaderInfo{}, fmt.Errorf("%w: extra data is %d bytes, want 32", ErrInvalidHeaderExtra, len(header.Extra))
+	}
+	if unused := header.MixDigest[24:]; !bytes.Equal(unused, make([]byte, len(unused))) {
+		return HeaderInfo{}, fmt.Errorf("%w: mix digest %x has bytes set past the ArbOS version", ErrInvalidHeaderExtra, header.MixDigest)
+	}
+	return DeserializeHeaderExtraInformation(header), nil
+}
+
 func DeserializeHeaderExtraInformation(header *Header) HeaderInfo {
 	if header == nil || header.BaseFee == nil || header.BaseFee.Sign() == 0 || len(header.Extra) != 32 || header.Difficulty.Cmp(common.Big1) != 0 {
 		// imported blocks have no base fee
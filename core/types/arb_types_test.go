@@ -0,0 +1,110 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDeserializeHeaderExtraInformationChecked(t *testing.T) {
+	info := HeaderInfo{
+		SendRoot:           common.Hash{0x01},
+		SendCount:          1,
+		L1BlockNumber:      2,
+		ArbOSFormatVersion: 3,
+	}
+	// nitroHeader returns a Nitro header carrying info, modified by the given
+	// function
+	nitroHeader := func(modify func(*Header)) *Header {
+		header := &Header{
+			Number:     big.NewInt(1000),
+			Difficulty: common.Big1,
+			BaseFee:    big.NewInt(100_000_000),
+		}
+		info.UpdateHeaderWithInfo(header)
+		if modify != nil {
+			modify(header)
+		}
+		return header
+	}
+	for _, test := range []struct {
+		name    string
+		header  *Header
+		want    HeaderInfo
+		wantErr bool
+	}{
+		{name: "nitro", header: nitroHeader(nil), want: info},
+		{name: "nil", header: nil},
+		{name: "classic, no base fee", header: nitroHeader(func(h *Header) { h.BaseFee = nil })},
+		{name: "classic, zero base fee", header: nitroHeader(func(h *Header) { h.BaseFee = new(big.Int) })},
+		{name: "not arbitrum difficulty", header: nitroHeader(func(h *Header) { h.Difficulty = big.NewInt(131072) })},
+		{name: "no extra", header: nitroHeader(func(h *Header) { h.Extra = nil })},
+		{name: "truncated extra", header: nitroHeader(func(h *Header) { h.Extra = h.Extra[:16] }), wantErr: true},
+		{name: "oversized extra", header: nitroHeader(func(h *Header) { h.Extra = append(h.Extra, 0xff) }), wantErr: true},
+		{name: "garbage mix digest", header: nitroHeader(func(h *Header) { h.MixDigest = common.Hash{0xde, 0xad, 0xbe, 0xef, 31: 0xff} }), wantErr: true},
+	} {
+		have, err := DeserializeHeaderExtraInformationChecked(test.header)
+		if test.wantErr {
+			if !errors.Is(err, ErrInvalidHeaderExtra) {
+				t.Errorf("%s: error mismatch: have %v, want %v", test.name, err, ErrInvalidHeaderExtra)
+			}
+			if have != (HeaderInfo{}) {
+				t.Errorf("%s: info returned along with the error: %+v", test.name, have)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if have != test.want {
+			t.Errorf("%s: info mismatch: have %+v, want %+v", test.name, have, test.want)
+		}
+		// The lenient variant agrees on valid headers
+		if lenient := DeserializeHeaderExtraInformation(test.header); lenient != have {
+			t.Errorf("%s: lenient info mismatch: have %+v, want %+v", test.name, lenient, have)
+		}
+	}
+}
+
+// BenchmarkDeserializeHeaderExtraInformation measures parsing the ArbOS header
+// fields, compared to hashing the header, which any cache keyed by header hash
+// would have to pay for on every lookup.
+func BenchmarkDeserializeHeaderExtraInformation(b *testing.B) {
+	header := &Header{
+		Number:     big.NewInt(1000),
+		Difficulty: common.Big1,
+		BaseFee:    big.NewInt(100_000_000),
+		Extra:      common.Hash{0x01}.Bytes(),
+		MixDigest:  common.Hash{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 3},
+	}
+	b.Run("parse", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			DeserializeHeaderExtraInformation(header)
+		}
+	})
+	b.Run("hash", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			header.Hash()
+		}
+	})
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var errNoFallbackClient = errors.New("logs of pre-Nitro blocks requested but no fallback client is configured")
+
+// fallbackBackend is implemented by backends able to forward requests about
+// pre-Nitro blocks to a classic node.
+type fallbackBackend interface {
+	FallbackClient() types.FallbackClient
+}
+
+// preNitroRange returns whether the range starts before the Nitro genesis,
+// along with the number of the Nitro genesis block.
+func (api *FilterAPI) preNitroRange(begin int64) (bool, uint64) {
+	config := api.sys.backend.ChainConfig()
+	if !config.IsArbitrum() {
+		return false, 0
+	}
+	genesis := config.ArbitrumChainParams.GenesisBlockNum
+	return begin >= 0 && uint64(begin) < genesis, genesis
+}
+
+// getLogsWithFallback serves a log query starting before the Nitro genesis,
+// forwarding the pre-Nitro part of the range to the fallback client and
+// serving the rest locally. The logs are returned ordered by block number and
+// log index.
+func (api *FilterAPI) getLogsWithFallback(ctx context.Context, crit FilterCriteria, begin, end int64, genesis uint64) ([]*types.Log, error) {
+	var client types.FallbackClient
+	if backend, ok := api.sys.backend.(fallbackBackend); ok {
+		client = backend.FallbackClient()
+	}
+	if client == nil {
+		return nil, errNoFallbackClient
+	}
+	classicEnd := genesis - 1
+	if end >= 0 && uint64(end) < classicEnd {
+		classicEnd = uint64(end)
+	}
+	args := map[string]interface{}{
+		"fromBlock": hexutil.Uint64(begin),
+		"toBlock":   hexutil.Uint64(classicEnd),
+	}
+	if len(crit.Addresses) > 0 {
+		args["address"] = crit.Addresses
+	}
+	if len(crit.Topics) > 0 {
+		args["topics"] = crit.Topics
+	}
+	var classic []*types.Log
+	if err := client.CallContext(ctx, &classic, "eth_getLogs", args); err != nil {
+		return nil, err
+	}
+	// Only keep the logs of the requested pre-Nitro blocks, the others are
+	// served locally
+	logs := make([]*types.Log, 0, len(classic))
+	for _, log := range classic {
+		if log != nil && log.BlockNumber >= uint64(begin) && log.BlockNumber <= classicEnd {
+			logs = append(logs, log)
+		}
+	}
+	if end < 0 || uint64(end) >= genesis {
+		local, err := api.sys.NewRangeFilter(int64(genesis), end, crit.Addresses, crit.Topics).Logs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, local...)
+	}
+	slices.SortStableFunc(logs, func(a, b *types.Log) int {
+		if c := cmp.Compare(a.BlockNumber, b.BlockNumber); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Index, b.Index)
+	})
+	return logs, nil
+}
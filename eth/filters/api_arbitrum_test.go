@@ -0,0 +1,175 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// arbitrumTestBackend is a test backend of an Arbitrum chain migrated from
+// classic, forwarding requests about pre-Nitro blocks to a fallback client.
+type arbitrumTestBackend struct {
+	*testBackend
+	config   *params.ChainConfig
+	fallback types.FallbackClient
+}
+
+func (b *arbitrumTestBackend) ChainConfig() *params.ChainConfig {
+	return b.config
+}
+
+func (b *arbitrumTestBackend) FallbackClient() types.FallbackClient {
+	return b.fallback
+}
+
+// stubFallbackClient answers eth_getLogs with canned logs.
+type stubFallbackClient struct {
+	logs  []*types.Log
+	calls []map[string]interface{}
+}
+
+func (c *stubFallbackClient) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	if method != "eth_getLogs" {
+		return errors.New("unexpected method " + method)
+	}
+	c.calls = append(c.calls, args[0].(map[string]interface{}))
+	enc, err := json.Marshal(c.logs)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(enc, result)
+}
+
+// Tests that log queries spanning the Nitro genesis are split between the
+// fallback client and the local chain.
+func TestGetLogsPreNitro(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		key, _  = crypto.GenerateKey()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		signer  = types.LatestSigner(params.TestChainConfig)
+		emitter = common.Address{0xfe}
+		gspec   = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: types.GenesisAlloc{
+				addr:    {Balance: big.NewInt(params.Ether)},
+				emitter: {Code: common.FromHex("4360006000a100")}, // LOG1(topic: NUMBER)
+			},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+	)
+	if _, err := gspec.Commit(db, triedb.NewDatabase(db, nil)); err != nil {
+		t.Fatal(err)
+	}
+	chain, _ := core.GenerateChain(gspec.Config, gspec.ToBlock(), ethash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{
+			Nonce:    gen.TxNonce(addr),
+			GasPrice: gen.BaseFee(),
+			Gas:      30000,
+			To:       &emitter,
+		}), signer, key)
+		gen.AddTx(tx)
+	})
+	bc, err := core.NewBlockChain(db, nil, nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Stop()
+	if _, err := bc.InsertChain(chain); err != nil {
+		t.Fatal(err)
+	}
+	config := *params.TestChainConfig
+	config.ArbitrumChainParams.EnableArbOS = true
+	config.ArbitrumChainParams.GenesisBlockNum = 5
+
+	fallback := &stubFallbackClient{
+		logs: []*types.Log{
+			{Address: emitter, Topics: []common.Hash{}, BlockNumber: 3, Index: 0},
+			{Address: emitter, Topics: []common.Hash{}, BlockNumber: 1, Index: 0},
+			{Address: emitter, Topics: []common.Hash{}, BlockNumber: 4, Index: 1},
+			{Address: emitter, Topics: []common.Hash{}, BlockNumber: 4, Index: 0},
+			{Address: emitter, Topics: []common.Hash{}, BlockNumber: 5, Index: 0}, // Served locally
+		},
+	}
+	backend := &arbitrumTestBackend{testBackend: &testBackend{db: db}, config: &config, fallback: fallback}
+	api := NewFilterAPI(NewFilterSystem(backend, Config{}))
+
+	getLogs := func(from, to rpc.BlockNumber) ([]*types.Log, error) {
+		return api.GetLogs(context.Background(), FilterCriteria{FromBlock: big.NewInt(from.Int64()), ToBlock: big.NewInt(to.Int64()), Addresses: []common.Address{emitter}})
+	}
+	checkLogs := func(logs []*types.Log, want [][2]uint64) {
+		t.Helper()
+		if len(logs) != len(want) {
+			t.Fatalf("log count mismatch: have %d, want %d", len(logs), len(want))
+		}
+		for i, log := range logs {
+			if log.BlockNumber != want[i][0] || uint64(log.Index) != want[i][1] {
+				t.Fatalf("log %d mismatch: have (%d, %d), want (%d, %d)", i, log.BlockNumber, log.Index, want[i][0], want[i][1])
+			}
+		}
+	}
+	// A range straddling the Nitro genesis is merged in order
+	logs, err := getLogs(0, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to get logs: %v", err)
+	}
+	checkLogs(logs, [][2]uint64{{1, 0}, {3, 0}, {4, 0}, {4, 1}, {5, 0}, {6, 0}, {7, 0}, {8, 0}, {9, 0}, {10, 0}})
+	if logs[4].TxHash != chain[4].Transactions()[0].Hash() {
+		t.Fatalf("boundary log not served locally")
+	}
+	if len(fallback.calls) != 1 || fallback.calls[0]["fromBlock"] != hexutil.Uint64(0) || fallback.calls[0]["toBlock"] != hexutil.Uint64(4) {
+		t.Fatalf("unexpected fallback requests: %v", fallback.calls)
+	}
+	// A pre-Nitro range is served by the fallback client only
+	logs, err = getLogs(1, 3)
+	if err != nil {
+		t.Fatalf("failed to get logs: %v", err)
+	}
+	checkLogs(logs, [][2]uint64{{1, 0}, {3, 0}})
+
+	// A post-Nitro range is served locally only
+	fallback.calls = nil
+	logs, err = getLogs(8, 9)
+	if err != nil {
+		t.Fatalf("failed to get logs: %v", err)
+	}
+	checkLogs(logs, [][2]uint64{{8, 0}, {9, 0}})
+	if len(fallback.calls) != 0 {
+		t.Fatalf("fallback client used for post-Nitro range: %v", fallback.calls)
+	}
+	// Without fallback client, pre-Nitro logs can't be served
+	backend.fallback = nil
+	if _, err := getLogs(0, 8); !errors.Is(err, errNoFallbackClient) {
+		t.Fatalf("error mismatch: have %v, want %v", err, errNoFallbackClient)
+	}
+}
@@ -68,6 +68,7 @@ type Backend interface {
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
 	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
+	SubscribeFinalizedHeaderEvent(ch chan<- core.FinalizedHeaderEvent) event.Subscription
 
 	BloomStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
@@ -154,6 +155,8 @@ const (
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// FinalizedHeadersSubscription queries headers of blocks that become finalized
+	FinalizedHeadersSubscription
 	// LastIndexSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -168,6 +171,8 @@ const (
 	logsChanSize = 10
 	// chainEvChanSize is the size of channel listening to ChainEvent.
 	chainEvChanSize = 10
+	// finalizedEvChanSize is the size of channel listening to FinalizedHeaderEvent.
+	finalizedEvChanSize = 10
 )
 
 type subscription struct {
@@ -193,14 +198,16 @@ type EventSystem struct {
 	logsSub   event.Subscription // Subscription for new log event
 	rmLogsSub event.Subscription // Subscription for removed log event
 	chainSub  event.Subscription // Subscription for new chain event
+	finalSub  event.Subscription // Subscription for finalized header event
 
 	// Channels
-	install   chan *subscription         // install filter for event notification
-	uninstall chan *subscription         // remove filter for event notification
-	txsCh     chan core.NewTxsEvent      // Channel to receive new transactions event
-	logsCh    chan []*types.Log          // Channel to receive new log event
-	rmLogsCh  chan core.RemovedLogsEvent // Channel to receive removed log event
-	chainCh   chan core.ChainEvent       // Channel to receive new chain event
+	install   chan *subscription             // install filter for event notification
+	uninstall chan *subscription             // remove filter for event notification
+	txsCh     chan core.NewTxsEvent          // Channel to receive new transactions event
+	logsCh    chan []*types.Log              // Channel to receive new log event
+	rmLogsCh  chan core.RemovedLogsEvent     // Channel to receive removed log event
+	chainCh   chan core.ChainEvent           // Channel to receive new chain event
+	finalCh   chan core.FinalizedHeaderEvent // Channel to receive finalized header event
 }
 
 // NewEventSystem creates a new manager that listens for event on the given mux,
@@ -219,6 +226,7 @@ func NewEventSystem(sys *FilterSystem) *EventSystem {
 		logsCh:    make(chan []*types.Log, logsChanSize),
 		rmLogsCh:  make(chan core.RemovedLogsEvent, rmLogsChanSize),
 		chainCh:   make(chan core.ChainEvent, chainEvChanSize),
+		finalCh:   make(chan core.FinalizedHeaderEvent, finalizedEvChanSize),
 	}
 
 	// Subscribe events
@@ -226,9 +234,10 @@ func NewEventSystem(sys *FilterSystem) *EventSystem {
 	m.logsSub = m.backend.SubscribeLogsEvent(m.logsCh)
 	m.rmLogsSub = m.backend.SubscribeRemovedLogsEvent(m.rmLogsCh)
 	m.chainSub = m.backend.SubscribeChainEvent(m.chainCh)
+	m.finalSub = m.backend.SubscribeFinalizedHeaderEvent(m.finalCh)
 
 	// Make sure none of the subscriptions are empty
-	if m.txsSub == nil || m.logsSub == nil || m.rmLogsSub == nil || m.chainSub == nil {
+	if m.txsSub == nil || m.logsSub == nil || m.rmLogsSub == nil || m.chainSub == nil || m.finalSub == nil {
 		log.Crit("Subscribe for event system failed")
 	}
 
@@ -353,6 +362,22 @@ func (es *EventSystem) SubscribeNewHeads(headers chan *types.Header) *Subscripti
 	return es.subscribe(sub)
 }
 
+// SubscribeFinalizedHeads creates a subscription that writes the header of a
+// block that becomes finalized.
+func (es *EventSystem) SubscribeFinalizedHeads(headers chan *types.Header) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       FinalizedHeadersSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		txs:       make(chan []*types.Transaction),
+		headers:   headers,
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
 // SubscribePendingTxs creates a subscription that writes transactions for
 // transactions that enter the transaction pool.
 func (es *EventSystem) SubscribePendingTxs(txs chan []*types.Transaction) *Subscription {
@@ -395,6 +420,12 @@ func (es *EventSystem) handleChainEvent(filters filterIndex, ev core.ChainEvent)
 	}
 }
 
+func (es *EventSystem) handleFinalizedEvent(filters filterIndex, ev core.FinalizedHeaderEvent) {
+	for _, f := range filters[FinalizedHeadersSubscription] {
+		f.headers <- ev.Header
+	}
+}
+
 // eventLoop (un)installs filters and processes mux events.
 func (es *EventSystem) eventLoop() {
 	// Ensure all subscriptions get cleaned up
@@ -403,6 +434,7 @@ func (es *EventSystem) eventLoop() {
 		es.logsSub.Unsubscribe()
 		es.rmLogsSub.Unsubscribe()
 		es.chainSub.Unsubscribe()
+		es.finalSub.Unsubscribe()
 	}()
 
 	index := make(filterIndex)
@@ -420,6 +452,8 @@ func (es *EventSystem) eventLoop() {
 			es.handleLogs(index, ev.Logs)
 		case ev := <-es.chainCh:
 			es.handleChainEvent(index, ev)
+		case ev := <-es.finalCh:
+			es.handleFinalizedEvent(index, ev)
 
 		case f := <-es.install:
 			index[f.typ][f.id] = f
@@ -438,6 +472,8 @@ func (es *EventSystem) eventLoop() {
 			return
 		case <-es.chainSub.Err():
 			return
+		case <-es.finalSub.Err():
+			return
 		}
 	}
 }
@@ -46,6 +46,7 @@ type testBackend struct {
 	logsFeed        event.Feed
 	rmLogsFeed      event.Feed
 	chainFeed       event.Feed
+	finalizedFeed   event.Feed
 	pendingBlock    *types.Block
 	pendingReceipts types.Receipts
 }
@@ -137,6 +138,10 @@ func (b *testBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subsc
 	return b.chainFeed.Subscribe(ch)
 }
 
+func (b *testBackend) SubscribeFinalizedHeaderEvent(ch chan<- core.FinalizedHeaderEvent) event.Subscription {
+	return b.finalizedFeed.Subscribe(ch)
+}
+
 func (b *testBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, b.sections
 }
@@ -238,6 +243,40 @@ func TestBlockSubscription(t *testing.T) {
 	<-sub1.Err()
 }
 
+// TestFinalizedHeadSubscription tests if a finalized header subscription
+// returns the headers of blocks as they become finalized.
+func TestFinalizedHeadSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db           = rawdb.NewMemoryDatabase()
+		backend, sys = newTestFilterSystem(t, db, Config{})
+		api          = NewFilterAPI(sys)
+		genesis      = &core.Genesis{
+			Config:  params.TestChainConfig,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		_, chain, _ = core.GenerateChainWithGenesis(genesis, ethash.NewFaker(), 5, func(i int, gen *core.BlockGen) {})
+	)
+	headers := make(chan *types.Header)
+	sub := api.events.SubscribeFinalizedHeads(headers)
+
+	go func() { // simulate client
+		for i := 0; i < len(chain); i++ {
+			if header := <-headers; header.Hash() != chain[i].Hash() {
+				t.Errorf("received invalid hash on index %d, want %x, got %x", i, chain[i].Hash(), header.Hash())
+			}
+		}
+		sub.Unsubscribe()
+	}()
+
+	time.Sleep(1 * time.Second)
+	for _, block := range chain {
+		backend.finalizedFeed.Send(core.FinalizedHeaderEvent{Header: block.Header()})
+	}
+	<-sub.Err()
+}
+
 // TestPendingTxFilter tests whether pending tx filters retrieve all pending transactions that are posted to the event mux.
 func TestPendingTxFilter(t *testing.T) {
 	t.Parallel()
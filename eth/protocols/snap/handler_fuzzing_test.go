@@ -124,6 +124,7 @@ func getChain() *core.BlockChain {
 		TrieCleanNoPrefetch: true,
 		SnapshotLimit:       100,
 		SnapshotWait:        true,
+		TriesInMemory:       128,
 	}
 	trieRoot = blocks[len(blocks)-1].Root()
 	bc, _ := core.NewBlockChain(rawdb.NewMemoryDatabase(), cacheConf, nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
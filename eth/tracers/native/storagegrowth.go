@@ -0,0 +1,240 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/internal"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func init() {
+	tracers.DefaultDirectory.Register("storageGrowthTracer", newStorageGrowthTracer, false)
+}
+
+// StorageGrowth is the set of accounts and storage slots a transaction (or a
+// block of transactions) brought into existence, along with the L2 execution gas
+// charged for creating each of them afresh: the new account gas per account and
+// the zero to non-zero SSTORE gas per slot.
+type StorageGrowth struct {
+	Accounts  []common.Address                 `json:"accounts"`
+	Storage   map[common.Address][]common.Hash `json:"storage"`
+	GrowthGas uint64                           `json:"growthGas"`
+}
+
+// Merge adds the accounts and slots of other into s, skipping duplicates.
+func (s *StorageGrowth) Merge(other *StorageGrowth) {
+	if s.Accounts == nil {
+		s.Accounts = []common.Address{}
+	}
+	for _, addr := range other.Accounts {
+		if !slices.Contains(s.Accounts, addr) {
+			s.Accounts = append(s.Accounts, addr)
+		}
+	}
+	if s.Storage == nil {
+		s.Storage = make(map[common.Address][]common.Hash)
+	}
+	for addr, slots := range other.Storage {
+		for _, slot := range slots {
+			if !slices.Contains(s.Storage[addr], slot) {
+				s.Storage[addr] = append(s.Storage[addr], slot)
+			}
+		}
+	}
+	s.normalize()
+}
+
+// normalize sorts the accounts and slots and recomputes the growth gas.
+func (s *StorageGrowth) normalize() {
+	slices.SortFunc(s.Accounts, func(a, b common.Address) int { return bytes.Compare(a[:], b[:]) })
+	s.GrowthGas = uint64(len(s.Accounts)) * params.CallNewAccountGas
+	for _, slots := range s.Storage {
+		slices.SortFunc(slots, func(a, b common.Hash) int { return bytes.Compare(a[:], b[:]) })
+		s.GrowthGas += uint64(len(slots)) * params.SstoreSetGasEIP2200
+	}
+}
+
+// storageGrowthTracer reports the accounts which went from empty to non-empty
+// and the storage slots which went from zero to non-zero during a transaction.
+// Accounts and slots are recorded as they are first touched and compared with
+// the final state once the transaction ends, so anything created within a
+// reverted frame is left out.
+//
+// Example:
+//
+//	> debug.traceTransaction("0x...", {tracer: "storageGrowthTracer"})
+//	{
+//	  accounts: ["0x2bd2326c993dfaef84f696526064ff22eba5b362"],
+//	  storage: {
+//	    "0x2bd2326c993dfaef84f696526064ff22eba5b362": ["0x0000000000000000000000000000000000000000000000000000000000000000"]
+//	  },
+//	  growthGas: 45000
+//	}
+type storageGrowthTracer struct {
+	env       *tracing.VMContext
+	accounts  map[common.Address]bool                 // Whether each touched account was empty before the tx
+	slots     map[common.Address]map[common.Hash]bool // Whether each written slot was zero before the tx
+	result    *StorageGrowth
+	interrupt atomic.Bool // Atomic flag to signal execution interruption
+	reason    error       // Textual reason for the interruption
+}
+
+func newStorageGrowthTracer(ctx *tracers.Context, _ json.RawMessage) (*tracers.Tracer, error) {
+	t := &storageGrowthTracer{
+		accounts: make(map[common.Address]bool),
+		slots:    make(map[common.Address]map[common.Hash]bool),
+	}
+	return &tracers.Tracer{
+		Hooks: &tracing.Hooks{
+			OnTxStart: t.OnTxStart,
+			OnTxEnd:   t.OnTxEnd,
+			OnOpcode:  t.OnOpcode,
+		},
+		GetResult: t.GetResult,
+		Stop:      t.Stop,
+	}, nil
+}
+
+// OnOpcode records the accounts and slots an opcode is about to create.
+func (t *storageGrowthTracer) OnOpcode(pc uint64, opcode byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
+	if err != nil {
+		return
+	}
+	// Skip if tracing was interrupted
+	if t.interrupt.Load() {
+		return
+	}
+	op := vm.OpCode(opcode)
+	stackData := scope.StackData()
+	stackLen := len(stackData)
+	caller := scope.Address()
+	switch {
+	case stackLen >= 1 && op == vm.SSTORE:
+		t.lookupSlot(caller, common.Hash(stackData[stackLen-1].Bytes32()))
+	case stackLen >= 1 && op == vm.SELFDESTRUCT:
+		t.lookupAccount(common.Address(stackData[stackLen-1].Bytes20()))
+	case stackLen >= 3 && op == vm.CALL:
+		if !stackData[stackLen-3].IsZero() {
+			t.lookupAccount(common.Address(stackData[stackLen-2].Bytes20()))
+		}
+	case op == vm.CREATE:
+		t.lookupAccount(crypto.CreateAddress(caller, t.env.StateDB.GetNonce(caller)))
+	case stackLen >= 4 && op == vm.CREATE2:
+		offset := stackData[stackLen-2]
+		size := stackData[stackLen-3]
+		init, err := internal.GetMemoryCopyPadded(scope.MemoryData(), int64(offset.Uint64()), int64(size.Uint64()))
+		if err != nil {
+			log.Warn("failed to copy CREATE2 input", "err", err, "tracer", "storageGrowthTracer", "offset", offset, "size", size)
+			return
+		}
+		salt := stackData[stackLen-4]
+		t.lookupAccount(crypto.CreateAddress2(caller, salt.Bytes32(), crypto.Keccak256(init)))
+	}
+}
+
+func (t *storageGrowthTracer) OnTxStart(env *tracing.VMContext, tx *types.Transaction, from common.Address) {
+	t.env = env
+	t.lookupAccount(from)
+	if tx.To() == nil {
+		t.lookupAccount(crypto.CreateAddress(from, env.StateDB.GetNonce(from)))
+	} else {
+		t.lookupAccount(*tx.To())
+	}
+}
+
+// OnTxEnd compares the recorded accounts and slots against the final state.
+func (t *storageGrowthTracer) OnTxEnd(receipt *types.Receipt, err error) {
+	if err != nil {
+		return
+	}
+	res := &StorageGrowth{
+		Accounts: []common.Address{},
+		Storage:  make(map[common.Address][]common.Hash),
+	}
+	for addr, empty := range t.accounts {
+		if empty && !t.isEmpty(addr) {
+			res.Accounts = append(res.Accounts, addr)
+		}
+	}
+	for addr, slots := range t.slots {
+		for slot, zero := range slots {
+			if zero && t.env.StateDB.GetState(addr, slot) != (common.Hash{}) {
+				res.Storage[addr] = append(res.Storage[addr], slot)
+			}
+		}
+	}
+	res.normalize()
+	t.result = res
+}
+
+// GetResult returns the json-encoded storage growth of the transaction, and
+// any error arising from the encoding or forceful termination (via `Stop`).
+func (t *storageGrowthTracer) GetResult() (json.RawMessage, error) {
+	res := t.result
+	if res == nil {
+		res = &StorageGrowth{Accounts: []common.Address{}, Storage: make(map[common.Address][]common.Hash)}
+	}
+	enc, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return enc, t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *storageGrowthTracer) Stop(err error) {
+	t.reason = err
+	t.interrupt.Store(true)
+}
+
+// lookupAccount records whether the account is empty, unless it was already
+// touched earlier in the transaction.
+func (t *storageGrowthTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.accounts[addr]; ok {
+		return
+	}
+	t.accounts[addr] = t.isEmpty(addr)
+}
+
+// lookupSlot records whether the slot is zero, unless it was already written
+// earlier in the transaction.
+func (t *storageGrowthTracer) lookupSlot(addr common.Address, slot common.Hash) {
+	if _, ok := t.slots[addr][slot]; ok {
+		return
+	}
+	if t.slots[addr] == nil {
+		t.slots[addr] = make(map[common.Hash]bool)
+	}
+	t.slots[addr][slot] = t.env.StateDB.GetState(addr, slot) == (common.Hash{})
+}
+
+func (t *storageGrowthTracer) isEmpty(addr common.Address) bool {
+	db := t.env.StateDB
+	return db.GetNonce(addr) == 0 && db.GetBalance(addr).IsZero() && len(db.GetCode(addr)) == 0
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/native"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageGrowthTracer(t *testing.T) {
+	var (
+		caller   = common.HexToAddress("0xaa")
+		reverter = common.HexToAddress("0xbb")
+		fresh    = common.HexToAddress("0xcc")
+
+		// Init code of a contract setting slot 0 to 1: PUSH1 1 PUSH1 0 SSTORE STOP
+		initCode = "600160005500"
+		// Stores the init code in memory and runs it with CREATE
+		create = "65" + initCode + "600052" + "6006601a6000f0" + "50"
+
+		// Creates a contract and then reverts the whole frame
+		reverterCode = common.FromHex(create + "60006000fd")
+		// Sets slot 0, rewrites the empty slot 1 with zero, calls the reverter,
+		// creates a contract of its own and sends wei to an empty account
+		callerCode = common.FromHex("6001600055" + "6000600155" +
+			"6000600060006000600073" + reverter.Hex()[2:] + "5af150" +
+			create +
+			"6000600060006000600173" + fresh.Hex()[2:] + "5af150" +
+			"00")
+	)
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(caller, callerCode)
+	statedb.SetNonce(caller, 1)
+	statedb.SetBalance(caller, uint256.NewInt(1), tracing.BalanceChangeUnspecified)
+	statedb.SetCode(reverter, reverterCode)
+	statedb.SetNonce(reverter, 1)
+
+	tracer, err := tracers.DefaultDirectory.New("storageGrowthTracer", &tracers.Context{}, nil)
+	require.NoError(t, err)
+
+	_, _, err = runtime.Call(caller, nil, &runtime.Config{State: statedb, EVMConfig: vm.Config{Tracer: tracer.Hooks}})
+	require.NoError(t, err)
+	tracer.OnTxEnd(&types.Receipt{}, nil)
+
+	res, err := tracer.GetResult()
+	require.NoError(t, err)
+
+	var growth native.StorageGrowth
+	require.NoError(t, json.Unmarshal(res, &growth))
+
+	// The reverted creation must not show up, neither as account nor as slot
+	child := crypto.CreateAddress(caller, 1)
+	require.Nil(t, statedb.GetCode(crypto.CreateAddress(reverter, 1)))
+	require.Equal(t, []common.Address{fresh, child}, growth.Accounts)
+	require.Equal(t, map[common.Address][]common.Hash{
+		caller: {{}},
+		child:  {{}},
+	}, growth.Storage)
+	require.Equal(t, uint64(2*25000+2*20000), growth.GrowthGas)
+}
+
+func TestStorageGrowthMerge(t *testing.T) {
+	var (
+		a, b = common.HexToAddress("0x01"), common.HexToAddress("0x02")
+		s1   = common.HexToHash("0x01")
+		s2   = common.HexToHash("0x02")
+	)
+	var total native.StorageGrowth
+	total.Merge(&native.StorageGrowth{Accounts: []common.Address{b}, Storage: map[common.Address][]common.Hash{a: {s2}}})
+	total.Merge(&native.StorageGrowth{Accounts: []common.Address{a, b}, Storage: map[common.Address][]common.Hash{a: {s1, s2}}})
+
+	require.Equal(t, []common.Address{a, b}, total.Accounts)
+	require.Equal(t, map[common.Address][]common.Hash{a: {s1, s2}}, total.Storage)
+	require.Equal(t, uint64(2*25000+2*20000), total.GrowthGas)
+}
@@ -188,6 +188,18 @@ type WasmDataBaseRetriever interface {
 	WasmTargets() []WasmTarget
 }
 
+// AncientTablePruner wraps the methods of an ancient store whose tables may be
+// pruned on their own, leaving the other tables intact.
+type AncientTablePruner interface {
+	// AncientTableTail returns the number of the first item stored in the table,
+	// which is above the tail of the ancient store if the table was pruned.
+	AncientTableTail(kind string) (uint64, error)
+
+	// TruncateTableTail discards the first n items of the table. The already
+	// deleted items are ignored. It returns the previous tail of the table.
+	TruncateTableTail(kind string, n uint64) (uint64, error)
+}
+
 // ResettableAncientStore extends the AncientStore interface by adding a Reset method.
 type ResettableAncientStore interface {
 	AncientStore
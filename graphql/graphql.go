@@ -487,6 +487,15 @@ func (t *Transaction) CumulativeGasUsed(ctx context.Context) (*hexutil.Uint64, e
 	return &ret, nil
 }
 
+func (t *Transaction) GasUsedForL1(ctx context.Context) (*hexutil.Uint64, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	ret := hexutil.Uint64(receipt.GasUsedForL1)
+	return &ret, nil
+}
+
 func (t *Transaction) BlobGasUsed(ctx context.Context) (*hexutil.Uint64, error) {
 	tx, _ := t.resolve(ctx)
 	if tx == nil {
@@ -431,6 +431,79 @@ func TestWithdrawals(t *testing.T) {
 	}
 }
 
+func TestGraphQLGasUsedForL1(t *testing.T) {
+	// Copy the config, the withdrawals test enables the merge in the shared one
+	config := *params.AllEthashProtocolChanges
+	config.TerminalTotalDifficulty = nil
+	config.ShanghaiTime = nil
+
+	var (
+		key, _  = crypto.GenerateKey()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		genesis = &core.Genesis{
+			Config:     &config,
+			GasLimit:   11500000,
+			Difficulty: big.NewInt(1048576),
+			Alloc: types.GenesisAlloc{
+				addr: {Balance: big.NewInt(params.Ether)},
+			},
+		}
+		signer = types.LatestSigner(genesis.Config)
+		stack  = createNode(t)
+	)
+	defer stack.Close()
+
+	ethBackend, err := eth.New(stack, &ethconfig.Config{
+		Genesis:        genesis,
+		NetworkId:      1337,
+		TrieCleanCache: 5,
+		TrieDirtyCache: 5,
+		TrieTimeout:    60 * time.Minute,
+		SnapshotCache:  5,
+		StateScheme:    rawdb.HashScheme,
+	})
+	if err != nil {
+		t.Fatalf("could not create eth backend: %v", err)
+	}
+	chain, _ := core.GenerateChain(genesis.Config, ethBackend.BlockChain().Genesis(), ethash.NewFaker(), ethBackend.ChainDb(), 1, func(i int, gen *core.BlockGen) {
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{To: &common.Address{}, Nonce: nonce, Gas: 100000, GasPrice: big.NewInt(params.InitialBaseFee)})
+			gen.AddTx(tx)
+		}
+	})
+	if _, err := ethBackend.BlockChain().InsertChain(chain); err != nil {
+		t.Fatalf("could not import blocks: %v", err)
+	}
+	// Only Arbitrum chains charge for the L1 gas, fill it in the stored receipts
+	block := chain[0]
+	receipts := rawdb.ReadRawReceipts(ethBackend.ChainDb(), block.Hash(), block.NumberU64())
+	for i, receipt := range receipts {
+		receipt.GasUsedForL1 = uint64(i+1) * 1000
+	}
+	rawdb.WriteReceipts(ethBackend.ChainDb(), block.Hash(), block.NumberU64(), receipts)
+
+	filterSystem := filters.NewFilterSystem(ethBackend.APIBackend, filters.Config{})
+	handler, err := newHandler(stack, ethBackend.APIBackend, filterSystem, []string{}, []string{})
+	if err != nil {
+		t.Fatalf("could not create graphql service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	res := handler.Schema.Exec(context.Background(), "{block { transactions { gasUsed gasUsedForL1 } } }", "", map[string]interface{}{})
+	if res.Errors != nil {
+		t.Fatalf("failed to execute query: %v", res.Errors)
+	}
+	have, err := json.Marshal(res.Data)
+	if err != nil {
+		t.Fatalf("failed to encode graphql response: %s", err)
+	}
+	want := `{"block":{"transactions":[{"gasUsed":"0x5208","gasUsedForL1":"0x3e8"},{"gasUsed":"0x5208","gasUsedForL1":"0x7d0"}]}}`
+	if string(have) != want {
+		t.Errorf("response unmatch.\nhave:\n%s\nwant:\n%s", have, want)
+	}
+}
+
 func createNode(t *testing.T) *node.Node {
 	stack, err := node.New(&node.Config{
 		HTTPHost:     "127.0.0.1",
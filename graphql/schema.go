@@ -136,6 +136,10 @@ const schema string = `
         # this transaction. If the transaction has not yet been mined, this field
         # will be null.
         cumulativeGasUsed: Long
+        # GasUsedForL1 is the part of gasUsed paying for the posting of this
+        # transaction to the parent chain, on Arbitrum chains. If the
+        # transaction has not yet been mined, this field will be null.
+        gasUsedForL1: Long
         # EffectiveGasPrice is actual value per gas deducted from the sender's
         # account. Before EIP-1559, this is equal to the transaction's gas price.
         # After EIP-1559, it is baseFeePerGas + min(maxFeePerGas - baseFeePerGas,
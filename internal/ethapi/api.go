@@ -38,6 +38,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/misc/eip1559"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/tracing"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -2338,6 +2339,43 @@ func (s *DebugAPI) GetRawTransaction(ctx context.Context, hash common.Hash) (hex
 	return tx.MarshalBinary()
 }
 
+// GetBadBlockDetail returns a bad block seen by the client along with the error
+// that made it invalid and the receipts generated before the failure. Bad blocks
+// stored without this context are returned with a null error and receipts.
+func (api *DebugAPI) GetBadBlockDetail(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
+	db := api.b.ChainDb()
+	block := rawdb.ReadBadBlock(db, hash)
+	if block == nil {
+		return nil, fmt.Errorf("bad block %#x not found", hash)
+	}
+	fields := map[string]interface{}{
+		"hash":     hash,
+		"block":    RPCMarshalBlock(block, true, true, api.b.ChainConfig()),
+		"error":    nil,
+		"receipts": nil,
+	}
+	detail := rawdb.ReadBadBlockDetail(db, hash)
+	if detail == nil {
+		return fields, nil
+	}
+	fields["error"] = detail.Error
+
+	// Receipts are only available up to the failing transaction
+	txs := block.Transactions()
+	if len(detail.Receipts) > len(txs) {
+		return nil, fmt.Errorf("bad block %#x has %d receipts for %d transactions", hash, len(detail.Receipts), len(txs))
+	}
+	receipts := make(types.Receipts, len(detail.Receipts))
+	for i, receipt := range detail.Receipts {
+		receipts[i] = (*types.Receipt)(receipt)
+	}
+	if err := receipts.DeriveFields(api.b.ChainConfig(), hash, block.NumberU64(), block.Time(), block.BaseFee(), nil, txs[:len(receipts)]); err != nil {
+		return nil, err
+	}
+	fields["receipts"] = receipts
+	return fields, nil
+}
+
 // PrintBlock retrieves a block and returns its pretty printed form.
 func (api *DebugAPI) PrintBlock(ctx context.Context, number uint64) (string, error) {
 	block, _ := api.b.BlockByNumber(ctx, rpc.BlockNumber(number))
@@ -2062,3 +2062,49 @@ func testRPCResponseWithFile(t *testing.T, testid int, result interface{}, rpc s
 	}
 	require.JSONEqf(t, string(want), string(data), "test %d: json not match, want: %s, have: %s", testid, string(want), string(data))
 }
+
+func TestDebugGetBadBlockDetail(t *testing.T) {
+	t.Parallel()
+
+	var (
+		backend, _ = setupReceiptBackend(t, 4)
+		api        = NewDebugAPI(backend)
+		ctx        = context.Background()
+	)
+	legacy, _ := backend.BlockByNumber(ctx, 1)
+	bad, _ := backend.BlockByNumber(ctx, 3)
+	receipts, _ := backend.GetReceipts(ctx, bad.Hash())
+
+	// Bad blocks stored without context are still returned
+	rawdb.WriteBadBlock(backend.db, legacy)
+	result, err := api.GetBadBlockDetail(ctx, legacy.Hash())
+	if err != nil {
+		t.Fatalf("failed to get bad block: %v", err)
+	}
+	if result["error"] != nil || result["receipts"] != nil {
+		t.Fatalf("unexpected detail for bad block without context: %v", result)
+	}
+	// Bad blocks stored with context return the error and receipts
+	rawdb.WriteBadBlock(backend.db, bad)
+	rawdb.WriteBadBlockDetail(backend.db, bad.Hash(), receipts, errors.New("invalid gas used"))
+
+	result, err = api.GetBadBlockDetail(ctx, bad.Hash())
+	if err != nil {
+		t.Fatalf("failed to get bad block: %v", err)
+	}
+	if result["error"] != "invalid gas used" {
+		t.Fatalf("error mismatch: have %v, want %v", result["error"], "invalid gas used")
+	}
+	have, ok := result["receipts"].(types.Receipts)
+	if !ok || len(have) != len(receipts) {
+		t.Fatalf("receipts mismatch: have %v, want %d receipts", result["receipts"], len(receipts))
+	}
+	for i, receipt := range have {
+		if receipt.TxHash != bad.Transactions()[i].Hash() || receipt.BlockHash != bad.Hash() || len(receipt.Logs) != len(receipts[i].Logs) {
+			t.Fatalf("receipt %d mismatch: have %+v, want %+v", i, receipt, receipts[i])
+		}
+	}
+	if _, err := api.GetBadBlockDetail(ctx, common.Hash{0x01}); err == nil {
+		t.Fatalf("expected error for unknown bad block")
+	}
+}
@@ -484,6 +484,10 @@ func (c *ChainConfig) Description() string {
 	if c.VerkleTime != nil {
 		banner += fmt.Sprintf(" - Verkle:                      @%-10v\n", *c.VerkleTime)
 	}
+	// Arbitrum: the multi-dimensional gas accounting switch
+	if c.IsArbitrum() && c.ArbitrumChainParams.MultiGasActivationTime != nil {
+		banner += fmt.Sprintf(" - MultiGas (Arbitrum):         @%-10v\n", *c.ArbitrumChainParams.MultiGasActivationTime)
+	}
 	return banner
 }
 
@@ -713,7 +717,7 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, headNumber *big.Int,
 		return newBlockCompatError("EIP158 chain ID", c.EIP158Block, newcfg.EIP158Block)
 	}
 
-	if err := c.checkArbitrumCompatible(newcfg, headNumber); err != nil {
+	if err := c.checkArbitrumCompatible(newcfg, headNumber, headTimestamp); err != nil {
 		return err
 	}
 	if isForkBlockIncompatible(c.ByzantiumBlock, newcfg.ByzantiumBlock, headNumber) {
@@ -53,6 +53,8 @@ type ArbitrumChainParams struct {
 	GenesisBlockNum           uint64
 	MaxCodeSize               uint64 `json:"MaxCodeSize,omitempty"`     // Maximum bytecode to permit for a contract. 0 value implies params.DefaultMaxCodeSize
 	MaxInitCodeSize           uint64 `json:"MaxInitCodeSize,omitempty"` // Maximum initcode to permit in a creation transaction and create instructions. 0 value implies params.DefaultMaxInitCodeSize
+
+	MultiGasActivationTime *uint64 `json:"MultiGasActivationTime,omitempty"` // Multi-dimensional gas accounting switch time (nil = no fork, 0 = already active)
 }
 
 func (c *ChainConfig) IsArbitrum() bool {
@@ -63,6 +65,12 @@ func (c *ChainConfig) IsArbitrumNitro(num *big.Int) bool {
 	return c.IsArbitrum() && isBlockForked(new(big.Int).SetUint64(c.ArbitrumChainParams.GenesisBlockNum), num)
 }
 
+// IsMultiGas returns whether multi-dimensional gas accounting is active at the
+// given block time.
+func (c *ChainConfig) IsMultiGas(time uint64) bool {
+	return c.IsArbitrum() && isTimestampForked(c.ArbitrumChainParams.MultiGasActivationTime, time)
+}
+
 func (c *ChainConfig) MaxCodeSize() uint64 {
 	if c.ArbitrumChainParams.MaxCodeSize == 0 {
 		return DefaultMaxCodeSize
@@ -81,7 +89,7 @@ func (c *ChainConfig) DebugMode() bool {
 	return c.ArbitrumChainParams.AllowDebugPrecompiles
 }
 
-func (c *ChainConfig) checkArbitrumCompatible(newcfg *ChainConfig, head *big.Int) *ConfigCompatError {
+func (c *ChainConfig) checkArbitrumCompatible(newcfg *ChainConfig, head *big.Int, headTimestamp uint64) *ConfigCompatError {
 	if c.IsArbitrum() != newcfg.IsArbitrum() {
 		// This difference applies to the entire chain, so report that the genesis block is where the difference appears.
 		return newBlockCompatError("isArbitrum", common.Big0, common.Big0)
@@ -94,6 +102,9 @@ func (c *ChainConfig) checkArbitrumCompatible(newcfg *ChainConfig, head *big.Int
 	if cArb.GenesisBlockNum != newArb.GenesisBlockNum {
 		return newBlockCompatError("genesisblocknum", new(big.Int).SetUint64(cArb.GenesisBlockNum), new(big.Int).SetUint64(newArb.GenesisBlockNum))
 	}
+	if isForkTimestampIncompatible(cArb.MultiGasActivationTime, newArb.MultiGasActivationTime, headTimestamp) {
+		return newTimestampCompatError("MultiGas activation timestamp", cArb.MultiGasActivationTime, newArb.MultiGasActivationTime)
+	}
 	return nil
 }
 
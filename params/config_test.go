@@ -110,6 +110,23 @@ func TestCheckCompatible(t *testing.T) {
 				RewindToTime: 9,
 			},
 		},
+		{
+			stored:        &ChainConfig{ArbitrumChainParams: ArbitrumChainParams{EnableArbOS: true}},
+			new:           &ChainConfig{ArbitrumChainParams: ArbitrumChainParams{EnableArbOS: true, MultiGasActivationTime: newUint64(20)}},
+			headTimestamp: 19,
+			wantErr:       nil,
+		},
+		{
+			stored:        &ChainConfig{ArbitrumChainParams: ArbitrumChainParams{EnableArbOS: true, MultiGasActivationTime: newUint64(30)}},
+			new:           &ChainConfig{ArbitrumChainParams: ArbitrumChainParams{EnableArbOS: true, MultiGasActivationTime: newUint64(20)}},
+			headTimestamp: 25,
+			wantErr: &ConfigCompatError{
+				What:         "MultiGas activation timestamp",
+				StoredTime:   newUint64(30),
+				NewTime:      newUint64(20),
+				RewindToTime: 19,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -177,6 +177,28 @@ func (db *Database) Initialized(genesisRoot common.Hash) bool {
 	return db.backend.Initialized(genesisRoot)
 }
 
+// CleanSize returns the memory used by the clean node cache of the database.
+func (db *Database) CleanSize() common.StorageSize {
+	switch backend := db.backend.(type) {
+	case *hashdb.Database:
+		return backend.CleanSize()
+	case *pathdb.Database:
+		return backend.CleanSize()
+	}
+	return 0
+}
+
+// HistorySize returns the number of state histories kept on disk along with
+// their total size. It's only supported by path-based database and will
+// return an error for others.
+func (db *Database) HistorySize() (uint64, common.StorageSize, error) {
+	pdb, ok := db.backend.(*pathdb.Database)
+	if !ok {
+		return 0, 0, errors.New("not supported")
+	}
+	return pdb.HistorySize()
+}
+
 // Scheme returns the node scheme used in the database.
 func (db *Database) Scheme() string {
 	if db.config.PathDB != nil {
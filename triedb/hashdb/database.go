@@ -624,6 +624,16 @@ func (db *Database) Size() (common.StorageSize, common.StorageSize) {
 	return 0, db.dirtiesSize + db.childrenSize + metadataSize
 }
 
+// CleanSize returns the memory used by the clean node cache.
+func (db *Database) CleanSize() common.StorageSize {
+	if db.cleans == nil {
+		return 0
+	}
+	var stats fastcache.Stats
+	db.cleans.UpdateStats(&stats)
+	return common.StorageSize(stats.BytesSize)
+}
+
 // Close closes the trie database and releases all held resources.
 func (db *Database) Close() error {
 	if db.cleans != nil {
@@ -455,6 +455,32 @@ func (db *Database) Size() (diffs common.StorageSize, nodes common.StorageSize)
 	return diffs, nodes
 }
 
+// CleanSize returns the memory used by the clean node cache.
+func (db *Database) CleanSize() common.StorageSize {
+	return db.tree.bottom().cleanSize()
+}
+
+// HistorySize returns the number of state histories kept in the freezer along
+// with their total size.
+func (db *Database) HistorySize() (uint64, common.StorageSize, error) {
+	if db.freezer == nil {
+		return 0, 0, nil
+	}
+	head, err := db.freezer.Ancients()
+	if err != nil {
+		return 0, 0, err
+	}
+	tail, err := db.freezer.Tail()
+	if err != nil {
+		return 0, 0, err
+	}
+	size, err := rawdb.ReadStateHistorySize(db.freezer)
+	if err != nil {
+		return 0, 0, err
+	}
+	return head - tail, common.StorageSize(size), nil
+}
+
 // Initialized returns an indicator if the state data is already
 // initialized in path-based scheme.
 func (db *Database) Initialized(genesisRoot common.Hash) bool {
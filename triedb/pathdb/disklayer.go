@@ -282,6 +282,16 @@ func (dl *diskLayer) size() common.StorageSize {
 	return common.StorageSize(dl.buffer.size)
 }
 
+// cleanSize returns the memory used by the clean node cache.
+func (dl *diskLayer) cleanSize() common.StorageSize {
+	if dl.cleans == nil {
+		return 0
+	}
+	var stats fastcache.Stats
+	dl.cleans.UpdateStats(&stats)
+	return common.StorageSize(stats.BytesSize)
+}
+
 // resetCache releases the memory held by clean cache to prevent memory leak.
 func (dl *diskLayer) resetCache() {
 	dl.lock.RLock()